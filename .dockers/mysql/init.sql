@@ -12,7 +12,9 @@ CREATE DATABASE IF NOT EXISTS `go_course_users`;
 /*base de datos*/
 USE `go_course_users`;
 
-/*Crear tabla usuarios si no existe*/
+/*Crear tabla usuarios si no existe. Este script crea solo el esquema inicial; los cambios posteriores (verificación de
+correo, autenticación de dos factores, auditoría, outbox, webhooks y búsqueda de usuarios) están en migrations/ y la
+aplicación los aplica al iniciarse.*/
 CREATE TABLE IF NOT EXISTS `users` (
     `id` INT NOT NULL AUTO_INCREMENT,
     `first_name` VARCHAR(45) NULL,
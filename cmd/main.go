@@ -1,83 +1,341 @@
 package main
 
 import (
-	"context"  // Proporciona funcionalidades para manejar contextos en Go
-	"fmt"      // Paquete para formateo de salida
-	"log"      // Paquete para registro de errores
-	"net/http" // Paquete para crear servidores HTTP
-	"os"       // Proporciona funciones para interactuar con el sistema operativo
+	"context"   // Proporciona funcionalidades para manejar contextos en Go
+	"log"       // Paquete para registro de errores
+	"net"       // Proporciona funciones para manejar direcciones de red
+	"net/http"  // Paquete para crear servidores HTTP
+	"os"        // Proporciona funciones para interactuar con el sistema operativo
+	"os/signal" // Permite recibir las señales del sistema operativo
+	"strconv"   // Convierte números a texto
+	"sync"      // Proporciona primitivas de sincronización
+	"syscall"   // Define las señales del sistema operativo
+	"time"      // Proporciona funcionalidades para medir y mostrar el tiempo
 
 	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/webhook"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/migrations"
 	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/bootstrap"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
 	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/handler"
-	"github.com/joho/godotenv"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/health"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/idempotency"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/metrics"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/migrate"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tlsconfig"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// readinessTimeout es el tiempo máximo de las verificaciones de las dependencias en /readyz.
+const readinessTimeout = 2 * time.Second
+
+// flushTimeout es el tiempo máximo de la última pasada del outbox y de los webhooks al detenerse, y el de la
+// exportación de los spans pendientes. Cada uno tiene su propio plazo, ya que el drenaje de las solicitudes puede
+// haber consumido todo el tiempo de SHUTDOWN_TIMEOUT.
+const flushTimeout = 10 * time.Second
+
 func main() {
-	// Importo las variables de entorno desde el archivo .env
-	_ = godotenv.Load()
+	// Registra las señales de detención (SIGINT o SIGTERM) antes que nada, para que una señal recibida durante el
+	// inicio no termine el proceso con la acción predeterminada, sin cerrar la base de datos ni drenar las solicitudes.
+	// El contexto se cancela al recibir la señal y cancela también los pasos del inicio que lo usan.
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Carga y valida la configuración desde las variables de entorno, el archivo .env y el archivo CONFIG_FILE.
+	// Si la configuración no es válida (por ejemplo, si falta el token) la aplicación no se inicia.
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Conexión a la base de datos MySQL utilizando Docker
-	db, err := bootstrap.NewBD()
+	db, err := bootstrap.NewBD(cfg.Database)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close() // Cerrar la conexión a la base de datos al finalizar
+
+	// startupStopped detiene la aplicación sin error, después de cerrar la base de datos, si llegó una señal de
+	// detención durante el inicio; si no, no hace nada.
+	startupStopped := func() {
+		if signals.Err() != nil {
+			log.Print("shutdown signal received during startup")
+			_ = db.Close()
+			os.Exit(0)
+		}
+	}
 
 	// Verifica la conexión con la base de datos MySQL
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(signals); err != nil {
+		startupStopped()
+		log.Fatal(err)
+	}
+
+	// Crea el logger estructurado para registrar mensajes en la salida estándar en formato JSON
+	logger, err := bootstrap.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Aplica las migraciones pendientes del esquema (migrations/), salvo que se apliquen por separado con DB_MIGRATE=false
+	if cfg.Database.Migrate {
+		if err := migrate.Up(signals, db, migrations.FS, logger); err != nil {
+			startupStopped()
+			log.Fatal(err)
+		}
+	}
+
+	// Tiempo máximo para drenar las solicitudes en curso al detener el servidor, espera previa para que el balanceador
+	// deje de enviar tráfico y tiempo máximo de cada operación sobre la base de datos
+	shutdownTimeout := cfg.Server.ShutdownTimeout.Std()
+	readinessDelay := cfg.Server.ReadinessDelay.Std()
+	queryTimeout := cfg.Database.QueryTimeout.Std()
+
+	// Crea las métricas del servicio, incluidas las estadísticas del pool de conexiones a la base de datos
+	m := metrics.New()
+	if err := m.RegisterDB("users", db); err != nil {
+		log.Fatal(err)
+	}
+
+	// Configura las trazas de OpenTelemetry y la propagación del encabezado traceparent
+	shutdownTracing, err := bootstrap.NewTracing(context.Background(), cfg.Tracing)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Crea un logger para registrar mensajes en la salida estándar
-	logger := bootstrap.NewLogger()
+	// Crea un repositorio de usuarios utilizando la base de datos y el logger, midiendo y trazando cada consulta
+	repo := user.NewTracedRepo(user.NewInstrumentedRepo(user.NewRepo(db, logger, queryTimeout), m.ObserveQuery("user")))
 
-	// Crea un repositorio de usuarios utilizando la base de datos y el logger
-	repo := user.NewRepo(db, logger)
+	// Completa el texto de búsqueda de los usuarios creados antes de la migración que lo agregó
+	if _, err := repo.BackfillSearchText(signals); err != nil {
+		startupStopped()
+		logger.Error("search text backfill failed", "error", err)
+	}
+
+	// Crea el Mailer para enviar los correos de verificación
+	mailer := bootstrap.NewMailer(cfg.Mail, logger)
+
+	// Crea un servicio de usuarios utilizando el logger, el repositorio y el Mailer, trazando cada método
+	service := user.NewTracedService(user.NewService(logger, repo, mailer, cfg.Mail.VerifyURL))
+
+	// Crea el servicio de webhooks, que recibe los eventos del outbox y los envía a las suscripciones. Salvo que se
+	// permitan las redes privadas, las suscripciones no pueden apuntar a servicios internos.
+	allowPrivate := cfg.Webhooks.AllowPrivateNetworks
+	webhookService := webhook.NewService(logger, webhook.NewRepo(db, logger, queryTimeout), webhook.NewClient(10*time.Second, allowPrivate), webhook.Config{AllowPrivateNetworks: allowPrivate})
 
-	// Crea un servicio de usuarios utilizando el logger y el repositorio
-	service := user.NewService(logger, repo)
+	// Crea el dispatcher que entrega los eventos del outbox a los webhooks y a los sinks configurados. Con varias
+	// instancias, cada pasada reserva la entrega con un bloqueo de MySQL, por lo que se entregan de a una instancia
+	sinks, err := bootstrap.NewOutboxSinks(cfg.Outbox)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sinks = append(sinks, webhookService)
+	dispatcher := outbox.NewDispatcher(outbox.NewSQLStore(db), sinks, logger, time.Second)
 
-	// Crea un contexto de fondo para las solicitudes HTTP
-	ctx := context.Background()
+	// Si llegó una señal durante el inicio, se detiene antes de iniciar los procesos en segundo plano y los servidores.
+	// Las señales que lleguen después se atienden con el apagado ordenado.
+	startupStopped()
 
-	// Configura el servidor HTTP para manejar las solicitudes relacionadas con usuarios
-	h := handler.NewUserHTTPServer(user.MakeEndpoints(ctx, service))
+	// Inicia en segundo plano la entrega de los eventos del outbox y de las entregas pendientes de webhooks.
+	// workersCtx se cancela al detener el servidor, y workers permite esperar a que terminen.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhook.NewWorker(webhookService, logger, time.Second).Run(workersCtx)
+	}()
 
-	// Importo el puerto desde las variables de entorno
-	port := os.Getenv("PORT")
-	// Imprime un mensaje indicando el puerto donde se inicia el servidor
-	fmt.Println("Server started at port ", port)
+	// Configura las verificaciones de readiness de las dependencias
+	readiness := health.NewReadiness(readinessTimeout)
+	readiness.Add("database", db.PingContext)
 
-	address := fmt.Sprintf("127.0.0.1:%s", port)
+	// Crea los endpoints de usuarios. La obtención por lotes admite hasta USERS_BATCH_MAX_IDS IDs por solicitud, y la
+	// creación admite el encabezado Idempotency-Key: las respuestas se guardan en memoria durante IDEMPOTENCY_TTL para
+	// devolverlas en los reintentos sin crear usuarios duplicados.
+	userEndpoints := user.MakeEndpoints(service, user.Config{BatchMaxIDs: cfg.Users.BatchMaxIDs})
+	userEndpoints.Create = user.Controller(idempotency.Wrap(transport.Endpoint(userEndpoints.Create), idempotency.NewMemoryStore(), cfg.Idempotency.TTL.Std(), logger))
 
-	// Configura y lanza el servidor HTTP
+	// Crea el esquema de GraphQL de usuarios, cuyos resolvers llaman al mismo servicio que los endpoints
+	userGraphQL, err := user.NewGraphQL(service, user.Config{BatchMaxIDs: cfg.Users.BatchMaxIDs})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Configura el servidor HTTP para manejar las solicitudes relacionadas con usuarios, webhooks, el estado del servicio y las métricas.
+	// Los límites de solicitudes por cliente se guardan en memoria; con varias instancias se puede usar un ratelimit.Store compartido
+	limits := ratelimit.NewMemoryStore()
+	wh := handler.NewWebhookHTTPServer(cfg, webhook.MakeEndpoints(webhookService), m, logger)
+	hh := handler.NewHealthHTTPServer(readiness)
+	mux := http.NewServeMux()
+	mux.Handle("/", handler.NewUserHTTPServer(cfg, userEndpoints, m, limits, logger))
+	mux.Handle("/graphql", handler.NewUserGraphQLServer(cfg, userGraphQL, m, limits, logger))
+	mux.Handle("/webhooks", wh)
+	mux.Handle("/webhooks/", wh)
+	mux.Handle("/healthz", hh)
+	mux.Handle("/readyz", hh)
+	mux.Handle("/metrics", handler.NewMetricsHTTPServer(cfg, m.Handler()))
+
+	// Dirección en la que escucha el servidor, con la interfaz y el puerto configurados
+	address := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+
+	// Configura el servidor HTTP
 	srv := &http.Server{
-		Handler: accessControl(h),
+		Handler: mux,
 		Addr:    address,
 	}
 
-	log.Fatal(srv.ListenAndServe())
-}
+	// Si TLS está configurado, el certificado (y la CA de clientes con mTLS) se recarga al cambiar en disco
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled() {
+		reloader, err := tlsconfig.New(tlsconfig.Options{
+			CertFile:          tlsCfg.CertFile,
+			KeyFile:           tlsCfg.KeyFile,
+			ClientCAFile:      tlsCfg.ClientCAFile,
+			RequireClientCert: tlsCfg.ClientAuth == config.ClientAuthRequire,
+			ReloadInterval:    tlsCfg.ReloadInterval.Std(),
+		}, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.TLSConfig = reloader.Config()
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			reloader.Run(workersCtx)
+		}()
+	}
+
+	// Configura el servidor gRPC de usuarios sobre los mismos endpoints, en la misma interfaz y con el mismo TLS que el servidor HTTP
+	var grpcSrv *grpc.Server
+	grpcAddress := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.GRPC.Port))
+	if cfg.GRPC.Port != 0 {
+		var opts []grpc.ServerOption
+		if srv.TLSConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(srv.TLSConfig)))
+		}
+		grpcSrv = handler.NewUserGRPCServer(cfg, userEndpoints, limits, logger, opts...)
+	}
 
-// accessControl agrega encabezados de control de acceso a todas las solicitudes HTTP.
-func accessControl(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Configura los encabezados de control de acceso permitiendo solicitudes desde cualquier origen.
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		// Configura los métodos HTTP permitidos.
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS, HEAD, DELETE")
-		// Configura los encabezados HTTP permitidos.
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
-
-		// Maneja las solicitudes de opción (preflight) y responde directamente sin pasarlas al manejador principal.
-		if r.Method == "OPTIONS" {
+	// Registra la dirección donde se inicia el servidor y lo lanza en segundo plano
+	logger.Info("server started", "address", address, "tls", cfg.Server.TLS.Enabled(), "mtls", cfg.Server.TLS.ClientCAFile != "")
+	serverErr := make(chan error, 2)
+	go func() {
+		if srv.TLSConfig != nil {
+			serverErr <- srv.ListenAndServeTLS("", "")
 			return
 		}
+		serverErr <- srv.ListenAndServe()
+	}()
 
-		// Pasa la solicitud al siguiente manejador en la cadena.
-		h.ServeHTTP(w, r)
-	})
+	// Lanza el servidor gRPC en segundo plano, si está habilitado
+	if grpcSrv != nil {
+		listener, err := net.Listen("tcp", grpcAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Info("gRPC server started", "address", grpcAddress, "reflection", cfg.GRPC.Reflection)
+		go func() {
+			serverErr <- grpcSrv.Serve(listener)
+		}()
+	}
+
+	// Espera a que llegue una señal de detención (SIGINT o SIGTERM), incluso si llegó mientras se iniciaban los
+	// servidores, o a que el servidor falle
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		logger.Error("server stopped", "error", err)
+		exitCode = 1
+		readiness.SetShuttingDown()
+	case <-signals.Done():
+		// Informa al orquestador que el servicio ya no debe recibir tráfico y sigue atendiendo las solicitudes nuevas
+		// durante readinessDelay, hasta que el balanceador deja de enviarlas: si se dejaran de aceptar conexiones de
+		// inmediato, las solicitudes que llegan antes de que el balanceador vea /readyz en 503 fallarían.
+		// Una segunda señal detiene el proceso de inmediato.
+		readiness.SetShuttingDown()
+		stopSignals()
+		logger.Info("shutdown signal received, waiting for the load balancer to stop sending traffic", "delay", readinessDelay.String())
+		select {
+		case <-time.After(readinessDelay):
+		case err := <-serverErr:
+			logger.Error("server stopped", "error", err)
+			exitCode = 1
+		}
+		logger.Info("draining in-flight requests", "timeout", shutdownTimeout.String())
+	}
+
+	// Deja de aceptar conexiones y espera a que terminen las solicitudes en curso dentro del tiempo máximo
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP server did not drain in time, closing remaining connections", "error", err)
+		_ = srv.Close()
+		exitCode = 1
+	} else {
+		logger.Info("HTTP server drained")
+	}
+
+	// Deja de aceptar llamadas gRPC y espera a que terminen las llamadas en curso dentro del mismo tiempo máximo
+	if grpcSrv != nil {
+		drained := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+			logger.Info("gRPC server drained")
+		case <-shutdownCtx.Done():
+			logger.Error("gRPC server did not drain in time, closing remaining connections")
+			grpcSrv.Stop()
+			exitCode = 1
+		}
+	}
+
+	// Detiene los procesos en segundo plano y realiza una última pasada para entregar
+	// los eventos generados por las solicitudes drenadas
+	stopWorkers()
+	workers.Wait()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	if err := dispatcher.Dispatch(flushCtx); err != nil {
+		logger.Error("outbox flush failed", "error", err)
+		exitCode = 1
+	}
+	if err := webhookService.DeliverDue(flushCtx); err != nil {
+		logger.Error("webhook flush failed", "error", err)
+		exitCode = 1
+	}
+	logger.Info("background workers stopped")
+
+	// Exporta los spans pendientes, incluidos los de la última pasada
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Error("tracing shutdown failed", "error", err)
+		exitCode = 1
+	}
+
+	// Cierra el pool de conexiones a la base de datos
+	if err := db.Close(); err != nil {
+		logger.Error("database close failed", "error", err)
+		exitCode = 1
+	}
+
+	if exitCode != 0 {
+		logger.Error("shutdown finished with errors")
+	} else {
+		logger.Info("shutdown completed")
+	}
+	os.Exit(exitCode)
 }
 
 /*
@@ -98,11 +356,13 @@ Funcionamiento de la arquitectura:
 1. El archivo main.go es el punto de entrada de la aplicación.
 2. Se importan los paquetes necesarios.
 3. La función main() inicia la aplicación:
--- Carga las variables de entorno desde el archivo .env.
--- Establece una conexión a la base de datos MySQL utilizando Docker.
--- Crea un logger para registrar mensajes.
+-- Carga y valida la configuración desde las variables de entorno, el archivo .env y el archivo CONFIG_FILE (YAML o TOML).
+-- Establece una conexión a la base de datos MySQL utilizando Docker y aplica las migraciones pendientes del esquema.
+-- Crea un logger estructurado que registra mensajes en JSON, con el ID de la solicitud en cada línea.
 -- Crea un repositorio y un servicio para gestionar usuarios.
 -- Configura el servidor HTTP para manejar las solicitudes relacionadas con usuarios.
--- Configura y lanza el servidor en el puerto especificado en las variables de entorno.
-4. La función accessControl() es un middleware que agrega encabezados de control de acceso a todas las solicitudes HTTP, permitiendo solicitudes desde cualquier origen, configurando los métodos HTTP permitidos y los encabezados permitidos. Además, maneja las solicitudes de opción (preflight) y las responde directamente sin pasarlas al manejador principal.
+-- Configura y lanza el servidor en la dirección y el puerto configurados, con TLS (y mTLS) si se configuraron el certificado y la CA de clientes.
+-- Si GRPC_PORT no es cero, lanza también el servidor gRPC de usuarios en ese puerto, con la misma configuración de TLS.
+-- Al recibir SIGINT o SIGTERM responde 503 en /readyz durante SHUTDOWN_READINESS_DELAY, deja de aceptar conexiones, drena las solicitudes en curso dentro de SHUTDOWN_TIMEOUT, detiene los procesos en segundo plano y cierra la base de datos.
+4. La política CORS (orígenes, métodos de cada ruta, encabezados, credenciales y caché de las solicitudes preflight) la aplican los servidores HTTP de usuarios y webhooks según la configuración.
 */
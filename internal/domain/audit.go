@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Acciones registradas en el log de auditoría.
+const (
+	AuditActionCreate = "create" // Creación de un usuario
+	AuditActionUpdate = "update" // Actualización de un usuario
+	AuditActionDelete = "delete" // Eliminación de un usuario
+)
+
+// FieldChange representa el valor de un campo antes y después de un cambio.
+type FieldChange struct {
+	Before interface{} `json:"before"` // Valor anterior del campo (nil en una creación)
+	After  interface{} `json:"after"`  // Valor nuevo del campo (nil en una eliminación)
+}
+
+// AuditEntry representa un registro del log de auditoría de los cambios sobre usuarios.
+type AuditEntry struct {
+	ID uint64 `json:"id"` // Identificador único del registro
+
+	Actor string `json:"actor"` // Quién realizó el cambio: la identidad del certificado de cliente o "token"
+
+	DeclaredActor string `json:"declared_actor,omitempty"` // Actor que declaró el cliente con el encabezado X-Actor, sin autenticar
+
+	Action string `json:"action"` // Acción realizada (create, update o delete)
+
+	UserID uint64 `json:"user_id"` // Usuario afectado por el cambio
+
+	Changes map[string]FieldChange `json:"changes"` // Campos modificados con su valor anterior y nuevo
+
+	RequestID string `json:"request_id"` // ID de la solicitud que originó el cambio
+
+	CreatedAt time.Time `json:"created_at"` // Momento en el que se realizó el cambio
+}
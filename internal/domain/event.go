@@ -0,0 +1,8 @@
+package domain
+
+// Tipos de eventos de dominio que se publican al modificar usuarios.
+const (
+	EventUserCreated = "user.created" // Se creó un usuario
+	EventUserUpdated = "user.updated" // Se actualizó un usuario
+	EventUserDeleted = "user.deleted" // Se eliminó un usuario
+)
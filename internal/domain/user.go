@@ -9,4 +9,8 @@ type User struct {
 	LastName string `json:"last_name"` // Apellido del usuario
 
 	Email string `json:"email"` // Dirección de correo electrónico del usuario
+
+	EmailVerified bool `json:"email_verified"` // Indica si el usuario confirmó su correo electrónico
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"` // Indica si el usuario tiene activada la autenticación de dos factores
 }
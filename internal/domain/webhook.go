@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Estados de una entrega de webhook.
+const (
+	DeliveryStatusPending   = "pending"   // Pendiente de entrega o esperando un reintento
+	DeliveryStatusDelivered = "delivered" // Entregada correctamente
+	DeliveryStatusDead      = "dead"      // Se agotaron los reintentos (dead letter)
+)
+
+// WebhookSubscription representa la suscripción de un socio a los eventos de usuarios.
+type WebhookSubscription struct {
+	ID uint64 `json:"id"` // Identificador único de la suscripción
+
+	URL string `json:"url"` // URL a la que se envían los eventos
+
+	EventTypes []string `json:"event_types"` // Tipos de eventos a los que está suscrita
+
+	Secret string `json:"secret,omitempty"` // Secreto para firmar las entregas; solo se devuelve al crear la suscripción
+
+	Active bool `json:"active"` // Indica si la suscripción recibe eventos
+
+	CreatedAt time.Time `json:"created_at"` // Momento en el que se creó la suscripción
+}
+
+// WebhookDelivery representa la entrega de un evento a una suscripción.
+type WebhookDelivery struct {
+	ID uint64 `json:"id"` // Identificador único de la entrega
+
+	SubscriptionID uint64 `json:"subscription_id"` // Suscripción a la que se entrega el evento
+
+	EventID uint64 `json:"event_id"` // ID del evento en el outbox
+
+	EventType string `json:"event_type"` // Tipo del evento
+
+	Payload json.RawMessage `json:"payload"` // Cuerpo que se envía a la URL de la suscripción
+
+	Status string `json:"status"` // Estado de la entrega (pending, delivered o dead)
+
+	Attempts int `json:"attempts"` // Cantidad de intentos realizados
+
+	LastStatusCode int `json:"last_status_code,omitempty"` // Código de estado HTTP de la última respuesta
+
+	LastError string `json:"last_error,omitempty"` // Error del último intento fallido
+
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // Momento del próximo intento, si está pendiente
+
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"` // Momento en el que se entregó, si fue entregada
+
+	CreatedAt time.Time `json:"created_at"` // Momento en el que se generó la entrega
+}
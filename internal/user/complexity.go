@@ -0,0 +1,205 @@
+package user
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// maxRootFields es la cantidad máxima de campos raíz de una operación de GraphQL, contando cada alias por separado.
+	maxRootFields = 20
+
+	// maxComplexity es el costo máximo de una operación de GraphQL (ver costCounter.cost).
+	maxComplexity = 5000
+
+	// maxSelectionDepth es la profundidad máxima de las selecciones que se recorren al calcular el costo; las
+	// operaciones válidas del esquema no superan los cinco niveles.
+	maxSelectionDepth = 20
+)
+
+// operation resume la operación de GraphQL que ejecuta una solicitud.
+type operation struct {
+	mutation   bool // Si la operación es una mutación
+	rootFields int  // Cantidad de campos raíz, contando cada alias por separado
+	cost       int  // Costo de la operación (ver costCounter.cost)
+
+	// targets son los IDs de los usuarios que modifican o eliminan las mutaciones updateUser y deleteUser de la
+	// operación, uno por mutación; los IDs que no son válidos no se incluyen, porque el resolver los rechaza.
+	targets []uint64
+}
+
+// analyzeOperation analiza la operación de la solicitud sin ejecutarla. Devuelve false si el documento no se puede
+// analizar o no indica qué operación ejecutar; en ese caso, Execute informa el error al ejecutarla.
+func analyzeOperation(req GraphQLReq) (operation, bool) {
+	req.Parse()
+	return req.op, req.opOK
+}
+
+// analyzeDocument analiza la operación que ejecuta la solicitud en el documento ya analizado de su consulta.
+func analyzeDocument(doc *ast.Document, req GraphQLReq) (operation, bool) {
+	var (
+		selected  *ast.OperationDefinition
+		count     int
+		fragments = map[string]*ast.FragmentDefinition{}
+	)
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.OperationDefinition:
+			count++
+			if req.OperationName == "" || (d.Name != nil && d.Name.Value == req.OperationName) {
+				selected = d
+			}
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		}
+	}
+	if selected == nil || (req.OperationName == "" && count > 1) {
+		return operation{}, false
+	}
+
+	op := operation{mutation: selected.Operation == ast.OperationTypeMutation}
+	c := &costCounter{fragments: fragments, variables: req.Variables, visiting: map[string]bool{}}
+	op.rootFields = c.fields(selected.SelectionSet, 0)
+	c.visited = 0
+	op.cost = c.cost(selected.SelectionSet, 0)
+	if op.mutation {
+		c.visited = 0
+		c.each(selected.SelectionSet, 0, func(f *ast.Field) {
+			if f.Name.Value != "updateUser" && f.Name.Value != "deleteUser" {
+				return
+			}
+			if id, ok := c.id(f); ok {
+				op.targets = append(op.targets, id)
+			}
+		})
+	}
+	return op, true
+}
+
+// checkOperation verifica que la operación no supere la cantidad máxima de campos raíz ni el costo máximo.
+func checkOperation(op operation) error {
+	if op.rootFields > maxRootFields {
+		return fmt.Errorf("%w: the operation has %d root fields, the maximum is %d", ErrQueryTooComplex, op.rootFields, maxRootFields)
+	}
+	if op.cost > maxComplexity {
+		return fmt.Errorf("%w: the operation costs %d, the maximum is %d", ErrQueryTooComplex, op.cost, maxComplexity)
+	}
+	return nil
+}
+
+// costCounter calcula el costo de las selecciones de una operación, expandiendo sus fragmentos.
+type costCounter struct {
+	fragments map[string]*ast.FragmentDefinition // Fragmentos del documento, por nombre
+	variables map[string]interface{}             // Valores de las variables de la operación
+	visiting  map[string]bool                    // Fragmentos que se están expandiendo, para no recorrer ciclos
+	visited   int                                // Campos recorridos; el recorrido se detiene al superar maxComplexity
+}
+
+// fields devuelve la cantidad de campos de la selección, sin contar sus subselecciones.
+func (c *costCounter) fields(set *ast.SelectionSet, depth int) int {
+	n := 0
+	c.each(set, depth, func(*ast.Field) { n++ })
+	return n
+}
+
+// cost devuelve el costo de la selección: cada campo vale 1 más el costo de su subselección, y la
+// subselección de la conexión users se multiplica por la cantidad de usuarios que pide (first).
+func (c *costCounter) cost(set *ast.SelectionSet, depth int) int {
+	total := 0
+	c.each(set, depth, func(f *ast.Field) {
+		total++
+		if f.SelectionSet != nil && depth < maxSelectionDepth {
+			total += c.multiplier(f) * c.cost(f.SelectionSet, depth+1)
+		}
+	})
+	return total
+}
+
+// each llama a fn con cada campo de la selección, incluidos los de sus fragmentos. Como cada campo recorrido suma al
+// menos 1 al costo, el recorrido se detiene al superar maxComplexity campos, de modo que los fragmentos anidados que
+// se expanden muchas veces no hacen que el análisis tarde más que la operación.
+func (c *costCounter) each(set *ast.SelectionSet, depth int, fn func(f *ast.Field)) {
+	if set == nil || depth > maxSelectionDepth {
+		return
+	}
+	for _, sel := range set.Selections {
+		if c.visited > maxComplexity {
+			return
+		}
+		switch s := sel.(type) {
+		case *ast.Field:
+			c.visited++
+			fn(s)
+		case *ast.InlineFragment:
+			c.each(s.SelectionSet, depth, fn)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			if f, ok := c.fragments[name]; ok && !c.visiting[name] {
+				c.visiting[name] = true
+				c.each(f.SelectionSet, depth, fn)
+				delete(c.visiting, name)
+			}
+		}
+	}
+}
+
+// multiplier devuelve la cantidad de elementos que devuelve el campo: first para la conexión users (o su valor
+// predeterminado) y 1 para los demás campos.
+func (c *costCounter) multiplier(f *ast.Field) int {
+	if f.Name.Value != "users" {
+		return 1
+	}
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		var n int
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			n, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			// Las variables decodificadas de JSON tienen los números como float64.
+			switch value := c.variables[v.Name.Value].(type) {
+			case float64:
+				n = int(value)
+			case int:
+				n = value
+			}
+		}
+		if n < 1 || n > maxPageSize {
+			// El resolver rechaza los valores fuera de rango; para el costo se considera el máximo.
+			n = maxPageSize
+		}
+		return n
+	}
+	return defaultPageSize
+}
+
+// id devuelve el valor del argumento id del campo, como un literal o como una variable de la operación.
+func (c *costCounter) id(f *ast.Field) (uint64, bool) {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "id" {
+			continue
+		}
+		var s string
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			s = v.Value
+		case *ast.StringValue:
+			s = v.Value
+		case *ast.Variable:
+			// El tipo ID admite tanto cadenas como números; los números decodificados de JSON son float64.
+			switch value := c.variables[v.Name.Value].(type) {
+			case string:
+				s = value
+			case float64:
+				s = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+		id, err := strconv.ParseUint(s, 10, 64)
+		return id, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAnalyzeOperation verifica la cantidad de campos raíz, el costo y los usuarios que modifican o eliminan distintas
+// operaciones, y cuáles superan los límites.
+func TestAnalyzeOperation(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     GraphQLReq
+		want    operation
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:   "single user",
+			req:    GraphQLReq{Query: `{ user(id: "1") { id firstName } }`},
+			want:   operation{rootFields: 1, cost: 3},
+			wantOK: true,
+		},
+		{
+			name:   "users page multiplies its selection",
+			req:    GraphQLReq{Query: `{ users(first: 10) { edges { node { id } } } }`},
+			want:   operation{rootFields: 1, cost: 1 + 10*3},
+			wantOK: true,
+		},
+		{
+			name:   "users page from a variable",
+			req:    GraphQLReq{Query: `query($n: Int) { users(first: $n) { edges { cursor } } }`, Variables: map[string]interface{}{"n": float64(50)}},
+			want:   operation{rootFields: 1, cost: 1 + 50*2},
+			wantOK: true,
+		},
+		{
+			name:   "users page without first uses the default",
+			req:    GraphQLReq{Query: `{ users { edges { cursor } } }`},
+			want:   operation{rootFields: 1, cost: 1 + defaultPageSize*2},
+			wantOK: true,
+		},
+		{
+			name:   "aliases count as root fields",
+			req:    GraphQLReq{Query: `mutation { a: deleteUser(id: "1") b: deleteUser(id: "2") c: deleteUser(id: "3") }`},
+			want:   operation{mutation: true, rootFields: 3, cost: 3, targets: []uint64{1, 2, 3}},
+			wantOK: true,
+		},
+		{
+			name: "two-factor targets from literals and variables",
+			req: GraphQLReq{
+				Query:     `mutation($a: ID!, $b: ID!) { updateUser(id: $a, input: {firstName: "A"}) { id } deleteUser(id: $b) x: deleteUser(id: 7) y: deleteUser(id: "bad") }`,
+				Variables: map[string]interface{}{"a": "5", "b": float64(6)},
+			},
+			want:   operation{mutation: true, rootFields: 4, cost: 5, targets: []uint64{5, 6, 7}},
+			wantOK: true,
+		},
+		{
+			name:   "fragments are expanded",
+			req:    GraphQLReq{Query: `query { ...F } fragment F on Query { a: user(id: "1") { id } b: user(id: "2") { id } }`},
+			want:   operation{rootFields: 2, cost: 4},
+			wantOK: true,
+		},
+		{
+			name:   "selected operation",
+			req:    GraphQLReq{Query: `query Q { user(id: "1") { id } } mutation M { deleteUser(id: "1") }`, OperationName: "M"},
+			want:   operation{mutation: true, rootFields: 1, cost: 1, targets: []uint64{1}},
+			wantOK: true,
+		},
+		{name: "several operations without a name", req: GraphQLReq{Query: `query Q { user(id: "1") { id } } query R { user(id: "2") { id } }`}},
+		{name: "syntax error", req: GraphQLReq{Query: `{ user(id: `}},
+		{
+			name:    "too many root fields",
+			req:     GraphQLReq{Query: "{" + strings.Repeat(` u: user(id: "1") { id }`, maxRootFields+1) + " }"},
+			want:    operation{rootFields: maxRootFields + 1, cost: 2 * (maxRootFields + 1)},
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:    "too complex",
+			req:     GraphQLReq{Query: "{" + strings.Repeat(` x: users(first: 100) { edges { node { id firstName lastName email emailVerified twoFactorEnabled } } }`, 7) + " }"},
+			want:    operation{rootFields: 7, cost: 7 * (1 + 100*8)},
+			wantOK:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, ok := analyzeOperation(tt.req)
+			if ok != tt.wantOK || !reflect.DeepEqual(op, tt.want) {
+				t.Fatalf("analyzeOperation() = %+v, %v; want %+v, %v", op, ok, tt.want, tt.wantOK)
+			}
+			if err := checkOperation(op); (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrQueryTooComplex)) {
+				t.Errorf("checkOperation() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestAnalyzeOperationNestedFragments verifica que los fragmentos anidados que se expanden muchas veces no hagan que
+// el análisis recorra una cantidad exponencial de campos.
+func TestAnalyzeOperationNestedFragments(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{ ...F0 }`)
+	for i := 0; i < 30; i++ {
+		b.WriteString(fmt.Sprintf(" fragment F%d on Query { ...F%d ...F%d }", i, i+1, i+1))
+	}
+	b.WriteString(` fragment F30 on Query { user(id: "1") { id } }`)
+
+	op, ok := analyzeOperation(GraphQLReq{Query: b.String()})
+	if !ok {
+		t.Fatal("analyzeOperation() = false")
+	}
+	if err := checkOperation(op); !errors.Is(err, ErrQueryTooComplex) {
+		t.Errorf("checkOperation() = %v, want ErrQueryTooComplex", err)
+	}
+}
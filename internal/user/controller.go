@@ -3,9 +3,15 @@ package user
 import (
 	"context" // El paquete `context` proporciona un objeto de contexto para llevar información del ámbito de la solicitud.
 	"errors"
-	"fmt" // El paquete `fmt` proporciona funciones para el formateo de salida de datos.
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
 )
 
 // Definición de tipos
@@ -21,44 +27,132 @@ type (
 		Get    Controller // Campo `Get` de tipo `Controller` que almacena el controlador para el endpoint de obtención de un usuario por ID.
 		Update Controller // Campo `Update` de tipo `Controller` que almacena el controlador para el endpoint de actualización de un usuario por ID.
 		Delete Controller // // Campo `Delete` de tipo `Controller` que almacena el controlador para el endpoint de eliminación de un usuario por ID.
+		Verify Controller // Campo `Verify` de tipo `Controller` que almacena el controlador para el endpoint de verificación de correo.
+
+		BatchGet Controller // Controlador para el endpoint de obtención de varios usuarios por sus IDs.
+		Search   Controller // Controlador para el endpoint de búsqueda de usuarios.
+
+		EnrollTwoFactor  Controller // Controlador para el endpoint de inscripción en la autenticación de dos factores.
+		ConfirmTwoFactor Controller // Controlador para el endpoint de confirmación de la autenticación de dos factores.
+		VerifyTwoFactor  Controller // Controlador para el endpoint de verificación del segundo factor.
+		DisableTwoFactor Controller // Controlador para el endpoint de desactivación de la autenticación de dos factores.
+
+		History Controller // Controlador para el endpoint del historial de cambios de un usuario.
+		Audit   Controller // Controlador para el endpoint del log de auditoría.
+	}
+
+	// GetAllReq: Define una estructura `GetAllReq` para representar los filtros de la obtención de todos los usuarios.
+	GetAllReq struct {
+		EmailVerified *bool // Si no es nil, filtra los usuarios según hayan verificado o no su correo.
 	}
 
 	GetReq struct {
 		ID uint64 // ID del usuario a obtener
 	}
 
+	// SearchReq: Define una estructura `SearchReq` para representar la búsqueda de usuarios.
+	SearchReq struct {
+		Query string // Texto a buscar en el nombre, el apellido y el correo
+		Limit int    // Cantidad máxima de resultados; cero indica la cantidad predeterminada
+	}
+
+	// BatchGetReq: Define una estructura `BatchGetReq` para representar la solicitud de varios usuarios por sus IDs.
+	BatchGetReq struct {
+		IDs []uint64 `json:"ids" openapi:"required"` // IDs de los usuarios a obtener
+	}
+
+	// BatchGetResp: Define una estructura `BatchGetResp` con los usuarios encontrados y los IDs que no existen.
+	BatchGetResp struct {
+		Users      []domain.User `json:"users"`       // Usuarios encontrados, en el orden de los IDs de la solicitud
+		MissingIDs []uint64      `json:"missing_ids"` // IDs de la solicitud que no corresponden a ningún usuario
+	}
+
 	// CreateReq: Define una estructura `CreateReq` para representar la solicitud de creación de un nuevo usuario.
 	CreateReq struct {
-		FirstName string `json:"first_name"` // Campo `FirstName` de tipo cadena para almacenar el nombre del usuario.
-		LastName  string `json:"last_name"`  // Campo `LastName` de tipo cadena para almacenar el apellido del usuario.
-		Email     string `json:"email"`      // Campo `Email` de tipo cadena para almacenar el correo electrónico del usuario.
-		// La etiqueta `json:"first_name"` indica la clave que se usará al codificar el campo a JSON.
+		FirstName string `json:"first_name" openapi:"required,maxLength=45"` // Campo `FirstName` de tipo cadena para almacenar el nombre del usuario.
+		LastName  string `json:"last_name" openapi:"required,maxLength=45"`  // Campo `LastName` de tipo cadena para almacenar el apellido del usuario.
+		Email     string `json:"email" openapi:"format=email,maxLength=45"`  // Campo `Email` de tipo cadena para almacenar el correo electrónico del usuario.
+		// La etiqueta `json:"first_name"` indica la clave que se usará al codificar el campo a JSON y la etiqueta
+		// `openapi` agrega las restricciones del campo a la especificación OpenAPI (ver el paquete openapi), contra
+		// la que se valida el cuerpo de la solicitud. La longitud máxima es la de las columnas de la tabla users.
 	}
 
 	// UpdateReq: Define una estructura `UpdateReq` para representar la solicitud de actualización de un usuario.
 	UpdateReq struct {
 		ID        uint64  // ID del usuario a actualizar
-		FirstName *string `json:"first_name"` // Campo `FirstName` de tipo puntero a cadena para almacenar el nombre del usuario.
-		LastName  *string `json:"last_name"`  // Campo `LastName` de tipo puntero a cadena para almacenar el apellido del usuario.
-		Email     *string `json:"email"`      // Campo `Email` de tipo puntero a cadena para almacenar el correo electrónico del usuario.
+		FirstName *string `json:"first_name" openapi:"maxLength=45"`         // Campo `FirstName` de tipo puntero a cadena para almacenar el nombre del usuario.
+		LastName  *string `json:"last_name" openapi:"maxLength=45"`          // Campo `LastName` de tipo puntero a cadena para almacenar el apellido del usuario.
+		Email     *string `json:"email" openapi:"format=email,maxLength=45"` // Campo `Email` de tipo puntero a cadena para almacenar el correo electrónico del usuario.
 	}
 
 	// DeleteReq: Define una estructura `DeleteReq` para representar la solicitud de eliminación de un usuario.
 	DeleteReq struct {
 		ID uint64 // ID del usuario a eliminar
 	}
+
+	// TwoFactorReq: Define una estructura `TwoFactorReq` para representar las solicitudes de autenticación de dos factores.
+	TwoFactorReq struct {
+		ID   uint64 // ID del usuario
+		Code string `json:"code" openapi:"required"` // Código TOTP o código de recuperación
+	}
+
+	// HistoryReq: Define una estructura `HistoryReq` para representar la solicitud del historial de cambios de un usuario.
+	HistoryReq struct {
+		ID uint64 // ID del usuario
+	}
+
+	// AuditReq: Define una estructura `AuditReq` para representar los filtros de la consulta del log de auditoría.
+	AuditReq struct {
+		UserID *uint64    // Filtra los registros del usuario indicado
+		Actor  string     // Filtra los registros realizados por el actor indicado
+		Action string     // Filtra los registros de la acción indicada
+		From   *time.Time // Filtra los registros realizados a partir de esa fecha
+		To     *time.Time // Filtra los registros realizados hasta esa fecha
+		Limit  int        // Cantidad máxima de registros a devolver
+	}
+
+	// VerifyReq: Define una estructura `VerifyReq` para representar la solicitud de verificación de correo.
+	VerifyReq struct {
+		Token string // Token de verificación recibido por correo
+	}
+)
+
+const (
+	// defaultSearchLimit y maxSearchLimit son la cantidad predeterminada y máxima de resultados de una búsqueda.
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+
+	// maxSearchQueryLength es la longitud máxima del texto de una búsqueda.
+	maxSearchQueryLength = 100
 )
 
+// Config agrupa la configuración de los endpoints de usuarios.
+type Config struct {
+	BatchMaxIDs int // Cantidad máxima de IDs de una solicitud de BatchGet
+}
+
 // Funciones del controlador
 
 // MakeEndpoints crea los endpoints (rutas) de la API y asigna los controladores correspondientes.
-func MakeEndpoints(ctx context.Context, s Service) Endpoints {
+func MakeEndpoints(s Service, cfg Config) Endpoints {
 	return Endpoints{
 		Create: makeCreateEndpoint(s),
 		GetAll: makeGetAllEndpoint(s),
 		Get:    makeGetEndopoint(s),
 		Update: makeUpdateEndpoint(s),
 		Delete: makeDeleteEndpoint(s),
+		Verify: makeVerifyEndpoint(s),
+
+		BatchGet: makeBatchGetEndpoint(s, cfg.BatchMaxIDs),
+		Search:   makeSearchEndpoint(s),
+
+		EnrollTwoFactor:  makeEnrollTwoFactorEndpoint(s),
+		ConfirmTwoFactor: makeConfirmTwoFactorEndpoint(s),
+		VerifyTwoFactor:  makeVerifyTwoFactorEndpoint(s),
+		DisableTwoFactor: makeDisableTwoFactorEndpoint(s),
+
+		History: makeHistoryEndpoint(s),
+		Audit:   makeAuditEndpoint(s),
 	}
 }
 
@@ -70,21 +164,18 @@ func makeCreateEndpoint(s Service) Controller {
 		// Convierte la interfaz `data` a la estructura `CreateReq` para acceder a los campos del usuario.
 		req := request.(CreateReq)
 
-		// Valida los campos obligatorios de la solicitud (nombre, apellido y correo electrónico).
-		if req.FirstName == "" {
-			return nil, response.BadRequest(ErrFirstNameRequired.Error())
-		}
-		if req.LastName == "" {
-			return nil, response.BadRequest(ErrLastNameRequired.Error())
+		// Valida los campos obligatorios de la solicitud (nombre y apellido) y el formato del correo.
+		if err := validateUser(&req.FirstName, &req.LastName, &req.Email); err != nil {
+			return nil, err
 		}
 
 		// Llama a la función `Create` del servicio `Service` para crear el nuevo usuario.
 		// Esta función (que probablemente se encuentre en otro paquete) se encarga de la lógica de negocio para persistir el usuario en un repositorio.
 		user, err := s.Create(ctx, req.FirstName, req.LastName, req.Email)
 
-		// Maneja el error en caso de que falle la creación del usuario.
+		// Devuelve el error en caso de que falle la creación del usuario.
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, err
 		}
 		return response.Created("success", user), nil
 	}
@@ -93,11 +184,13 @@ func makeCreateEndpoint(s Service) Controller {
 // makeGetAllEndpoint crea un controlador para el endpoint de obtención de todos los usuarios.
 func makeGetAllEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		// Esta función recupera todos los usuarios del servicio y los envía como respuesta.
+		// Esta función recupera todos los usuarios del servicio que cumplen con los filtros y los envía como respuesta.
 
-		users, err := s.GetAll(ctx)
+		req := request.(GetAllReq)
+
+		users, err := s.GetAll(ctx, Filters{EmailVerified: req.EmailVerified})
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, err
 		}
 		return response.OK("success", users), nil
 	}
@@ -116,16 +209,85 @@ func makeGetEndopoint(s Service) Controller {
 
 		// Maneja el error en caso de que falle la obtención del usuario.
 		if err != nil {
-			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
-			}
-			return nil, response.InternalServerError(err.Error())
+			return nil, err
 		}
-		fmt.Println(req)
 		return response.OK("success", user), nil
 	}
 }
 
+// makeBatchGetEndpoint crea un controlador para el endpoint de obtención de varios usuarios por sus IDs, que los
+// obtiene con una sola consulta en lugar de una por usuario. Los IDs repetidos se devuelven una sola vez.
+func makeBatchGetEndpoint(s Service, maxIDs int) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(BatchGetReq)
+
+		// Valida que la solicitud tenga al menos un ID y no supere la cantidad máxima.
+		if len(req.IDs) == 0 {
+			return nil, apierror.ValidationError{Fields: []apierror.FieldError{{Pointer: "/ids", Code: "ids_required", Detail: "ids is required"}}}
+		}
+		if len(req.IDs) > maxIDs {
+			return nil, apierror.ValidationError{Fields: []apierror.FieldError{{Pointer: "/ids", Code: "too_many_ids", Detail: fmt.Sprintf("ids can't have more than %d elements", maxIDs)}}}
+		}
+
+		users, err := s.GetMany(ctx, req.IDs)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ordena los usuarios según los IDs de la solicitud e informa los que no se encontraron.
+		byID := make(map[uint64]domain.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		resp := BatchGetResp{Users: make([]domain.User, 0, len(users)), MissingIDs: []uint64{}}
+		seen := make(map[uint64]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if u, ok := byID[id]; ok {
+				resp.Users = append(resp.Users, u)
+			} else {
+				resp.MissingIDs = append(resp.MissingIDs, id)
+			}
+		}
+		return response.OK("success", resp), nil
+	}
+}
+
+// makeSearchEndpoint crea un controlador para el endpoint de búsqueda de usuarios.
+func makeSearchEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SearchReq)
+
+		// Valida la búsqueda y la cantidad de resultados.
+		var fields []apierror.FieldError
+		if strings.TrimSpace(req.Query) == "" {
+			fields = append(fields, apierror.FieldError{Pointer: "/q", Code: "query_required", Detail: "q is required"})
+		} else if utf8.RuneCountInString(req.Query) > maxSearchQueryLength {
+			fields = append(fields, apierror.FieldError{Pointer: "/q", Code: "query_too_long", Detail: fmt.Sprintf("q can't be longer than %d characters", maxSearchQueryLength)})
+		} else if len(queryTerms(req.Query)) == 0 {
+			fields = append(fields, apierror.FieldError{Pointer: "/q", Code: "query_too_short", Detail: fmt.Sprintf("q must contain a word of at least %d characters", minSearchTermLength)})
+		}
+		if req.Limit == 0 {
+			req.Limit = defaultSearchLimit
+		}
+		if req.Limit < 1 || req.Limit > maxSearchLimit {
+			fields = append(fields, apierror.FieldError{Pointer: "/limit", Code: "invalid_limit", Detail: fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit)})
+		}
+		if len(fields) > 0 {
+			return nil, apierror.ValidationError{Fields: fields}
+		}
+
+		results, err := s.Search(ctx, req.Query, req.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", results), nil
+	}
+}
+
 // makeUpdateEndpoint crea un controlador para el endpoint de actualización de un usuario por ID.
 func makeUpdateEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -134,22 +296,15 @@ func makeUpdateEndpoint(s Service) Controller {
 		// Convierte la interfaz `data` a la estructura `UpdateReq` para acceder a los campos de actualización del usuario.
 		req := request.(UpdateReq)
 
-		// Valida los campos obligatorios de la solicitud (nombre y apellido).
-		if req.FirstName != nil && *req.FirstName == "" {
-			return nil, response.BadRequest(ErrFirstNameRequired.Error())
-		}
-		if req.LastName != nil && *req.LastName == "" {
-			return nil, response.BadRequest(ErrLastNameRequired.Error())
+		// Valida que el nombre y el apellido, si se envían, no estén vacíos y que el correo tenga un formato válido.
+		if err := validateUser(req.FirstName, req.LastName, req.Email); err != nil {
+			return nil, err
 		}
 
 		// Llama a la función `Update` del servicio `Service` para actualizar los datos del usuario.
 		if err := s.Update(ctx, req.ID, req.FirstName, req.LastName, req.Email); err != nil {
-			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
-			}
-			return nil, response.InternalServerError(err.Error())
+			return nil, err
 		}
-		fmt.Println(req)
 		return response.OK("success", nil), nil
 	}
 }
@@ -166,11 +321,8 @@ func makeDeleteEndpoint(s Service) Controller {
 		// Llama al método Delete del servicio para eliminar el usuario
 		_, err := s.Delete(ctx, req.ID)
 		if err != nil {
-			// Maneja el error en caso de que falle la eliminación del usuario
-			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
-			}
-			return nil, response.InternalServerError(err.Error())
+			// Devuelve el error del servicio; encodeError lo convierte en la respuesta que tiene registrada
+			return nil, err
 		}
 
 		// Devuelve un mensaje de éxito indicando que el usuario fue eliminado
@@ -178,6 +330,142 @@ func makeDeleteEndpoint(s Service) Controller {
 	}
 }
 
+// makeVerifyEndpoint crea un controlador para el endpoint de verificación de correo electrónico.
+func makeVerifyEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyReq)
+
+		// Llama a la función `VerifyEmail` del servicio para confirmar el correo del usuario.
+		if err := s.VerifyEmail(ctx, req.Token); err != nil {
+			return nil, err
+		}
+
+		return response.OK("email verified successfully", nil), nil
+	}
+}
+
+// makeEnrollTwoFactorEndpoint crea un controlador para el endpoint de inscripción en la autenticación de dos factores.
+func makeEnrollTwoFactorEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TwoFactorReq)
+
+		enrollment, err := s.EnrollTwoFactor(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return response.OK("success", enrollment), nil
+	}
+}
+
+// makeConfirmTwoFactorEndpoint crea un controlador para el endpoint de confirmación de la autenticación de dos factores.
+func makeConfirmTwoFactorEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TwoFactorReq)
+
+		codes, err := s.ConfirmTwoFactor(ctx, req.ID, req.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		// Los códigos de recuperación solo se muestran esta única vez.
+		return response.OK("two-factor authentication enabled", map[string]interface{}{
+			"recovery_codes": codes,
+		}), nil
+	}
+}
+
+// makeVerifyTwoFactorEndpoint crea un controlador para el endpoint de verificación del segundo factor.
+func makeVerifyTwoFactorEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TwoFactorReq)
+
+		if err := s.VerifyTwoFactor(ctx, req.ID, req.Code); err != nil {
+			return nil, err
+		}
+
+		return response.OK("two-factor code verified", nil), nil
+	}
+}
+
+// makeDisableTwoFactorEndpoint crea un controlador para el endpoint de desactivación de la autenticación de dos factores.
+func makeDisableTwoFactorEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TwoFactorReq)
+
+		if err := s.DisableTwoFactor(ctx, req.ID, req.Code); err != nil {
+			return nil, err
+		}
+
+		return response.OK("two-factor authentication disabled", nil), nil
+	}
+}
+
+// makeHistoryEndpoint crea un controlador para el endpoint del historial de cambios de un usuario.
+func makeHistoryEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(HistoryReq)
+
+		entries, err := s.History(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", entries), nil
+	}
+}
+
+// makeAuditEndpoint crea un controlador para el endpoint del log de auditoría.
+func makeAuditEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(AuditReq)
+
+		entries, err := s.Audit(ctx, AuditFilters{
+			UserID: req.UserID,
+			Actor:  req.Actor,
+			Action: req.Action,
+			From:   req.From,
+			To:     req.To,
+			Limit:  req.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", entries), nil
+	}
+}
+
+// validateUser valida que el nombre y el apellido no estén vacíos y que el correo, si no está vacío, sea una dirección
+// válida; un puntero nil indica que el campo no se envió. Devuelve un apierror.ValidationError con el error de cada
+// campo inválido.
+func validateUser(firstName, lastName, email *string) error {
+	var fields []apierror.FieldError
+	if firstName != nil && *firstName == "" {
+		fields = append(fields, apierror.FieldError{Pointer: "/first_name", Code: "first_name_required", Detail: ErrFirstNameRequired.Error()})
+	}
+	if lastName != nil && *lastName == "" {
+		fields = append(fields, apierror.FieldError{Pointer: "/last_name", Code: "last_name_required", Detail: ErrLastNameRequired.Error()})
+	}
+	if email != nil && !validEmail(*email) {
+		fields = append(fields, apierror.FieldError{Pointer: "/email", Code: "invalid_email", Detail: ErrInvalidEmail.Error()})
+	}
+
+	if len(fields) > 0 {
+		return apierror.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validEmail indica si el correo está vacío o es una única dirección sin nombre ni espacios (usuario@dominio). El
+// correo se usa como destinatario en los encabezados de los correos de verificación, por lo que no puede contener
+// saltos de línea ni otros caracteres que net/mail no acepte en una dirección.
+func validEmail(email string) bool {
+	if email == "" {
+		return true
+	}
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Name == "" && addr.Address == email
+}
+
 /*
 Capa de presentación (Controller):
 
@@ -3,6 +3,9 @@ package user
 import (
 	"errors"
 	"fmt"
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
 )
 
 // ErrFirstNameRequired se produce cuando se intenta crear un usuario sin proporcionar un nombre.
@@ -11,9 +14,36 @@ var ErrFirstNameRequired = errors.New("first name ir required")
 // ErrLastNameRequired se produce cuando se intenta crear un usuario sin proporcionar un apellido.
 var ErrLastNameRequired = errors.New("last name ir required")
 
+// ErrInvalidEmail se produce cuando el correo electrónico de un usuario no es una dirección válida.
+var ErrInvalidEmail = errors.New("email must be a valid address")
+
 // ErrThereArentFields se utiliza cuando no se proporcionan campos para actualizar en la función Update del repositorio de usuarios.
 var ErrThereArentFields = errors.New("there aren't fields")
 
+// ErrInvalidVerificationToken se produce cuando el token de verificación de correo no existe o ya no corresponde al correo del usuario.
+var ErrInvalidVerificationToken = errors.New("invalid verification token")
+
+// ErrVerificationTokenExpired se produce cuando el token de verificación de correo ya expiró.
+var ErrVerificationTokenExpired = errors.New("verification token expired")
+
+// ErrTwoFactorAlreadyEnabled se produce cuando se intenta inscribir en la autenticación de dos factores a un usuario que ya la tiene activada.
+var ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+// ErrTwoFactorNotEnrolled se produce cuando se intenta confirmar la autenticación de dos factores sin haber iniciado la inscripción.
+var ErrTwoFactorNotEnrolled = errors.New("two-factor authentication enrolment not started")
+
+// ErrTwoFactorNotEnabled se produce cuando se intenta verificar o desactivar la autenticación de dos factores de un usuario que no la tiene activada.
+var ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+
+// ErrInvalidTwoFactorCode se produce cuando el código de un solo uso o el código de recuperación no es válido.
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// ErrTwoFactorRequired se produce cuando se intenta modificar o eliminar un usuario con la autenticación de dos factores activada sin enviar el código del segundo factor.
+var ErrTwoFactorRequired = errors.New("two-factor code required")
+
+// ErrQueryTooComplex se produce cuando una operación de GraphQL tiene demasiados campos raíz o supera el costo máximo.
+var ErrQueryTooComplex = errors.New("query too complex")
+
 // ErrNotFound es una estructura de error personalizada que se utiliza cuando no se encuentra un usuario en la base de datos.
 type ErrNotFound struct {
 	ID uint64 // ID del usuario que no se encontró.
@@ -23,3 +53,21 @@ type ErrNotFound struct {
 func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("user id '%d' doesn`t exist", e.ID) // Retorna un mensaje de error formateado con el ID del usuario.
 }
+
+// init registra los errores de usuarios con su código de estado HTTP y su código de error estable, para que
+// encodeError los convierta en la respuesta correspondiente en todas las rutas.
+func init() {
+	apierror.Register(ErrFirstNameRequired, http.StatusBadRequest, "first_name_required")
+	apierror.Register(ErrLastNameRequired, http.StatusBadRequest, "last_name_required")
+	apierror.Register(ErrInvalidEmail, http.StatusBadRequest, "invalid_email")
+	apierror.Register(ErrThereArentFields, http.StatusBadRequest, "no_fields_to_update")
+	apierror.Register(ErrInvalidVerificationToken, http.StatusBadRequest, "invalid_verification_token")
+	apierror.Register(ErrVerificationTokenExpired, http.StatusBadRequest, "verification_token_expired")
+	apierror.Register(ErrTwoFactorAlreadyEnabled, http.StatusBadRequest, "two_factor_already_enabled")
+	apierror.Register(ErrTwoFactorNotEnrolled, http.StatusBadRequest, "two_factor_not_enrolled")
+	apierror.Register(ErrTwoFactorNotEnabled, http.StatusBadRequest, "two_factor_not_enabled")
+	apierror.Register(ErrInvalidTwoFactorCode, http.StatusUnauthorized, "invalid_two_factor_code")
+	apierror.Register(ErrTwoFactorRequired, http.StatusUnauthorized, "two_factor_required")
+	apierror.Register(ErrQueryTooComplex, http.StatusBadRequest, "query_too_complex")
+	apierror.RegisterType[ErrNotFound](http.StatusNotFound, "user_not_found")
+}
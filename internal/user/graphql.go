@@ -0,0 +1,506 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/dataloader"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const (
+	// defaultPageSize y maxPageSize son la cantidad predeterminada y máxima de usuarios por página de la conexión users.
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxFieldLength es la longitud máxima del nombre, el apellido y el correo, la de las columnas de la base de datos.
+	maxFieldLength = 45
+
+	// cursorPrefix es el prefijo del ID de usuario en los cursores, antes de codificarlos en base64.
+	cursorPrefix = "user:"
+)
+
+type (
+	// GraphQLReq: Define una estructura `GraphQLReq` para representar una solicitud de GraphQL.
+	GraphQLReq struct {
+		Query         string                 `json:"query"`         // Documento con la operación a ejecutar
+		OperationName string                 `json:"operationName"` // Nombre de la operación, si el documento tiene varias
+		Variables     map[string]interface{} `json:"variables"`     // Valores de las variables de la operación
+
+		parsed   bool          // Si Parse ya analizó la consulta
+		doc      *ast.Document // Documento analizado de Query
+		parseErr error         // Error de sintaxis de Query
+		op       operation     // Operación que ejecuta la solicitud (ver analyzeOperation)
+		opOK     bool          // Si se pudo determinar la operación que ejecuta la solicitud
+	}
+
+	// GraphQL ejecuta operaciones de GraphQL sobre el servicio de usuarios.
+	GraphQL interface {
+		// Execute ejecuta la operación de la solicitud y devuelve su resultado con el formato de GraphQL:
+		// los datos obtenidos y los errores de la operación.
+		Execute(ctx context.Context, req GraphQLReq) *graphql.Result
+
+		// Mutations devuelve la cantidad de mutaciones de la operación de la solicitud, contando cada alias por
+		// separado, o cero si es una consulta o no se puede analizar.
+		Mutations(req GraphQLReq) int
+
+		// TwoFactorTargets devuelve los IDs de los usuarios que modifican o eliminan las mutaciones de la operación
+		// de la solicitud, uno por mutación, para limitar los códigos de dos factores que se prueban para cada uno.
+		TwoFactorTargets(req GraphQLReq) []uint64
+	}
+
+	// graphQL es una implementación de la interfaz GraphQL.
+	graphQL struct {
+		schema      graphql.Schema // Esquema con las consultas y mutaciones de usuarios
+		service     Service        // Servicio de usuarios al que llaman los resolvers
+		batchMaxIDs int            // Cantidad máxima de IDs de cada llamada a GetMany del dataloader
+	}
+
+	// loaderKey es la clave del contexto con el dataloader de usuarios de la solicitud.
+	loaderKey struct{}
+)
+
+// NewGraphQL crea el esquema de GraphQL de usuarios: la consulta user(id), la conexión paginada users y las
+// mutaciones createUser, updateUser y deleteUser. Los resolvers llaman al servicio; las consultas de usuarios por ID
+// de una misma operación se agrupan con un dataloader en llamadas a GetMany de hasta cfg.BatchMaxIDs IDs, el mismo
+// límite que el de la obtención por lotes.
+func NewGraphQL(s Service, cfg Config) (GraphQL, error) {
+	g := &graphQL{service: s, batchMaxIDs: cfg.BatchMaxIDs}
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "Usuario del servicio.",
+		Fields: graphql.Fields{
+			"id":               {Type: graphql.NewNonNull(graphql.ID), Resolve: userField(func(u *domain.User) interface{} { return strconv.FormatUint(u.ID, 10) })},
+			"firstName":        {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *domain.User) interface{} { return u.FirstName })},
+			"lastName":         {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *domain.User) interface{} { return u.LastName })},
+			"email":            {Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(u *domain.User) interface{} { return u.Email })},
+			"emailVerified":    {Type: graphql.NewNonNull(graphql.Boolean), Resolve: userField(func(u *domain.User) interface{} { return u.EmailVerified })},
+			"twoFactorEnabled": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: userField(func(u *domain.User) interface{} { return u.TwoFactorEnabled })},
+		},
+	})
+
+	// La conexión sigue la especificación de conexiones de Relay: cada arista tiene el usuario y su cursor.
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": {Type: graphql.NewNonNull(graphql.String)},
+			"node":   {Type: graphql.NewNonNull(userType)},
+		},
+	})
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": {Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   {Type: graphql.String},
+		},
+	})
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"pageInfo": {Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": {
+				Type:        userType,
+				Description: "Obtiene un usuario por su ID, o null si no existe.",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: g.resolveUser,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(connectionType),
+				Description: "Obtiene los usuarios ordenados por ID, de a una página.",
+				Args: graphql.FieldConfigArgument{
+					"first":         {Type: graphql.Int, DefaultValue: defaultPageSize, Description: fmt.Sprintf("Cantidad de usuarios de la página (máximo %d).", maxPageSize)},
+					"after":         {Type: graphql.String, Description: "Cursor del último usuario de la página anterior."},
+					"emailVerified": {Type: graphql.Boolean, Description: "Filtra los usuarios según hayan verificado o no su correo."},
+				},
+				Resolve: g.resolveUsers,
+			},
+		},
+	})
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateUserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"firstName": {Type: graphql.NewNonNull(graphql.String)},
+			"lastName":  {Type: graphql.NewNonNull(graphql.String)},
+			"email":     {Type: graphql.String},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateUserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"firstName": {Type: graphql.String},
+			"lastName":  {Type: graphql.String},
+			"email":     {Type: graphql.String},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type:        graphql.NewNonNull(userType),
+				Description: "Crea un usuario y le envía el correo de verificación.",
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(createInput)},
+				},
+				Resolve: g.resolveCreateUser,
+			},
+			"updateUser": {
+				Type:        graphql.NewNonNull(userType),
+				Description: "Modifica los atributos enviados de un usuario y devuelve el usuario modificado.",
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: g.resolveUpdateUser,
+			},
+			"deleteUser": {
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Elimina un usuario.",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: g.resolveDeleteUser,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		return nil, err
+	}
+	g.schema = schema
+	return g, nil
+}
+
+// Execute ejecuta la operación con un dataloader de usuarios propio de la solicitud, de modo que los usuarios
+// cargados no se comparten entre solicitudes. Las operaciones con más de maxRootFields campos raíz o con un costo
+// mayor que maxComplexity no se ejecutan: se responden con un error que no corresponde a ningún campo.
+func (g *graphQL) Execute(ctx context.Context, req GraphQLReq) *graphql.Result {
+	req.Parse()
+	if req.parseErr != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(req.parseErr)}
+	}
+	if req.opOK {
+		if err := checkOperation(req.op); err != nil {
+			e := graphQLError(err).(gqlError)
+			return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+				Message:    e.Error(),
+				Locations:  []location.SourceLocation{},
+				Extensions: e.Extensions(),
+			}}}
+		}
+	}
+
+	loader := dataloader.New(g.batchMaxIDs, func(ctx context.Context, ids []uint64) (map[uint64]*domain.User, error) {
+		users, err := g.service.GetMany(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[uint64]*domain.User, len(users))
+		for i := range users {
+			byID[users[i].ID] = &users[i]
+		}
+		return byID, nil
+	})
+
+	// Se valida y ejecuta el documento ya analizado, con los mismos pasos que graphql.Do, que lo volvería a analizar.
+	if validation := graphql.ValidateDocument(&g.schema, req.doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        g.schema,
+		AST:           req.doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(ctx, loaderKey{}, loader),
+	})
+}
+
+// Parse analiza la consulta de la solicitud y la operación que ejecuta, una sola vez: las copias de la solicitud
+// analizada comparten el resultado, de modo que Mutations, TwoFactorTargets y Execute no vuelven a analizarla. Las
+// solicitudes que no se analizaron antes se analizan al usarlas.
+func (r *GraphQLReq) Parse() {
+	if r.parsed {
+		return
+	}
+	r.parsed = true
+	r.doc, r.parseErr = parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(r.Query),
+		Name: "GraphQL request",
+	})})
+	if r.parseErr == nil {
+		r.op, r.opOK = analyzeDocument(r.doc, *r)
+	}
+}
+
+// Mutations devuelve la cantidad de mutaciones de la operación de la solicitud, contando cada alias por separado, o
+// cero si es una consulta o no se puede analizar.
+func (g *graphQL) Mutations(req GraphQLReq) int {
+	req.Parse()
+	op, ok := req.op, req.opOK
+	if !ok || !op.mutation {
+		return 0
+	}
+	return op.rootFields
+}
+
+// TwoFactorTargets devuelve los IDs de los usuarios que modifican o eliminan las mutaciones updateUser y deleteUser
+// de la operación de la solicitud, uno por mutación, o ninguno si es una consulta o no se puede analizar.
+func (g *graphQL) TwoFactorTargets(req GraphQLReq) []uint64 {
+	req.Parse()
+	if !req.opOK {
+		return nil
+	}
+	return req.op.targets
+}
+
+// resolveUser agrega el ID al lote del dataloader y devuelve una función que GraphQL llama después de resolver los
+// demás campos del mismo nivel, cuando todos los IDs de la operación ya están en el lote.
+func (g *graphQL) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	loader, ok := p.Context.Value(loaderKey{}).(*dataloader.Loader[uint64, *domain.User])
+	if !ok {
+		return nil, graphQLError(fmt.Errorf("user loader not found in context"))
+	}
+	load := loader.Load(p.Context, id)
+	return func() (interface{}, error) {
+		u, err := load()
+		if err != nil {
+			return nil, graphQLError(err)
+		}
+		if u == nil {
+			return nil, nil
+		}
+		return u, nil
+	}, nil
+}
+
+// resolveUsers obtiene una página de usuarios a partir del cursor. Se pide un usuario más que el tamaño de la página
+// para saber si hay una página siguiente sin contar todos los usuarios.
+func (g *graphQL) resolveUsers(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, graphQLError(response.BadRequest(fmt.Sprintf("first must be between 1 and %d", maxPageSize)))
+	}
+
+	filters := Filters{Limit: first + 1}
+	if after, ok := p.Args["after"].(string); ok {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return nil, graphQLError(err)
+		}
+		filters.AfterID = id
+	}
+	if verified, ok := p.Args["emailVerified"].(bool); ok {
+		filters.EmailVerified = &verified
+	}
+
+	users, err := g.service.GetAll(p.Context, filters)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	hasNextPage := len(users) > first
+	if hasNextPage {
+		users = users[:first]
+	}
+
+	edges := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		edges = append(edges, map[string]interface{}{"cursor": encodeCursor(users[i].ID), "node": &users[i]})
+	}
+	pageInfo := map[string]interface{}{"hasNextPage": hasNextPage, "endCursor": nil}
+	if len(users) > 0 {
+		pageInfo["endCursor"] = encodeCursor(users[len(users)-1].ID)
+	}
+	return map[string]interface{}{"edges": edges, "pageInfo": pageInfo}, nil
+}
+
+// resolveCreateUser crea un usuario con los datos del input.
+func (g *graphQL) resolveCreateUser(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	firstName, lastName, email := inputString(input, "firstName"), inputString(input, "lastName"), inputString(input, "email")
+	if err := validateInput(firstName, lastName, email); err != nil {
+		return nil, graphQLError(err)
+	}
+
+	var e string
+	if email != nil {
+		e = *email
+	}
+	u, err := g.service.Create(p.Context, *firstName, *lastName, e)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	return u, nil
+}
+
+// resolveUpdateUser modifica los atributos enviados en el input; los omitidos o nulos no se modifican.
+// El usuario modificado se obtiene del servicio y no del dataloader, para no devolver un valor anterior al cambio.
+func (g *graphQL) resolveUpdateUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+	firstName, lastName, email := inputString(input, "firstName"), inputString(input, "lastName"), inputString(input, "email")
+	if err := validateInput(firstName, lastName, email); err != nil {
+		return nil, graphQLError(err)
+	}
+
+	if err := g.service.Update(p.Context, id, firstName, lastName, email); err != nil {
+		return nil, graphQLError(err)
+	}
+	u, err := g.service.Get(p.Context, id)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	return u, nil
+}
+
+// resolveDeleteUser elimina un usuario y devuelve true.
+func (g *graphQL) resolveDeleteUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	if _, err := g.service.Delete(p.Context, id); err != nil {
+		return nil, graphQLError(err)
+	}
+	return true, nil
+}
+
+// userField devuelve el resolver de un campo del tipo User a partir de la función que obtiene su valor.
+func userField(value func(u *domain.User) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		u, ok := p.Source.(*domain.User)
+		if !ok {
+			return nil, nil
+		}
+		return value(u), nil
+	}
+}
+
+// inputString devuelve el valor de un campo de texto del input, o nil si se omitió o es nulo.
+func inputString(input map[string]interface{}, name string) *string {
+	v, ok := input[name].(string)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// validateInput valida los campos de los inputs de creación y modificación: el nombre y el apellido, si se envían, no
+// pueden estar vacíos, el correo debe ser una dirección válida y ningún campo puede superar la longitud de su columna
+// en la base de datos. Devuelve un apierror.ValidationError que indica cada campo con un JSON Pointer sobre el input.
+func validateInput(firstName, lastName, email *string) error {
+	var fields []apierror.FieldError
+	if firstName != nil && *firstName == "" {
+		fields = append(fields, apierror.FieldError{Pointer: "/input/firstName", Code: "first_name_required", Detail: ErrFirstNameRequired.Error()})
+	}
+	if lastName != nil && *lastName == "" {
+		fields = append(fields, apierror.FieldError{Pointer: "/input/lastName", Code: "last_name_required", Detail: ErrLastNameRequired.Error()})
+	}
+	if email != nil && !validEmail(*email) {
+		fields = append(fields, apierror.FieldError{Pointer: "/input/email", Code: "invalid_email", Detail: ErrInvalidEmail.Error()})
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{{"firstName", firstName}, {"lastName", lastName}, {"email", email}} {
+		if f.value != nil && utf8.RuneCountInString(*f.value) > maxFieldLength {
+			fields = append(fields, apierror.FieldError{
+				Pointer: "/input/" + f.name,
+				Code:    "too_long",
+				Detail:  fmt.Sprintf("%s can't be longer than %d characters", f.name, maxFieldLength),
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return apierror.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// parseID convierte el argumento de tipo ID en el ID del usuario.
+func parseID(arg interface{}) (uint64, error) {
+	s, _ := arg.(string)
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, response.BadRequest(fmt.Sprintf("invalid user id '%s'", s))
+	}
+	return id, nil
+}
+
+// encodeCursor devuelve el cursor opaco que apunta al usuario con el ID indicado.
+func encodeCursor(id uint64) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(id, 10)))
+}
+
+// decodeCursor devuelve el ID del usuario al que apunta el cursor.
+func decodeCursor(cursor string) (uint64, error) {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(b), cursorPrefix) {
+		if id, err := strconv.ParseUint(strings.TrimPrefix(string(b), cursorPrefix), 10, 64); err == nil {
+			return id, nil
+		}
+	}
+	return 0, response.BadRequest(fmt.Sprintf("invalid cursor '%s'", cursor))
+}
+
+// gqlError es un error de GraphQL con el código de estado, el código de error estable y los errores de los campos
+// del registro de apierror como extensiones, del mismo modo que las respuestas de error de la API HTTP.
+type gqlError struct {
+	problem *apierror.Problem
+}
+
+// graphQLError convierte un error del servicio en un error de GraphQL según el registro de apierror.
+func graphQLError(err error) error {
+	return gqlError{problem: apierror.NewProblem(err, "", "")}
+}
+
+// Error devuelve la descripción del error.
+func (e gqlError) Error() string {
+	return e.problem.Detail
+}
+
+// Extensions devuelve las extensiones del error: status, code y, si la solicitud tiene errores en sus campos, fields.
+func (e gqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"status": e.problem.Status}
+	if e.problem.Code != "" {
+		ext["code"] = e.problem.Code
+	}
+	if len(e.problem.Errors) > 0 {
+		ext["fields"] = e.problem.Errors
+	}
+	return ext
+}
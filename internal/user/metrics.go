@@ -0,0 +1,150 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+)
+
+// instrumentedRepo es un decorador de Repository que mide la duración de cada método.
+type instrumentedRepo struct {
+	next    Repository
+	observe func(method string, d time.Duration, err error)
+}
+
+// NewInstrumentedRepo devuelve un Repository que delega en next y registra la duración de cada llamada mediante observe.
+func NewInstrumentedRepo(next Repository, observe func(method string, d time.Duration, err error)) Repository {
+	return &instrumentedRepo{
+		next:    next,
+		observe: observe,
+	}
+}
+
+// WithTx mide la duración total de la transacción; el repositorio de la transacción también se instrumenta.
+func (r *instrumentedRepo) WithTx(ctx context.Context, fn func(tx Repository) error) (err error) {
+	defer r.measure("WithTx", time.Now(), &err)
+	return r.next.WithTx(ctx, func(tx Repository) error {
+		return fn(&instrumentedRepo{next: tx, observe: r.observe})
+	})
+}
+
+// Create mide la duración de Repository.Create.
+func (r *instrumentedRepo) Create(ctx context.Context, user *domain.User) (err error) {
+	defer r.measure("Create", time.Now(), &err)
+	return r.next.Create(ctx, user)
+}
+
+// GetAll mide la duración de Repository.GetAll.
+func (r *instrumentedRepo) GetAll(ctx context.Context, filters Filters) (users []domain.User, err error) {
+	defer r.measure("GetAll", time.Now(), &err)
+	return r.next.GetAll(ctx, filters)
+}
+
+// Get mide la duración de Repository.Get.
+func (r *instrumentedRepo) Get(ctx context.Context, id uint64) (user *domain.User, err error) {
+	defer r.measure("Get", time.Now(), &err)
+	return r.next.Get(ctx, id)
+}
+
+// GetMany mide la duración de Repository.GetMany.
+func (r *instrumentedRepo) GetMany(ctx context.Context, ids []uint64) (users []domain.User, err error) {
+	defer r.measure("GetMany", time.Now(), &err)
+	return r.next.GetMany(ctx, ids)
+}
+
+// Search mide la duración de Repository.Search.
+func (r *instrumentedRepo) Search(ctx context.Context, terms []string, fn func(domain.User)) (err error) {
+	defer r.measure("Search", time.Now(), &err)
+	return r.next.Search(ctx, terms, fn)
+}
+
+// BackfillSearchText mide la duración de Repository.BackfillSearchText.
+func (r *instrumentedRepo) BackfillSearchText(ctx context.Context) (n int, err error) {
+	defer r.measure("BackfillSearchText", time.Now(), &err)
+	return r.next.BackfillSearchText(ctx)
+}
+
+// Update mide la duración de Repository.Update.
+func (r *instrumentedRepo) Update(ctx context.Context, id uint64, firstName, lastName, email *string) (err error) {
+	defer r.measure("Update", time.Now(), &err)
+	return r.next.Update(ctx, id, firstName, lastName, email)
+}
+
+// Delete mide la duración de Repository.Delete.
+func (r *instrumentedRepo) Delete(ctx context.Context, id uint64) (user *domain.User, err error) {
+	defer r.measure("Delete", time.Now(), &err)
+	return r.next.Delete(ctx, id)
+}
+
+// SaveVerificationToken mide la duración de Repository.SaveVerificationToken.
+func (r *instrumentedRepo) SaveVerificationToken(ctx context.Context, userID uint64, email, tokenHash string, expiresAt time.Time) (err error) {
+	defer r.measure("SaveVerificationToken", time.Now(), &err)
+	return r.next.SaveVerificationToken(ctx, userID, email, tokenHash, expiresAt)
+}
+
+// VerifyEmail mide la duración de Repository.VerifyEmail.
+func (r *instrumentedRepo) VerifyEmail(ctx context.Context, tokenHash string) (id uint64, err error) {
+	defer r.measure("VerifyEmail", time.Now(), &err)
+	return r.next.VerifyEmail(ctx, tokenHash)
+}
+
+// GetTOTP mide la duración de Repository.GetTOTP.
+func (r *instrumentedRepo) GetTOTP(ctx context.Context, id uint64) (secret string, enabled bool, err error) {
+	defer r.measure("GetTOTP", time.Now(), &err)
+	return r.next.GetTOTP(ctx, id)
+}
+
+// SetTOTPSecret mide la duración de Repository.SetTOTPSecret.
+func (r *instrumentedRepo) SetTOTPSecret(ctx context.Context, id uint64, secret string) (err error) {
+	defer r.measure("SetTOTPSecret", time.Now(), &err)
+	return r.next.SetTOTPSecret(ctx, id, secret)
+}
+
+// EnableTOTP mide la duración de Repository.EnableTOTP.
+func (r *instrumentedRepo) EnableTOTP(ctx context.Context, id uint64, step uint64, recoveryCodeHashes []string) (err error) {
+	defer r.measure("EnableTOTP", time.Now(), &err)
+	return r.next.EnableTOTP(ctx, id, step, recoveryCodeHashes)
+}
+
+// DisableTOTP mide la duración de Repository.DisableTOTP.
+func (r *instrumentedRepo) DisableTOTP(ctx context.Context, id uint64) (err error) {
+	defer r.measure("DisableTOTP", time.Now(), &err)
+	return r.next.DisableTOTP(ctx, id)
+}
+
+// UseTOTPStep mide la duración de Repository.UseTOTPStep.
+func (r *instrumentedRepo) UseTOTPStep(ctx context.Context, id uint64, step uint64) (ok bool, err error) {
+	defer r.measure("UseTOTPStep", time.Now(), &err)
+	return r.next.UseTOTPStep(ctx, id, step)
+}
+
+// UseRecoveryCode mide la duración de Repository.UseRecoveryCode.
+func (r *instrumentedRepo) UseRecoveryCode(ctx context.Context, id uint64, codeHash string) (ok bool, err error) {
+	defer r.measure("UseRecoveryCode", time.Now(), &err)
+	return r.next.UseRecoveryCode(ctx, id, codeHash)
+}
+
+// SaveAudit mide la duración de Repository.SaveAudit.
+func (r *instrumentedRepo) SaveAudit(ctx context.Context, entry *domain.AuditEntry) (err error) {
+	defer r.measure("SaveAudit", time.Now(), &err)
+	return r.next.SaveAudit(ctx, entry)
+}
+
+// GetAudit mide la duración de Repository.GetAudit.
+func (r *instrumentedRepo) GetAudit(ctx context.Context, filters AuditFilters) (entries []domain.AuditEntry, err error) {
+	defer r.measure("GetAudit", time.Now(), &err)
+	return r.next.GetAudit(ctx, filters)
+}
+
+// SaveEvent mide la duración de Repository.SaveEvent.
+func (r *instrumentedRepo) SaveEvent(ctx context.Context, event *outbox.Event) (err error) {
+	defer r.measure("SaveEvent", time.Now(), &err)
+	return r.next.SaveEvent(ctx, event)
+}
+
+// measure registra la duración de la llamada desde start y su error.
+func (r *instrumentedRepo) measure(method string, start time.Time, err *error) {
+	r.observe(method, time.Since(start), *err)
+}
@@ -3,11 +3,35 @@ package user
 import (
 	"context" // Paquete `context`: Proporciona un objeto de contexto que lleva información del ámbito de la solicitud.
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log" // Paquete `log`: Proporciona funciones para registrar mensajes.
+	"log/slog" // Paquete `log/slog`: Proporciona el logger estructurado con niveles.
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain" // Paquete `internal/domain`: Proporciona la estructura `User` utilizada para representar datos de usuario.
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// fullTextMinTokenSize es la longitud mínima de las palabras que indexa FULLTEXT en InnoDB
+	// (innodb_ft_min_token_size); las búsquedas con palabras más cortas usan LIKE.
+	fullTextMinTokenSize = 3
+
+	// maxSearchCandidates es la cantidad máxima de coincidencias que Search lee de la base de datos para ordenarlas
+	// por relevancia, de modo que una búsqueda muy general no recorra toda la tabla ni la cargue en memoria.
+	maxSearchCandidates = 1000
+
+	// searchBackfillBatch es la cantidad de usuarios que BackfillSearchText actualiza en cada consulta.
+	searchBackfillBatch = 500
+
+	// errNoFullTextIndex es el código de error de MySQL que indica que no hay un índice FULLTEXT para las columnas
+	// de MATCH (ER_FT_MATCHING_KEY_NOT_FOUND).
+	errNoFullTextIndex = 1191
 )
 
 /*// DB estructura que contiene los datos de usuario y un contador para el ID máximo.
@@ -16,32 +40,144 @@ type DB struct {
 	MaxUserID uint64        // ID máximo para generar IDs automáticos: Un entero sin signo de 64 bits para mantener un registro del ID de usuario máximo para la generación automática de ID.
 }*/
 
+// Filters agrupa los filtros disponibles al listar usuarios.
+type Filters struct {
+	EmailVerified *bool  // Si no es nil, filtra los usuarios según hayan verificado o no su correo.
+	AfterID       uint64 // Si no es cero, devuelve solo los usuarios con un ID mayor, para paginar por cursor.
+	Limit         int    // Si es mayor que cero, cantidad máxima de usuarios a devolver, ordenados por ID.
+}
+
+// AuditFilters agrupa los filtros disponibles al consultar el log de auditoría.
+type AuditFilters struct {
+	UserID *uint64    // Si no es nil, filtra los registros del usuario indicado.
+	Actor  string     // Si no está vacío, filtra los registros realizados por el actor indicado.
+	Action string     // Si no está vacío, filtra los registros de la acción indicada.
+	From   *time.Time // Si no es nil, filtra los registros realizados a partir de esa fecha.
+	To     *time.Time // Si no es nil, filtra los registros realizados hasta esa fecha.
+	Limit  int        // Cantidad máxima de registros a devolver.
+}
+
 // Repository define las operaciones básicas que debe implementar un repositorio de usuarios.
 type Repository interface {
+	// WithTx ejecuta fn dentro de una transacción, pasándole un repositorio que opera sobre ella.
+	// Si fn devuelve un error la transacción se revierte; en caso contrario se confirma.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
 	// Create crea un nuevo usuario en la base de datos.
 	Create(ctx context.Context, user *domain.User) error
-	// GetAll devuelve todos los usuarios almacenados en la base de datos.
-	GetAll(ctx context.Context) ([]domain.User, error)
+	// GetAll devuelve todos los usuarios almacenados en la base de datos que cumplen con los filtros.
+	GetAll(ctx context.Context, filters Filters) ([]domain.User, error)
 	// Get devuelve un usuario específico basado en su ID.
 	Get(ctx context.Context, id uint64) (*domain.User, error)
+	// GetMany devuelve los usuarios con los IDs indicados en una sola consulta, ordenados por ID. Los IDs que no
+	// existen se omiten.
+	GetMany(ctx context.Context, ids []uint64) ([]domain.User, error)
+	// Search llama a fn con cada usuario en cuyo nombre, apellido o correo cada término es el comienzo de alguna
+	// palabra, sin un orden determinado. Los términos deben estar divididos y normalizados con searchTerms. El
+	// servicio ordena las coincidencias por relevancia y las resalta; fn no debe usar el repositorio.
+	Search(ctx context.Context, terms []string, fn func(domain.User)) error
+	// BackfillSearchText calcula el texto de búsqueda de los usuarios que no lo tienen y devuelve cuántos actualizó.
+	BackfillSearchText(ctx context.Context) (int, error)
 	// Update actualiza los datos de un usuario existente.
 	Update(ctx context.Context, id uint64, firstName, lastName, email *string) error
 	// Elimina un usuario específico basado en su ID.
 	Delete(ctx context.Context, id uint64) (*domain.User, error)
+	// SaveVerificationToken guarda el token de verificación de correo de un usuario, reemplazando el anterior si existe.
+	SaveVerificationToken(ctx context.Context, userID uint64, email, tokenHash string, expiresAt time.Time) error
+	// VerifyEmail marca como verificado el correo asociado al token y devuelve el ID del usuario.
+	VerifyEmail(ctx context.Context, tokenHash string) (uint64, error)
+	// GetTOTP devuelve el secreto TOTP de un usuario y si la autenticación de dos factores está activada.
+	GetTOTP(ctx context.Context, id uint64) (secret string, enabled bool, err error)
+	// SetTOTPSecret guarda un secreto TOTP pendiente de confirmación para un usuario. Si la autenticación de dos
+	// factores ya está activada devuelve ErrTwoFactorAlreadyEnabled sin reemplazar el secreto.
+	SetTOTPSecret(ctx context.Context, id uint64, secret string) error
+	// EnableTOTP activa la autenticación de dos factores de un usuario, registra step como el último período TOTP
+	// utilizado y reemplaza sus códigos de recuperación, en una transacción. Si ya estaba activada devuelve
+	// ErrTwoFactorAlreadyEnabled.
+	EnableTOTP(ctx context.Context, id uint64, step uint64, recoveryCodeHashes []string) error
+	// DisableTOTP desactiva la autenticación de dos factores de un usuario y elimina sus códigos de recuperación, en
+	// una transacción.
+	DisableTOTP(ctx context.Context, id uint64) error
+	// UseTOTPStep registra step como el último período TOTP utilizado por un usuario con la autenticación de dos
+	// factores activada e indica si es posterior al anterior, es decir, si el código no se había utilizado.
+	UseTOTPStep(ctx context.Context, id uint64, step uint64) (bool, error)
+	// UseRecoveryCode consume un código de recuperación de un usuario e indica si era válido.
+	UseRecoveryCode(ctx context.Context, id uint64, codeHash string) (bool, error)
+	// SaveAudit guarda un registro en el log de auditoría.
+	SaveAudit(ctx context.Context, entry *domain.AuditEntry) error
+	// GetAudit devuelve los registros del log de auditoría que cumplen con los filtros, del más reciente al más antiguo.
+	GetAudit(ctx context.Context, filters AuditFilters) ([]domain.AuditEntry, error)
+	// SaveEvent guarda un evento de dominio en el outbox para que sea entregado en segundo plano.
+	SaveEvent(ctx context.Context, event *outbox.Event) error
+}
+
+// querier agrupa los métodos comunes a *sql.DB y *sql.Tx, para que el repositorio pueda operar sobre ambos.
+// Todos reciben el contexto, de modo que la cancelación de la solicitud o el vencimiento del plazo interrumpen la consulta.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // repo es una implementación de la interfaz Repository.
 type repo struct {
-	db  *sql.DB     // Base de datos de usuarios
-	log *log.Logger // Logger para registrar eventos
+	pool       *sql.DB       // Pool de conexiones a la base de datos, utilizado para iniciar transacciones
+	db         querier       // Base de datos de usuarios o transacción en curso
+	log        *slog.Logger  // Logger estructurado para registrar eventos
+	timeout    time.Duration // Tiempo máximo de cada operación; cero indica sin límite propio
+	noFullText *atomic.Bool  // Se activa si la base de datos no tiene el índice FULLTEXT, para buscar siempre con LIKE
 }
 
 // NewRepo es una función constructora que devuelve una nueva instancia del repositorio.
-func NewRepo(db *sql.DB, l *log.Logger) Repository {
+// timeout es el tiempo máximo de cada operación, además del plazo que ya tenga el contexto de la solicitud.
+func NewRepo(db *sql.DB, l *slog.Logger, timeout time.Duration) Repository {
 	return &repo{
-		db:  db,
-		log: l,
+		pool:       db,
+		db:         db,
+		log:        l,
+		timeout:    timeout,
+		noFullText: &atomic.Bool{},
+	}
+}
+
+// withTimeout devuelve un contexto derivado de ctx que vence al cumplirse el tiempo máximo de la operación.
+func (r *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// WithTx ejecuta fn dentro de una transacción, pasándole un repositorio que opera sobre ella.
+// Si el repositorio ya opera sobre una transacción, fn se ejecuta dentro de la misma.
+func (r *repo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.inTx(ctx, func(tx *repo) error { return fn(tx) })
+}
+
+// inTx es como WithTx, pero le pasa a fn el repositorio concreto, para que los métodos que ejecutan varias
+// sentencias puedan hacerlo de forma atómica.
+func (r *repo) inTx(ctx context.Context, fn func(tx *repo) error) error {
+	if _, ok := r.db.(*sql.Tx); ok {
+		return fn(r)
 	}
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "WithTx", "error", err)
+		return err
+	}
+
+	if err := fn(&repo{pool: r.pool, db: tx, log: r.log, timeout: r.timeout, noFullText: r.noFullText}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			r.log.ErrorContext(ctx, "transaction rollback failed", "error", rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "WithTx", "error", err)
+		return err
+	}
+	return nil
 }
 
 /*
@@ -53,35 +189,67 @@ func NewRepo(db *sql.DB, l *log.Logger) Repository {
 */
 // Create crea un nuevo usuario en la base de datos.
 func (r *repo) Create(ctx context.Context, user *domain.User) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Query SQL para insertar un nuevo usuario en la base de datos.
-	sqlQ := "INSERT INTO users(first_name, last_name, email) VALUES(?,?,?)"
+	// search_text guarda el texto de búsqueda del usuario (ver searchText).
+	sqlQ := "INSERT INTO users(first_name, last_name, email, email_verified, search_text) VALUES(?,?,?,?,?)"
 	// Ejecutar la consulta SQL y obtener el resultado.
-	res, err := r.db.Exec(sqlQ, user.FirstName, user.LastName, user.Email)
+	res, err := r.db.ExecContext(ctx, sqlQ, user.FirstName, user.LastName, user.Email, user.EmailVerified, searchText(user.FirstName, user.LastName, user.Email))
 	if err != nil {
 		// Si ocurre un error al ejecutar la consulta, registrar el error y devolverlo.
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Create", "error", err)
 		return err
 	}
 	// Obtener el ID del usuario recién creado.
 	id, err := res.LastInsertId()
 	if err != nil {
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Create", "error", err)
 		return err
 	}
 	// Asignar el ID al usuario y registrar el éxito en el log.
 	user.ID = uint64(id)
-	r.log.Println("user created with id: ", id)
+	r.log.DebugContext(ctx, "user created", "user_id", id)
 	return nil
 }
 
-// GetAll devuelve todos los usuarios almacenados en la base de datos.
-func (r *repo) GetAll(ctx context.Context) ([]domain.User, error) {
+// GetAll devuelve todos los usuarios almacenados en la base de datos que cumplen con los filtros.
+func (r *repo) GetAll(ctx context.Context, filters Filters) ([]domain.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Consulta SQL para obtener todos los usuarios.
-	sqlQ := "SELECT id, first_name, last_name, email FROM users"
+	sqlQ := "SELECT id, first_name, last_name, email, email_verified, totp_enabled FROM users"
+
+	// Agregar las condiciones de los filtros proporcionados.
+	var conditions []string
+	var values []interface{}
+	if filters.EmailVerified != nil {
+		conditions = append(conditions, "email_verified=?")
+		values = append(values, *filters.EmailVerified)
+	}
+	if filters.AfterID > 0 {
+		conditions = append(conditions, "id>?")
+		values = append(values, filters.AfterID)
+	}
+	if len(conditions) > 0 {
+		sqlQ = fmt.Sprintf("%s WHERE %s", sqlQ, strings.Join(conditions, " AND "))
+	}
+
+	// Al paginar, los usuarios se ordenan por ID para que el cursor (el último ID devuelto) sea estable.
+	if filters.AfterID > 0 || filters.Limit > 0 {
+		sqlQ += " ORDER BY id"
+	}
+	if filters.Limit > 0 {
+		sqlQ += " LIMIT ?"
+		values = append(values, filters.Limit)
+	}
+
 	// Ejecutar la consulta SQL.
-	rows, err := r.db.Query(sqlQ)
+	rows, err := r.db.QueryContext(ctx, sqlQ, values...)
 	if err != nil {
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "GetAll", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -90,15 +258,21 @@ func (r *repo) GetAll(ctx context.Context) ([]domain.User, error) {
 	var users []domain.User
 	for rows.Next() {
 		var u domain.User
-		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email); err != nil {
-			r.log.Println(err.Error())
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.EmailVerified, &u.TwoFactorEnabled); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "GetAll", "error", err)
 			return nil, err
 		}
 		users = append(users, u)
 	}
+	// Un error al leer las filas (por ejemplo, al vencer el tiempo máximo de la consulta) termina el bucle como si no
+	// hubiera más filas; sin esta verificación, se devolvería una página incompleta.
+	if err := rows.Err(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "GetAll", "error", err)
+		return nil, err
+	}
 
 	// Registrar la cantidad de usuarios obtenidos en el log y devolver el slice de usuarios.
-	r.log.Println("user get all: ", len(users))
+	r.log.DebugContext(ctx, "users listed", "count", len(users))
 	return users, nil
 }
 
@@ -116,24 +290,224 @@ if index < 0 {
 r.log.Println("repository get") // Registrar en el logger que se ha obtenido un usuario*/
 // Get devuelve un usuario específico basado en su ID.
 func (r *repo) Get(ctx context.Context, id uint64) (*domain.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Consulta SQL para obtener un usuario por su ID.
-	sqlQ := "SELECT id, first_name, last_name, email FROM users WHERE id = ?"
+	sqlQ := "SELECT id, first_name, last_name, email, email_verified, totp_enabled FROM users WHERE id = ?"
 	// Variables para almacenar el usuario y los posibles errores.
 	var u domain.User
 	// Ejecutar la consulta SQL y escanear el resultado en la estructura del usuario.
-	if err := r.db.QueryRow(sqlQ, id).Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email); err != nil {
+	if err := r.db.QueryRowContext(ctx, sqlQ, id).Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.EmailVerified, &u.TwoFactorEnabled); err != nil {
 		// Si no se encuentra el usuario, devolver un error NotFound.
-		r.log.Println(err.Error())
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound{id}
 		}
+		r.log.ErrorContext(ctx, "query failed", "method", "Get", "error", err)
 		return nil, err
 	}
 	// Registrar el éxito en el log y devolver el usuario.
-	r.log.Println("get user with id: ", id)
+	r.log.DebugContext(ctx, "user fetched", "user_id", id)
 	return &u, nil
 }
 
+// GetMany devuelve los usuarios con los IDs indicados en una sola consulta (WHERE id IN (...)), ordenados por ID.
+// Los IDs que no existen se omiten, y los repetidos se consultan una sola vez.
+func (r *repo) GetMany(ctx context.Context, ids []uint64) ([]domain.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// Sin IDs no hay nada que consultar.
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Agregar un parámetro por cada ID distinto.
+	seen := make(map[uint64]bool, len(ids))
+	placeholders := make([]string, 0, len(ids))
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		placeholders = append(placeholders, "?")
+		values = append(values, id)
+	}
+
+	// Ejecutar la consulta SQL.
+	sqlQ := fmt.Sprintf("SELECT id, first_name, last_name, email, email_verified, totp_enabled FROM users WHERE id IN (%s) ORDER BY id", strings.Join(placeholders, ","))
+	rows, err := r.db.QueryContext(ctx, sqlQ, values...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "GetMany", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Iterar sobre los resultados y almacenar los usuarios en un slice.
+	users := make([]domain.User, 0, len(values))
+	for rows.Next() {
+		var u domain.User
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.EmailVerified, &u.TwoFactorEnabled); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "GetMany", "error", err)
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "GetMany", "error", err)
+		return nil, err
+	}
+
+	r.log.DebugContext(ctx, "users fetched", "requested", len(values), "found", len(users))
+	return users, nil
+}
+
+// Search busca los usuarios cuyo texto de búsqueda (la columna search_text, ver searchText) tiene, para cada término,
+// una palabra que empieza con él, y llama a fn con cada uno a medida que los lee. Los términos de al menos
+// fullTextMinTokenSize caracteres se buscan con el índice FULLTEXT de la columna y los más cortos, que el índice no
+// contiene, con LIKE; si la base de datos no tiene el índice, todos se buscan con LIKE. Las dos formas encuentran los
+// mismos usuarios. La consulta no se limita: el servicio ordena todas las coincidencias por relevancia, de modo que
+// el usuario más relevante está en el resultado aunque haya muchos usuarios anteriores que también coinciden.
+func (r *repo) Search(ctx context.Context, terms []string, fn func(domain.User)) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ, values := searchQuery(terms, !r.noFullText.Load())
+	err := r.scanUsers(ctx, "Search", fn, sqlQ, values...)
+
+	// Si la base de datos no tiene el índice FULLTEXT, las búsquedas siguientes usan solo LIKE. El error se produce al
+	// ejecutar la consulta, antes de leer algún usuario.
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errNoFullTextIndex {
+		r.noFullText.Store(true)
+		r.log.WarnContext(ctx, "fulltext index not found, searching with LIKE", "error", err)
+		sqlQ, values = searchQuery(terms, false)
+		return r.scanUsers(ctx, "Search", fn, sqlQ, values...)
+	}
+	return err
+}
+
+// searchQuery construye la consulta de Search. Con fullText, los términos de al menos fullTextMinTokenSize caracteres
+// se buscan con MATCH en modo booleano ("+jos*" exige una palabra que empiece con "jos"); el resto, y todos sin
+// fullText, con LIKE sobre " " + término, que es SQL estándar y funciona en cualquier motor. Como search_text ya está
+// en minúsculas y sin acentos, LIKE no depende de la intercalación de la columna. La consulta devuelve hasta
+// maxSearchCandidates coincidencias, sin ordenarlas, para que MySQL deje de leer al alcanzarlas.
+func searchQuery(terms []string, fullText bool) (string, []interface{}) {
+	// Los comodines de LIKE que contengan los términos se escapan con "!", para buscarlos como texto.
+	escape := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+	var (
+		words      []string
+		conditions []string
+		values     []interface{}
+	)
+	for _, term := range terms {
+		if fullText && utf8.RuneCountInString(term) >= fullTextMinTokenSize {
+			words = append(words, "+"+term+"*")
+			continue
+		}
+		conditions = append(conditions, "search_text LIKE ? ESCAPE '!'")
+		values = append(values, "% "+escape.Replace(term)+"%")
+	}
+	if len(words) > 0 {
+		conditions = append([]string{"MATCH(search_text) AGAINST(? IN BOOLEAN MODE)"}, conditions...)
+		values = append([]interface{}{strings.Join(words, " ")}, values...)
+	}
+
+	sqlQ := fmt.Sprintf("SELECT id, first_name, last_name, email, email_verified, totp_enabled FROM users WHERE %s LIMIT ?", strings.Join(conditions, " AND "))
+	return sqlQ, append(values, maxSearchCandidates)
+}
+
+// BackfillSearchText calcula la columna search_text de los usuarios que no la tienen, como los creados antes de la
+// migración que la agregó, de a searchBackfillBatch usuarios. Devuelve la cantidad de usuarios actualizados.
+func (r *repo) BackfillSearchText(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := r.backfillSearchTextBatch(ctx)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < searchBackfillBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		r.log.InfoContext(ctx, "search text backfilled", "count", total)
+	}
+	return total, nil
+}
+
+// backfillSearchTextBatch calcula la columna search_text de hasta searchBackfillBatch usuarios que no la tienen.
+func (r *repo) backfillSearchTextBatch(ctx context.Context) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, first_name, last_name, email FROM users WHERE search_text IS NULL ORDER BY id LIMIT ?", searchBackfillBatch)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "BackfillSearchText", "error", err)
+		return 0, err
+	}
+	texts := map[uint64]string{}
+	for rows.Next() {
+		var (
+			id                        uint64
+			firstName, lastName, mail sql.NullString
+		)
+		if err := rows.Scan(&id, &firstName, &lastName, &mail); err != nil {
+			rows.Close()
+			r.log.ErrorContext(ctx, "query failed", "method", "BackfillSearchText", "error", err)
+			return 0, err
+		}
+		texts[id] = searchText(firstName.String, lastName.String, mail.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "BackfillSearchText", "error", err)
+		return 0, err
+	}
+
+	// Solo se actualizan los usuarios que siguen sin texto de búsqueda, por si se modificaron mientras tanto.
+	for id, text := range texts {
+		if _, err := r.db.ExecContext(ctx, "UPDATE users SET search_text=? WHERE id=? AND search_text IS NULL", text, id); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "BackfillSearchText", "error", err)
+			return 0, err
+		}
+	}
+	return len(texts), nil
+}
+
+// scanUsers ejecuta una consulta que devuelve usuarios y llama a fn con cada uno a medida que los lee, sin
+// guardarlos. method es el nombre del método para el log.
+func (r *repo) scanUsers(ctx context.Context, method string, fn func(domain.User), sqlQ string, values ...interface{}) error {
+	rows, err := r.db.QueryContext(ctx, sqlQ, values...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", method, "error", err)
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var u domain.User
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.EmailVerified, &u.TwoFactorEnabled); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", method, "error", err)
+			return err
+		}
+		fn(u)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", method, "error", err)
+		return err
+	}
+
+	r.log.DebugContext(ctx, "users searched", "count", count)
+	return nil
+}
+
 /*BDD por memoria
 
 user, err := r.Get(ctx, id) // Obtener el usuario existente
@@ -156,6 +530,9 @@ r.log.Println("repository update") // Registrar en el logger que se ha actualiza
 
 // Update actualiza los datos de un usuario existente en la base de datos.
 func (r *repo) Update(ctx context.Context, id uint64, firstName, lastName, email *string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Construir la lista de campos a actualizar y los valores correspondientes.
 	var fields []string
 	var values []interface{}
@@ -173,14 +550,16 @@ func (r *repo) Update(ctx context.Context, id uint64, firstName, lastName, email
 	}
 
 	// Verificar si se proporciona un nuevo correo electrónico y agregarlo a los campos a actualizar.
+	// Si el correo electrónico cambia, vuelve a quedar pendiente de verificación; si es el mismo, conserva su estado.
+	// MySQL asigna las columnas en orden, por lo que email_verified se calcula con el correo anterior.
 	if email != nil {
-		fields = append(fields, "email=?")
-		values = append(values, *email)
+		fields = append(fields, "email_verified=IF(email <=> ?, email_verified, FALSE)", "email=?")
+		values = append(values, *email, *email)
 	}
 
 	// Verificar si no se proporciona ningún campo para actualizar.
 	if len(fields) == 0 {
-		r.log.Println(ErrThereArentFields.Error())
+		r.log.DebugContext(ctx, "update rejected", "error", ErrThereArentFields)
 		return ErrThereArentFields
 	}
 
@@ -190,48 +569,69 @@ func (r *repo) Update(ctx context.Context, id uint64, firstName, lastName, email
 	// Construir la consulta SQL final con los campos a actualizar.
 	sqlQ := fmt.Sprintf("UPDATE users SET %s WHERE id=?", strings.Join(fields, ","))
 	// Ejecutar la consulta SQL con los valores correspondientes.
-	res, err := r.db.Exec(sqlQ, values...)
+	res, err := r.db.ExecContext(ctx, sqlQ, values...)
 	if err != nil {
 		// Si ocurre un error al ejecutar la consulta, registrar el error y devolverlo.
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Update", "error", err)
 		return err
 	}
 
 	// Verificar si se actualizó correctamente algún registro.
 	row, err := res.RowsAffected()
 	if err != nil {
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Update", "error", err)
 		return err
 	}
 
 	// Si no se actualizó ningún registro, devolver un error NotFound.
 	if row == 0 {
-		err := ErrNotFound{id}
-		r.log.Println(err.Error())
+		return ErrNotFound{id}
+	}
+
+	// Recalcular el texto de búsqueda con los datos actualizados.
+	if err := r.updateSearchText(ctx, id); err != nil {
 		return err
 	}
 
 	// Registrar el éxito en el log y devolver nil (sin error).
-	r.log.Println("user updated id: ", id)
+	r.log.DebugContext(ctx, "user updated", "user_id", id)
+	return nil
+}
+
+// updateSearchText recalcula la columna search_text de un usuario a partir de su nombre, apellido y correo.
+func (r *repo) updateSearchText(ctx context.Context, id uint64) error {
+	var firstName, lastName, email sql.NullString
+	if err := r.db.QueryRowContext(ctx, "SELECT first_name, last_name, email FROM users WHERE id = ?", id).Scan(&firstName, &lastName, &email); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "updateSearchText", "error", err)
+		return err
+	}
+	text := searchText(firstName.String, lastName.String, email.String)
+	if _, err := r.db.ExecContext(ctx, "UPDATE users SET search_text=? WHERE id=?", text, id); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "updateSearchText", "error", err)
+		return err
+	}
 	return nil
 }
 
 // Delete elimina los datos de un usuario existente en la base de datos.
 func (r *repo) Delete(ctx context.Context, id uint64) (*domain.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	// Consulta SQL para eliminar un usuario por su ID
 	sqlQ := "DELETE FROM users WHERE id = ?"
 
 	// Ejecutar la consulta SQL para eliminar el usuario
-	result, err := r.db.Exec(sqlQ, id)
+	result, err := r.db.ExecContext(ctx, sqlQ, id)
 	if err != nil {
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Delete", "error", err)
 		return nil, err
 	}
 
 	// Verificar si se eliminó algún registro
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.log.Println(err.Error())
+		r.log.ErrorContext(ctx, "query failed", "method", "Delete", "error", err)
 		return nil, err
 	}
 	if rowsAffected == 0 {
@@ -241,10 +641,369 @@ func (r *repo) Delete(ctx context.Context, id uint64) (*domain.User, error) {
 	}
 
 	// Registrar el éxito en el log y devolver el usuario eliminado
-	r.log.Println("Usuario eliminado con ID:", id)
+	r.log.DebugContext(ctx, "user deleted", "user_id", id)
 	return &domain.User{ID: id}, nil
 }
 
+// SaveVerificationToken guarda el hash del token de verificación de correo de un usuario.
+// Cada usuario tiene a lo sumo un token vigente, por lo que un nuevo token reemplaza al anterior.
+func (r *repo) SaveVerificationToken(ctx context.Context, userID uint64, email, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "REPLACE INTO email_verifications(user_id, email, token_hash, expires_at) VALUES(?,?,?,?)"
+	if _, err := r.db.ExecContext(ctx, sqlQ, userID, email, tokenHash, expiresAt); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveVerificationToken", "error", err)
+		return err
+	}
+
+	r.log.DebugContext(ctx, "verification token saved", "user_id", userID)
+	return nil
+}
+
+// VerifyEmail marca como verificado el correo asociado al token y elimina el token utilizado.
+func (r *repo) VerifyEmail(ctx context.Context, tokenHash string) (uint64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// Buscar el token y los datos del usuario al que pertenece.
+	sqlQ := "SELECT user_id, email, expires_at FROM email_verifications WHERE token_hash = ?"
+	var (
+		userID    uint64
+		email     string
+		expiresAt time.Time
+	)
+	if err := r.db.QueryRowContext(ctx, sqlQ, tokenHash).Scan(&userID, &email, &expiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			r.log.DebugContext(ctx, "verification rejected", "error", ErrInvalidVerificationToken)
+			return 0, ErrInvalidVerificationToken
+		}
+		r.log.ErrorContext(ctx, "query failed", "method", "VerifyEmail", "error", err)
+		return 0, err
+	}
+
+	// Verificar que el token no haya expirado.
+	if time.Now().After(expiresAt) {
+		r.log.DebugContext(ctx, "verification rejected", "error", ErrVerificationTokenExpired)
+		return 0, ErrVerificationTokenExpired
+	}
+
+	// Marcar el correo como verificado solo si el usuario no lo cambió después de emitir el token.
+	res, err := r.db.ExecContext(ctx, "UPDATE users SET email_verified=? WHERE id=? AND email=?", true, userID, email)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "VerifyEmail", "error", err)
+		return 0, err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "VerifyEmail", "error", err)
+		return 0, err
+	}
+	if row == 0 {
+		r.log.DebugContext(ctx, "verification rejected", "error", ErrInvalidVerificationToken)
+		return 0, ErrInvalidVerificationToken
+	}
+
+	// Eliminar el token para que no pueda volver a utilizarse.
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM email_verifications WHERE user_id = ?", userID); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "VerifyEmail", "error", err)
+		return 0, err
+	}
+
+	r.log.DebugContext(ctx, "email verified", "user_id", userID)
+	return userID, nil
+}
+
+// GetTOTP devuelve el secreto TOTP de un usuario y si la autenticación de dos factores está activada.
+func (r *repo) GetTOTP(ctx context.Context, id uint64) (string, bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "SELECT totp_secret, totp_enabled FROM users WHERE id = ?"
+	var (
+		secret  sql.NullString
+		enabled bool
+	)
+	if err := r.db.QueryRowContext(ctx, sqlQ, id).Scan(&secret, &enabled); err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, ErrNotFound{id}
+		}
+		r.log.ErrorContext(ctx, "query failed", "method", "GetTOTP", "error", err)
+		return "", false, err
+	}
+
+	return secret.String, enabled, nil
+}
+
+// SetTOTPSecret guarda un secreto TOTP pendiente de confirmación para un usuario, en una transacción que bloquea su
+// fila. Solo se aplica si la autenticación de dos factores no está activada, de modo que una inscripción simultánea
+// con otra o con una confirmación no reemplaza el secreto de un usuario que ya la activó.
+func (r *repo) SetTOTPSecret(ctx context.Context, id uint64, secret string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := r.inTx(ctx, func(tx *repo) error {
+		var enabled bool
+		if err := tx.db.QueryRowContext(ctx, "SELECT totp_enabled FROM users WHERE id = ? FOR UPDATE", id).Scan(&enabled); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound{id}
+			}
+			return err
+		}
+		if enabled {
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		res, err := tx.db.ExecContext(ctx, "UPDATE users SET totp_secret=?, totp_enabled=?, totp_last_step=NULL WHERE id=? AND totp_enabled=?", secret, false, id, false)
+		if err != nil {
+			return err
+		}
+		row, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if row == 0 {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		return nil
+	})
+	if err != nil {
+		var notFound ErrNotFound
+		if !errors.As(err, &notFound) && !errors.Is(err, ErrTwoFactorAlreadyEnabled) {
+			r.log.ErrorContext(ctx, "query failed", "method", "SetTOTPSecret", "error", err)
+		}
+		return err
+	}
+
+	r.log.DebugContext(ctx, "totp secret saved", "user_id", id)
+	return nil
+}
+
+// EnableTOTP activa la autenticación de dos factores de un usuario, registra step como el último período TOTP
+// utilizado y reemplaza sus códigos de recuperación, en una transacción. La activación solo se aplica si no estaba
+// activada, de modo que dos confirmaciones simultáneas no se reemplazan los códigos de recuperación entre sí.
+func (r *repo) EnableTOTP(ctx context.Context, id uint64, step uint64, recoveryCodeHashes []string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := r.inTx(ctx, func(tx *repo) error {
+		res, err := tx.db.ExecContext(ctx, "UPDATE users SET totp_enabled=?, totp_last_step=? WHERE id=? AND totp_enabled=?", true, step, id, false)
+		if err != nil {
+			return err
+		}
+		row, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if row == 0 {
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		// Reemplazar los códigos de recuperación anteriores por los nuevos.
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = ?", id); err != nil {
+			return err
+		}
+		for _, h := range recoveryCodeHashes {
+			if _, err := tx.db.ExecContext(ctx, "INSERT INTO user_recovery_codes(user_id, code_hash) VALUES(?,?)", id, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrTwoFactorAlreadyEnabled) {
+			r.log.ErrorContext(ctx, "query failed", "method", "EnableTOTP", "error", err)
+		}
+		return err
+	}
+
+	r.log.DebugContext(ctx, "totp enabled", "user_id", id)
+	return nil
+}
+
+// DisableTOTP desactiva la autenticación de dos factores de un usuario y elimina sus códigos de recuperación, en una
+// transacción.
+func (r *repo) DisableTOTP(ctx context.Context, id uint64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	err := r.inTx(ctx, func(tx *repo) error {
+		if _, err := tx.db.ExecContext(ctx, "UPDATE users SET totp_secret=NULL, totp_enabled=?, totp_last_step=NULL WHERE id=?", false, id); err != nil {
+			return err
+		}
+		_, err := tx.db.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = ?", id)
+		return err
+	})
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "DisableTOTP", "error", err)
+		return err
+	}
+
+	r.log.DebugContext(ctx, "totp disabled", "user_id", id)
+	return nil
+}
+
+// UseTOTPStep registra step como el último período TOTP utilizado por un usuario con la autenticación de dos
+// factores activada e indica si es posterior al anterior. La comparación se hace en la misma sentencia, de modo que
+// dos verificaciones simultáneas del mismo código no pueden ser aceptadas ambas.
+func (r *repo) UseTOTPStep(ctx context.Context, id uint64, step uint64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "UPDATE users SET totp_last_step=? WHERE id=? AND totp_enabled=? AND (totp_last_step IS NULL OR totp_last_step < ?)"
+	res, err := r.db.ExecContext(ctx, sqlQ, step, id, true, step)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "UseTOTPStep", "error", err)
+		return false, err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "UseTOTPStep", "error", err)
+		return false, err
+	}
+
+	return row > 0, nil
+}
+
+// UseRecoveryCode elimina el código de recuperación de un usuario e indica si existía.
+func (r *repo) UseRecoveryCode(ctx context.Context, id uint64, codeHash string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = ? AND code_hash = ?", id, codeHash)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "UseRecoveryCode", "error", err)
+		return false, err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "UseRecoveryCode", "error", err)
+		return false, err
+	}
+
+	return row > 0, nil
+}
+
+// SaveAudit guarda un registro en el log de auditoría.
+func (r *repo) SaveAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	// Sin actor declarado, la columna declared_actor queda en NULL.
+	declared := sql.NullString{String: entry.DeclaredActor, Valid: entry.DeclaredActor != ""}
+
+	sqlQ := "INSERT INTO audit_log(actor, declared_actor, action, user_id, changes, request_id, created_at) VALUES(?,?,?,?,?,?,?)"
+	res, err := r.db.ExecContext(ctx, sqlQ, entry.Actor, declared, entry.Action, entry.UserID, changes, entry.RequestID, entry.CreatedAt)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveAudit", "error", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveAudit", "error", err)
+		return err
+	}
+
+	entry.ID = uint64(id)
+	return nil
+}
+
+// GetAudit devuelve los registros del log de auditoría que cumplen con los filtros, del más reciente al más antiguo.
+func (r *repo) GetAudit(ctx context.Context, filters AuditFilters) ([]domain.AuditEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "SELECT id, actor, declared_actor, action, user_id, changes, request_id, created_at FROM audit_log"
+
+	// Agregar las condiciones de los filtros proporcionados.
+	var conditions []string
+	var values []interface{}
+	if filters.UserID != nil {
+		conditions = append(conditions, "user_id=?")
+		values = append(values, *filters.UserID)
+	}
+	if filters.Actor != "" {
+		conditions = append(conditions, "actor=?")
+		values = append(values, filters.Actor)
+	}
+	if filters.Action != "" {
+		conditions = append(conditions, "action=?")
+		values = append(values, filters.Action)
+	}
+	if filters.From != nil {
+		conditions = append(conditions, "created_at>=?")
+		values = append(values, *filters.From)
+	}
+	if filters.To != nil {
+		conditions = append(conditions, "created_at<=?")
+		values = append(values, *filters.To)
+	}
+	if len(conditions) > 0 {
+		sqlQ = fmt.Sprintf("%s WHERE %s", sqlQ, strings.Join(conditions, " AND "))
+	}
+	sqlQ = fmt.Sprintf("%s ORDER BY id DESC LIMIT ?", sqlQ)
+	values = append(values, filters.Limit)
+
+	rows, err := r.db.QueryContext(ctx, sqlQ, values...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "GetAudit", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var (
+			e        domain.AuditEntry
+			declared sql.NullString
+			changes  []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Actor, &declared, &e.Action, &e.UserID, &changes, &e.RequestID, &e.CreatedAt); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "GetAudit", "error", err)
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "GetAudit", "error", err)
+			return nil, err
+		}
+		e.DeclaredActor = declared.String
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "GetAudit", "error", err)
+		return nil, err
+	}
+
+	r.log.DebugContext(ctx, "audit entries listed", "count", len(entries))
+	return entries, nil
+}
+
+// SaveEvent guarda un evento de dominio en el outbox para que sea entregado en segundo plano.
+func (r *repo) SaveEvent(ctx context.Context, event *outbox.Event) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "INSERT INTO outbox_events(event_type, event_key, payload, created_at) VALUES(?,?,?,?)"
+	res, err := r.db.ExecContext(ctx, sqlQ, event.Type, event.Key, []byte(event.Payload), event.CreatedAt)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveEvent", "error", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveEvent", "error", err)
+		return err
+	}
+
+	event.ID = uint64(id)
+	return nil
+}
+
 /*
 Capa de repositorio (Repository):
 
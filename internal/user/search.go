@@ -0,0 +1,288 @@
+package user
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// highlightStart y highlightEnd encierran las coincidencias en los campos resaltados de los resultados.
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+
+	// nameWeight y emailWeight son el peso de las coincidencias en el nombre y el apellido, y en el correo.
+	nameWeight  = 2
+	emailWeight = 1
+
+	// minSearchTermLength es la longitud mínima de los términos de una búsqueda. Los más cortos se ignoran: coinciden
+	// con gran parte de los usuarios y LIKE no puede usar un índice para buscarlos.
+	minSearchTermLength = 2
+)
+
+// Tipos de coincidencia de un término con un campo, de mayor a menor relevancia. Un término solo coincide con el
+// comienzo de una palabra, igual que en el índice FULLTEXT y en la búsqueda con LIKE del repositorio.
+const (
+	matchNone      = iota
+	matchWordStart // Una palabra del campo empieza con el término
+	matchPrefix    // El campo empieza con el término
+	matchWord      // Una palabra del campo es igual al término
+)
+
+// SearchResult es un usuario encontrado por la búsqueda, con su relevancia y los campos en los que coincide.
+type SearchResult struct {
+	User       domain.User       `json:"user"`       // Usuario encontrado
+	Score      int               `json:"score"`      // Relevancia del usuario para la búsqueda; mayor es más relevante
+	Highlights map[string]string `json:"highlights"` // Campos que coinciden, con las coincidencias entre <mark> y </mark> y el resto escapado para HTML
+}
+
+// searchField es un campo de búsqueda de un usuario, con su texto normalizado.
+type searchField struct {
+	name   string // Nombre del campo en JSON
+	value  string // Texto original
+	folded []rune // Texto en minúsculas y sin acentos, con un carácter por cada carácter del original
+	weight int    // Peso de las coincidencias en el campo
+}
+
+// searchText devuelve el texto de búsqueda de un usuario que el repositorio guarda en la columna search_text: las
+// palabras del nombre, el apellido y el correo en minúsculas y sin acentos, cada una precedida por un espacio
+// (" jose nunez jose nunez example com"). Con el espacio inicial, que un término sea el comienzo de alguna palabra
+// equivale a que el texto contenga " " + término, tanto para LIKE como para el índice FULLTEXT.
+func searchText(firstName, lastName, email string) string {
+	var b strings.Builder
+	for _, field := range []string{firstName, lastName, email} {
+		for _, w := range searchTerms(field) {
+			b.WriteString(" ")
+			b.WriteString(w)
+		}
+	}
+	return b.String()
+}
+
+// queryTerms devuelve los términos de la búsqueda que tienen al menos minSearchTermLength caracteres.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, term := range searchTerms(query) {
+		if utf8.RuneCountInString(term) >= minSearchTermLength {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchTerms divide el texto (la búsqueda o un campo del usuario) en palabras en minúsculas y sin acentos; los
+// caracteres que no son letras ni dígitos separan las palabras ("jose.nunez@example.com" → jose, nunez, example, com).
+func searchTerms(s string) []string {
+	folded := fold(s)
+	var words []string
+	start := -1
+	for i := range folded {
+		switch {
+		case isWordRune(folded, i) && start < 0:
+			start = i
+		case !isWordRune(folded, i) && start >= 0:
+			words = append(words, string(folded[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(folded[start:]))
+	}
+	return words
+}
+
+// fold devuelve el texto en minúsculas y sin acentos ("José Núñez" → "jose nunez"), con un carácter por cada carácter
+// del original, de modo que las posiciones de las coincidencias en el texto normalizado sirven para resaltar el original.
+func fold(s string) []rune {
+	folded := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			// La descomposición canónica separa la letra base de sus marcas diacríticas: é → e + ´.
+			r, _ = utf8.DecodeRuneInString(norm.NFD.String(string(r)))
+		}
+		folded = append(folded, unicode.ToLower(r))
+	}
+	return folded
+}
+
+// rankUsers devuelve los usuarios en cuyo nombre, apellido o correo cada término es el comienzo de alguna palabra, sin
+// distinguir mayúsculas ni acentos, ordenados por relevancia y luego por ID, con sus coincidencias resaltadas. Acepta
+// los mismos usuarios que el repositorio preselecciona con searchText, y como los ordena en memoria, no depende del
+// motor de base de datos.
+func rankUsers(users []domain.User, terms []string, limit int) []SearchResult {
+	ranking := newSearchRanking(terms, limit)
+	for _, u := range users {
+		ranking.add(u)
+	}
+	return ranking.results
+}
+
+// searchRanking conserva los limit resultados más relevantes de los usuarios que recibe, en el orden de rankUsers,
+// sin guardar el resto. Así el servicio puede ordenar todas las coincidencias de una búsqueda con memoria acotada.
+type searchRanking struct {
+	terms   []string
+	limit   int            // Cantidad máxima de resultados; cero indica sin límite
+	results []SearchResult // Resultados ordenados por relevancia y luego por ID
+}
+
+// newSearchRanking crea un searchRanking para los términos indicados.
+func newSearchRanking(terms []string, limit int) *searchRanking {
+	return &searchRanking{
+		terms:   terms,
+		limit:   limit,
+		results: []SearchResult{},
+	}
+}
+
+// add agrega el usuario a los resultados si coincide con los términos y está entre los limit más relevantes.
+func (k *searchRanking) add(u domain.User) {
+	r, ok := rankUser(u, k.terms)
+	if !ok {
+		return
+	}
+
+	i := sort.Search(len(k.results), func(i int) bool {
+		if k.results[i].Score != r.Score {
+			return k.results[i].Score < r.Score
+		}
+		return k.results[i].User.ID > r.User.ID
+	})
+	if k.limit > 0 && i >= k.limit {
+		return
+	}
+	k.results = append(k.results, SearchResult{})
+	copy(k.results[i+1:], k.results[i:])
+	k.results[i] = r
+	if k.limit > 0 && len(k.results) > k.limit {
+		k.results = k.results[:k.limit]
+	}
+}
+
+// rankUser calcula la relevancia de un usuario: por cada término, la mejor coincidencia en alguno de sus campos según
+// su tipo y el peso del campo. Devuelve false si algún término no coincide con ningún campo.
+func rankUser(u domain.User, terms []string) (SearchResult, bool) {
+	fields := []searchField{
+		{name: "first_name", value: u.FirstName, weight: nameWeight},
+		{name: "last_name", value: u.LastName, weight: nameWeight},
+		{name: "email", value: u.Email, weight: emailWeight},
+	}
+	for i := range fields {
+		fields[i].folded = fold(fields[i].value)
+	}
+
+	result := SearchResult{User: u, Highlights: map[string]string{}}
+	for _, term := range terms {
+		best := 0
+		for _, f := range fields {
+			if score := matchKind(f.folded, []rune(term)) * f.weight; score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return SearchResult{}, false
+		}
+		result.Score += best
+	}
+
+	for _, f := range fields {
+		if h, ok := highlight(f, terms); ok {
+			result.Highlights[f.name] = h
+		}
+	}
+	return result, true
+}
+
+// matchKind devuelve el mejor tipo de coincidencia del término con el comienzo de alguna palabra del texto, o
+// matchNone si ninguna palabra empieza con el término.
+func matchKind(text, term []rune) int {
+	best := matchNone
+	for _, i := range occurrences(text, term) {
+		kind := matchWordStart
+		switch {
+		case !isWordRune(text, i+len(term)):
+			kind = matchWord
+		case i == 0:
+			kind = matchPrefix
+		}
+		if kind > best {
+			best = kind
+		}
+	}
+	return best
+}
+
+// highlight devuelve el valor del campo con las coincidencias de los términos al comienzo de sus palabras entre
+// highlightStart y highlightEnd, y el resto escapado para HTML. Devuelve false si ningún término coincide con el campo.
+func highlight(f searchField, terms []string) (string, bool) {
+	// Marca los caracteres que forman parte de alguna coincidencia.
+	marked := make([]bool, len(f.folded))
+	found := false
+	for _, term := range terms {
+		t := []rune(term)
+		for _, i := range occurrences(f.folded, t) {
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			found = true
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	// Recorre el texto original y abre o cierra la marca al cambiar de tramo.
+	var b strings.Builder
+	open := false
+	i := 0
+	for _, r := range f.value {
+		if marked[i] != open {
+			if open {
+				b.WriteString(highlightEnd)
+			} else {
+				b.WriteString(highlightStart)
+			}
+			open = marked[i]
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		i++
+	}
+	if open {
+		b.WriteString(highlightEnd)
+	}
+	return b.String(), true
+}
+
+// occurrences devuelve las posiciones (en caracteres) en las que el término aparece en el texto al comienzo de una
+// palabra.
+func occurrences(text, term []rune) []int {
+	if len(term) == 0 {
+		return nil
+	}
+	var positions []int
+	for i := 0; i+len(term) <= len(text); i++ {
+		if !isWordRune(text, i-1) && equalRunes(text[i:i+len(term)], term) {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// equalRunes indica si las dos secuencias de caracteres son iguales.
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune indica si el carácter en la posición i es una letra o un dígito; fuera del texto no lo es.
+func isWordRune(text []rune, i int) bool {
+	return i >= 0 && i < len(text) && (unicode.IsLetter(text[i]) || unicode.IsDigit(text[i]))
+}
@@ -0,0 +1,290 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"José Núñez", "jose nunez"},
+		{"ÀÉÎÕÜ ç", "aeiou c"},
+		{"Jose.Nunez@Example.com", "jose.nunez@example.com"},
+		{"Łukasz", "łukasz"}, // Ł no tiene descomposición canónica
+		{"李小龙", "李小龙"},
+	}
+	for _, tt := range tests {
+		got := fold(tt.in)
+		if string(got) != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.in, string(got), tt.want)
+		}
+		// Las posiciones del texto normalizado deben corresponder a las del original para poder resaltarlo.
+		if n := len([]rune(tt.in)); len(got) != n {
+			t.Errorf("fold(%q) has %d runes, want %d", tt.in, len(got), n)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"José  Nu", []string{"jose", "nu"}},
+		{"jose.nunez@example.com", []string{"jose", "nunez", "example", "com"}},
+		{"o'brien-smith", []string{"o", "brien", "smith"}},
+		{"user42", []string{"user42"}},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"j", nil},
+		{"a b c", nil},
+		{"José N", []string{"jose"}},
+		{"jo nu", []string{"jo", "nu"}},
+	}
+	for _, tt := range tests {
+		if got := queryTerms(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryTerms(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSearchEndpointRejectsShortQueries verifica que las búsquedas sin ningún término de al menos
+// minSearchTermLength caracteres se rechacen sin consultar el repositorio.
+func TestSearchEndpointRejectsShortQueries(t *testing.T) {
+	s := NewService(slog.New(slog.NewTextHandler(io.Discard, nil)), searchRepo{}, nil, "")
+	endpoint := makeSearchEndpoint(s)
+
+	_, err := endpoint(context.Background(), SearchReq{Query: "j"})
+	var validation apierror.ValidationError
+	if !errors.As(err, &validation) || len(validation.Fields) != 1 || validation.Fields[0].Code != "query_too_short" {
+		t.Fatalf("endpoint() = %v, want a query_too_short validation error", err)
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	got := searchText("José", "Núñez", "jose.nunez@example.com")
+	want := " jose nunez jose nunez example com"
+	if got != want {
+		t.Errorf("searchText() = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		terms  []string
+		want   string
+		wantOK bool
+	}{
+		{"word", "José", []string{"jose"}, "<mark>José</mark>", true},
+		{"prefix keeps accents", "Núñez", []string{"nu"}, "<mark>Nú</mark>ñez", true},
+		{"several words", "jose.nunez@example.com", []string{"jose", "nu"}, "<mark>jose</mark>.<mark>nu</mark>nez@example.com", true},
+		{"adjacent terms merge", "Ana", []string{"a", "an"}, "<mark>An</mark>a", true},
+		{"middle of a word", "Fernández", []string{"ernan"}, "", false},
+		{"no match", "José", []string{"maria"}, "", false},
+		{"escapes html", "<b>Ana</b>", []string{"ana"}, "&lt;b&gt;<mark>Ana</mark>&lt;/b&gt;", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := searchField{value: tt.value, folded: fold(tt.value)}
+			got, ok := highlight(f, tt.terms)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("highlight(%q, %q) = %q, %v; want %q, %v", tt.value, tt.terms, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRankUsers(t *testing.T) {
+	users := []domain.User{
+		{ID: 1, FirstName: "José", LastName: "Núñez", Email: "jose.nunez@example.com"},
+		{ID: 2, FirstName: "Josefina", LastName: "Pérez", Email: "jp@example.com"},
+		{ID: 3, FirstName: "María", LastName: "Fernández", Email: "maria@example.com"},
+		{ID: 4, FirstName: "Ana", LastName: "José", Email: "ana@example.com"},
+		{ID: 5, FirstName: "Pedro", LastName: "Gómez", Email: "pedro.jose@example.com"},
+	}
+
+	tests := []struct {
+		name   string
+		terms  []string
+		limit  int
+		ids    []uint64
+		scores []int
+	}{
+		// Palabra igual al término en el nombre (3*2) y prefijo del apellido (2*2).
+		{"two terms", []string{"jose", "nu"}, 0, []uint64{1}, []int{10}},
+		// Igual en el nombre o el apellido (3*2), prefijo del nombre (2*2), igual en el correo (3*1); empate por ID.
+		{"word beats prefix", []string{"jose"}, 0, []uint64{1, 4, 2, 5}, []int{6, 6, 4, 3}},
+		{"limit", []string{"jose"}, 2, []uint64{1, 4}, []int{6, 6}},
+		{"middle of a word does not match", []string{"ernan"}, 0, []uint64{}, []int{}},
+		{"every term must match", []string{"maria", "nunez"}, 0, []uint64{}, []int{}},
+		{"accents are ignored", []string{"fernandez"}, 0, []uint64{3}, []int{6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := rankUsers(users, tt.terms, tt.limit)
+			ids, scores := []uint64{}, []int{}
+			for _, r := range results {
+				ids = append(ids, r.User.ID)
+				scores = append(scores, r.Score)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) || !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("rankUsers(%q) = ids %v scores %v, want ids %v scores %v", tt.terms, ids, scores, tt.ids, tt.scores)
+			}
+		})
+	}
+}
+
+// TestRankUsersMatchesSearchText verifica que rankUsers acepte exactamente los usuarios que el repositorio preselecciona
+// con la columna search_text (que contenga " " + término para cada término), de modo que la búsqueda con el índice
+// FULLTEXT y la búsqueda con LIKE no descarten ni agreguen usuarios al ordenarlos.
+func TestRankUsersMatchesSearchText(t *testing.T) {
+	users := []domain.User{
+		{ID: 1, FirstName: "José", LastName: "Núñez", Email: "jose.nunez@example.com"},
+		{ID: 2, FirstName: "María José", LastName: "de la Fuente", Email: "mj_delafuente@example.org"},
+		{ID: 3, FirstName: "Fernando", LastName: "Hernández", Email: "fer+test@example.com"},
+		{ID: 4, FirstName: "Zoë", LastName: "O'Brien", Email: "zoe.obrien@example.ie"},
+		{ID: 5, FirstName: "Ana", LastName: "", Email: ""},
+	}
+	queries := []string{"jose", "JOSÉ nu", "ernan", "fer", "de la", "fuente", "zoe", "o brien", "obrien", "example com", "a", "test", "ez", "ie"}
+
+	for _, q := range queries {
+		terms := searchTerms(q)
+		want := map[uint64]bool{}
+		for _, u := range users {
+			text := searchText(u.FirstName, u.LastName, u.Email)
+			all := true
+			for _, term := range terms {
+				if !strings.Contains(text, " "+term) {
+					all = false
+				}
+			}
+			if all {
+				want[u.ID] = true
+			}
+		}
+
+		got := map[uint64]bool{}
+		for _, r := range rankUsers(users, terms, 0) {
+			got[r.User.ID] = true
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("query %q: rankUsers accepts %v, search_text matches %v", q, got, want)
+		}
+	}
+}
+
+// searchRepo es un Repository que solo implementa Search, sobre una lista de usuarios que coinciden.
+type searchRepo struct {
+	Repository
+	users []domain.User
+}
+
+func (r searchRepo) Search(_ context.Context, _ []string, fn func(domain.User)) error {
+	for _, u := range r.users {
+		fn(u)
+	}
+	return nil
+}
+
+// TestServiceSearchRanksEveryMatch verifica que el usuario más relevante se devuelva aunque lo precedan, por ID, muchos
+// usuarios que coinciden peor.
+func TestServiceSearchRanksEveryMatch(t *testing.T) {
+	var users []domain.User
+	for i := 1; i <= 100; i++ {
+		users = append(users, domain.User{ID: uint64(i), FirstName: "Josefina", Email: fmt.Sprintf("user%d@example.com", i)})
+	}
+	users = append(users, domain.User{ID: 500, FirstName: "José", LastName: "Núñez", Email: "jose@example.com"})
+
+	s := NewService(slog.New(slog.NewTextHandler(io.Discard, nil)), searchRepo{users: users}, nil, "")
+	results, err := s.Search(context.Background(), "jose", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []uint64{}
+	for _, r := range results {
+		ids = append(ids, r.User.ID)
+	}
+	if want := []uint64{500, 1, 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Search() ids = %v, want %v", ids, want)
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		terms    []string
+		fullText bool
+		query    string
+		args     []interface{}
+	}{
+		{
+			name:     "fulltext",
+			terms:    []string{"jose", "nun"},
+			fullText: true,
+			query:    "MATCH(search_text) AGAINST(? IN BOOLEAN MODE)",
+			args:     []interface{}{"+jose* +nun*"},
+		},
+		{
+			name:     "short term uses like",
+			terms:    []string{"jose", "nu"},
+			fullText: true,
+			query:    "MATCH(search_text) AGAINST(? IN BOOLEAN MODE) AND search_text LIKE ? ESCAPE '!'",
+			args:     []interface{}{"+jose*", "% nu%"},
+		},
+		{
+			name:     "without index",
+			terms:    []string{"jose", "nun"},
+			fullText: false,
+			query:    "search_text LIKE ? ESCAPE '!' AND search_text LIKE ? ESCAPE '!'",
+			args:     []interface{}{"% jose%", "% nun%"},
+		},
+		{
+			name:     "like escapes wildcards",
+			terms:    []string{"a_b%!"},
+			fullText: false,
+			query:    "search_text LIKE ? ESCAPE '!'",
+			args:     []interface{}{"% a!_b!%!!%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := searchQuery(tt.terms, tt.fullText)
+			// La consulta no se ordena, para que MySQL deje de leer al alcanzar la cantidad máxima de candidatos.
+			if !strings.HasSuffix(query, "WHERE "+tt.query+" LIMIT ?") {
+				t.Errorf("searchQuery() = %q, want it to end with WHERE %s LIMIT ?", query, tt.query)
+			}
+			if want := append(tt.args, maxSearchCandidates); !reflect.DeepEqual(args, want) {
+				t.Errorf("searchQuery() args = %v, want %v", args, want)
+			}
+		})
+	}
+}
@@ -2,42 +2,111 @@ package user
 
 import (
 	"context" // Paquete `context`: Proporciona un objeto de contexto que lleva información del ámbito de la solicitud.
-	"log"     // Paquete `log`: Proporciona funciones para registrar mensajes.
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog" // Paquete `log/slog`: Proporciona el logger estructurado con niveles.
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/mail"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/totp"
 )
 
+const (
+	// verificationTokenTTL es el tiempo durante el cual un token de verificación de correo es válido.
+	verificationTokenTTL = 24 * time.Hour
+
+	// totpIssuer es el emisor que muestran las aplicaciones autenticadoras.
+	totpIssuer = "go-fundamentals-web-users"
+
+	// recoveryCodesCount es la cantidad de códigos de recuperación que se generan al activar la autenticación de dos factores.
+	recoveryCodesCount = 10
+
+	// defaultAuditLimit y maxAuditLimit son la cantidad predeterminada y máxima de registros de auditoría por consulta.
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// TwoFactorEnrollment contiene los datos necesarios para registrar el secreto TOTP en una aplicación autenticadora.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`      // Secreto TOTP codificado en base32
+	URI    string `json:"otpauth_uri"` // URI otpauth:// con el secreto y el emisor
+	QRCode []byte `json:"qr_code"`     // Imagen PNG con el código QR de la URI (base64 en JSON)
+}
+
 // Service define la interfaz del servicio de usuarios.
 type Service interface {
 	// Create crea un nuevo usuario con los datos proporcionados.
 	// El contexto se utiliza para pasar información adicional a la función Create.
 	Create(ctx context.Context, firstName, lastName, email string) (*domain.User, error)
 
-	// GetAll devuelve todos los usuarios almacenados en la base de datos.
-	GetAll(ctx context.Context) ([]domain.User, error)
+	// GetAll devuelve todos los usuarios almacenados en la base de datos que cumplen con los filtros.
+	GetAll(ctx context.Context, filters Filters) ([]domain.User, error)
 
 	// Get devuelve un usuario específico basado en su ID.
 	Get(ctx context.Context, id uint64) (*domain.User, error)
 
-	// Update actualiza los datos de un usuario existente.
+	// GetMany devuelve los usuarios con los IDs indicados en una sola consulta, ordenados por ID.
+	// Los IDs que no existen se omiten.
+	GetMany(ctx context.Context, ids []uint64) ([]domain.User, error)
+
+	// Search busca los usuarios cuyo nombre, apellido o correo contienen las palabras de la búsqueda, sin distinguir
+	// mayúsculas ni acentos, y devuelve hasta limit resultados ordenados por relevancia y con las coincidencias resaltadas.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+
+	// Update actualiza los datos de un usuario existente. Si el usuario tiene activada la autenticación de dos
+	// factores, exige el código del segundo factor guardado en el contexto (ver requestctx.TwoFactorCode).
 	Update(ctx context.Context, id uint64, firstName, lastName, email *string) error
 
-	// Elimina un usuario específico basado en su ID.
+	// Elimina un usuario específico basado en su ID. Igual que Update, exige el segundo factor si está activado.
 	Delete(ctx context.Context, id uint64) (*domain.User, error)
+
+	// VerifyEmail confirma el correo electrónico del usuario al que pertenece el token.
+	VerifyEmail(ctx context.Context, token string) error
+
+	// EnrollTwoFactor genera un nuevo secreto TOTP pendiente de confirmación para el usuario.
+	EnrollTwoFactor(ctx context.Context, id uint64) (*TwoFactorEnrollment, error)
+
+	// ConfirmTwoFactor activa la autenticación de dos factores si el código es válido y devuelve los códigos de recuperación.
+	ConfirmTwoFactor(ctx context.Context, id uint64, code string) ([]string, error)
+
+	// VerifyTwoFactor verifica un código TOTP o un código de recuperación como segundo factor del usuario.
+	VerifyTwoFactor(ctx context.Context, id uint64, code string) error
+
+	// DisableTwoFactor desactiva la autenticación de dos factores del usuario, previa verificación del código.
+	DisableTwoFactor(ctx context.Context, id uint64, code string) error
+
+	// History devuelve el historial de cambios de un usuario, del más reciente al más antiguo.
+	History(ctx context.Context, id uint64) ([]domain.AuditEntry, error)
+
+	// Audit devuelve los registros del log de auditoría que cumplen con los filtros.
+	Audit(ctx context.Context, filters AuditFilters) ([]domain.AuditEntry, error)
 }
 
 // service es una implementación del servicio de usuarios.
 type service struct {
-	log  *log.Logger // Instancia del logger para registrar mensajes.
-	repo Repository  // Instancia del repositorio de usuarios.
+	log       *slog.Logger // Instancia del logger estructurado para registrar mensajes.
+	repo      Repository   // Instancia del repositorio de usuarios.
+	mailer    mail.Mailer  // Instancia del Mailer para enviar los correos de verificación.
+	verifyURL string       // URL pública del endpoint de verificación de correo.
 }
 
 // NewService es una función constructora que devuelve una nueva instancia del servicio de usuarios.
-// Crea un nuevo servicio de usuarios utilizando el logger y el repositorio.
-func NewService(l *log.Logger, repo Repository) Service {
+// Crea un nuevo servicio de usuarios utilizando el logger, el repositorio, el Mailer y la URL de verificación de correo.
+func NewService(l *slog.Logger, repo Repository, mailer mail.Mailer, verifyURL string) Service {
 	return &service{
-		log:  l,
-		repo: repo,
+		log:       l,
+		repo:      repo,
+		mailer:    mailer,
+		verifyURL: verifyURL,
 	}
 }
 
@@ -50,28 +119,48 @@ func (s *service) Create(ctx context.Context, firstName, lastName, email string)
 		Email:     email,
 	}
 
-	// Delega la creación del usuario al repositorio.
-	if err := s.repo.Create(ctx, user); err != nil {
+	// Delega la creación del usuario al repositorio y registra la auditoría, el evento y el token de verificación de
+	// correo en la misma transacción, de modo que una falla en cualquiera de ellos no deja un usuario creado.
+	var token string
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		if err := tx.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := s.audit(ctx, tx, domain.AuditActionCreate, user.ID, nil, user); err != nil {
+			return err
+		}
+		if err := s.publish(ctx, tx, domain.EventUserCreated, user.ID, user); err != nil {
+			return err
+		}
+		var err error
+		token, err = s.saveVerification(ctx, tx, user.ID, user.Email)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// Registra un mensaje en el logger indicando la creación del usuario.
-	s.log.Println("Usuario creado correctamente")
+	s.log.InfoContext(ctx, "user created", "user_id", user.ID)
+
+	// Envía el correo de verificación al usuario creado. El usuario ya existe, por lo que una falla en el envío no
+	// hace fallar la solicitud: si el cliente la reintentara, crearía un usuario duplicado.
+	s.sendVerification(ctx, user.ID, user.Email, token)
 
 	// Retorna la instancia del usuario creado.
 	return user, nil
 }
 
 // GetAll devuelve todos los usuarios almacenados en la base de datos.
-func (s *service) GetAll(ctx context.Context) ([]domain.User, error) {
+func (s *service) GetAll(ctx context.Context, filters Filters) ([]domain.User, error) {
 	// Delega la obtención de todos los usuarios al repositorio.
-	users, err := s.repo.GetAll(ctx)
+	users, err := s.repo.GetAll(ctx, filters)
 	if err != nil {
 		return nil, err
 	}
 
 	// Registra un mensaje en el logger indicando la obtención de todos los usuarios.
-	s.log.Println("Se han obtenido todos los usuarios")
+	s.log.InfoContext(ctx, "users listed", "count", len(users))
 
 	// Retorna la lista de usuarios obtenida del repositorio.
 	return users, nil
@@ -86,40 +175,446 @@ func (s *service) Get(ctx context.Context, id uint64) (*domain.User, error) {
 	}
 
 	// Registra un mensaje en el logger indicando la obtención del usuario.
-	s.log.Println("Se ha obtenido el usuario seleccionado")
+	s.log.InfoContext(ctx, "user fetched", "user_id", id)
 
 	// Retorna el usuario obtenido del repositorio.
 	return user, nil
 }
 
+// GetMany devuelve los usuarios con los IDs indicados en una sola consulta.
+func (s *service) GetMany(ctx context.Context, ids []uint64) ([]domain.User, error) {
+	// Delega la obtención de los usuarios al repositorio.
+	users, err := s.repo.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Registra un mensaje en el logger indicando la cantidad de usuarios solicitados y encontrados.
+	s.log.InfoContext(ctx, "users fetched", "requested", len(ids), "found", len(users))
+
+	return users, nil
+}
+
+// Search busca los usuarios que coinciden con la búsqueda. El repositorio encuentra todas las coincidencias (con el
+// índice FULLTEXT o con LIKE) y el servicio las ordena por relevancia, conserva las limit mejores y resalta las
+// coincidencias. Ambos consideran que un término coincide si es el comienzo de una palabra, con las palabras
+// normalizadas por searchTerms, de modo que el resultado es el mismo con cualquier implementación del repositorio.
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	// Todas las coincidencias que devuelve el repositorio (hasta maxSearchCandidates) pasan por el ranking: el usuario
+	// más relevante puede ser cualquiera de ellas.
+	ranking := newSearchRanking(terms, limit)
+	matches := 0
+	err := s.repo.Search(ctx, terms, func(u domain.User) {
+		matches++
+		ranking.add(u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "users searched", "terms", len(terms), "matches", matches, "results", len(ranking.results))
+	return ranking.results, nil
+}
+
 // Update actualiza los datos de un usuario existente.
 func (s *service) Update(ctx context.Context, id uint64, firstName, lastName, email *string) error {
-	// Delega la actualización del usuario al repositorio.
-	if err := s.repo.Update(ctx, id, firstName, lastName, email); err != nil {
+	if err := s.requireTwoFactor(ctx, id); err != nil {
+		return err
+	}
+
+	// Delega la actualización del usuario al repositorio y registra la auditoría en la misma transacción. Si el
+	// correo cambió, el token de verificación del nuevo correo se guarda también en la transacción.
+	var (
+		newEmail string
+		token    string
+	)
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		before, err := tx.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(ctx, id, firstName, lastName, email); err != nil {
+			return err
+		}
+		after, err := tx.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.audit(ctx, tx, domain.AuditActionUpdate, id, before, after); err != nil {
+			return err
+		}
+		if err := s.publish(ctx, tx, domain.EventUserUpdated, id, after); err != nil {
+			return err
+		}
+
+		// Solo se debe volver a verificar el correo si su valor cambió.
+		if after.Email == before.Email {
+			return nil
+		}
+		newEmail = after.Email
+		token, err = s.saveVerification(ctx, tx, id, newEmail)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
 	// Registra un mensaje en el logger indicando la actualización del usuario.
-	s.log.Println("Se ha actualizado el usuario")
+	s.log.InfoContext(ctx, "user updated", "user_id", id)
+
+	// Envía el correo de verificación al nuevo correo electrónico; una falla en el envío no revierte la actualización.
+	s.sendVerification(ctx, id, newEmail, token)
 
 	return nil
 }
 
 // Delete elimina un usuario por su ID.
 func (s *service) Delete(ctx context.Context, id uint64) (*domain.User, error) {
-	// Intenta eliminar el usuario utilizando el repositorio
-	_, err := s.repo.Delete(ctx, id)
+	if err := s.requireTwoFactor(ctx, id); err != nil {
+		return nil, err
+	}
+
+	// Intenta eliminar el usuario utilizando el repositorio y registra la auditoría en la misma transacción.
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		before, err := tx.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := s.audit(ctx, tx, domain.AuditActionDelete, id, before, nil); err != nil {
+			return err
+		}
+		return s.publish(ctx, tx, domain.EventUserDeleted, id, before)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Registra un mensaje en el logger indicando la actualización del usuario.
-	s.log.Println("Se ha eliminado el usuario")
+	s.log.InfoContext(ctx, "user deleted", "user_id", id)
 
 	// Si no hay errores, devolvemos el usuario eliminado
 	return nil, nil
 }
 
+// VerifyEmail confirma el correo electrónico del usuario al que pertenece el token.
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	// El repositorio solo almacena el hash del token, nunca el token original.
+	id, err := s.repo.VerifyEmail(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	s.log.InfoContext(ctx, "email verified", "user_id", id)
+	return nil
+}
+
+// saveVerification genera un nuevo token de verificación para el correo del usuario y guarda su hash utilizando la
+// transacción proporcionada. Devuelve el token, o una cadena vacía si el usuario no tiene correo.
+func (s *service) saveVerification(ctx context.Context, tx Repository, id uint64, email string) (string, error) {
+	// Sin correo electrónico no hay nada que verificar.
+	if email == "" {
+		return "", nil
+	}
+
+	// Genera un token aleatorio de 32 bytes.
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	// Guarda el hash del token junto con su fecha de expiración.
+	if err := tx.SaveVerificationToken(ctx, id, email, hashToken(token), time.Now().Add(verificationTokenTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendVerification envía el enlace de verificación con el token al correo del usuario a través del Mailer.
+// Se ejecuta después de confirmar la transacción, por lo que una falla en el envío solo se registra en el log.
+func (s *service) sendVerification(ctx context.Context, id uint64, email, token string) {
+	if token == "" {
+		return
+	}
+
+	link := fmt.Sprintf("%s?token=%s", s.verifyURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Please confirm your email address by visiting the following link: %s", link),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.log.ErrorContext(ctx, "verification email failed", "user_id", id, "error", err)
+		return
+	}
+
+	s.log.InfoContext(ctx, "verification email sent", "user_id", id)
+}
+
+// EnrollTwoFactor genera un nuevo secreto TOTP pendiente de confirmación para el usuario.
+func (s *service) EnrollTwoFactor(ctx context.Context, id uint64) (*TwoFactorEnrollment, error) {
+	user, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	// Genera y guarda el secreto, que queda pendiente hasta que el usuario confirme un código.
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetTOTPSecret(ctx, id, secret); err != nil {
+		return nil, err
+	}
+
+	// Se utiliza el correo como nombre de la cuenta, o el ID si el usuario no tiene correo.
+	account := user.Email
+	if account == "" {
+		account = fmt.Sprint(user.ID)
+	}
+	uri := totp.URI(totpIssuer, account, secret)
+	qr, err := totp.QRCode(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "two-factor enrollment started", "user_id", id)
+	return &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: qr,
+	}, nil
+}
+
+// ConfirmTwoFactor activa la autenticación de dos factores si el código es válido y devuelve los códigos de recuperación.
+// Los códigos de recuperación solo se devuelven en este momento, ya que el repositorio almacena únicamente sus hashes.
+func (s *service) ConfirmTwoFactor(ctx context.Context, id uint64, code string) ([]string, error) {
+	secret, enabled, err := s.repo.GetTOTP(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if secret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := totp.Step(code, secret, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	// Genera los códigos de recuperación y guarda sus hashes.
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		h := hex.EncodeToString(b)
+		codes[i] = fmt.Sprintf("%s-%s", h[:5], h[5:])
+		hashes[i] = hashToken(codes[i])
+	}
+	// El período del código de confirmación queda registrado como utilizado, para que no sirva para verificar.
+	if err := s.repo.EnableTOTP(ctx, id, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "two-factor enabled", "user_id", id)
+	return codes, nil
+}
+
+// VerifyTwoFactor verifica un código TOTP o un código de recuperación como segundo factor del usuario.
+// Los códigos de recuperación solo pueden utilizarse una vez, y los códigos TOTP solo una vez por período: se rechaza
+// el código de un período igual o anterior al último aceptado, para que un código interceptado no pueda reutilizarse
+// mientras sigue vigente.
+//
+// El servicio no tiene un inicio de sesión propio: Update y Delete verifican el segundo factor con requireTwoFactor, y
+// quien autentica a los usuarios (por ejemplo, un proveedor de identidad) puede llamar a VerifyTwoFactor después de
+// verificar la contraseña de los usuarios que lo tienen activado.
+func (s *service) VerifyTwoFactor(ctx context.Context, id uint64, code string) error {
+	secret, enabled, err := s.repo.GetTOTP(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.ToLower(strings.TrimSpace(code))
+	if step, ok := totp.Step(code, secret, time.Now()); ok {
+		unused, err := s.repo.UseTOTPStep(ctx, id, step)
+		if err != nil {
+			return err
+		}
+		if !unused {
+			s.log.WarnContext(ctx, "totp code reused", "user_id", id)
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	// Si no es un código TOTP válido, se intenta como código de recuperación.
+	ok, err := s.repo.UseRecoveryCode(ctx, id, hashToken(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	s.log.WarnContext(ctx, "recovery code used", "user_id", id)
+	return nil
+}
+
+// requireTwoFactor verifica el código del segundo factor guardado en el contexto si el usuario tiene activada la
+// autenticación de dos factores. Devuelve ErrTwoFactorRequired si el contexto no tiene un código, y el error de
+// VerifyTwoFactor si no es válido; como cada código se acepta una sola vez, cada operación necesita uno propio.
+func (s *service) requireTwoFactor(ctx context.Context, id uint64) error {
+	_, enabled, err := s.repo.GetTOTP(ctx, id)
+	if err != nil || !enabled {
+		return err
+	}
+
+	code := requestctx.TwoFactorCode(ctx)
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+	return s.VerifyTwoFactor(ctx, id, code)
+}
+
+// DisableTwoFactor desactiva la autenticación de dos factores del usuario, previa verificación del código.
+func (s *service) DisableTwoFactor(ctx context.Context, id uint64, code string) error {
+	if err := s.VerifyTwoFactor(ctx, id, code); err != nil {
+		return err
+	}
+	if err := s.repo.DisableTOTP(ctx, id); err != nil {
+		return err
+	}
+
+	s.log.InfoContext(ctx, "two-factor disabled", "user_id", id)
+	return nil
+}
+
+// History devuelve el historial de cambios de un usuario, del más reciente al más antiguo.
+// No se verifica que el usuario exista, ya que el historial de los usuarios eliminados se conserva.
+func (s *service) History(ctx context.Context, id uint64) ([]domain.AuditEntry, error) {
+	return s.Audit(ctx, AuditFilters{UserID: &id})
+}
+
+// Audit devuelve los registros del log de auditoría que cumplen con los filtros.
+func (s *service) Audit(ctx context.Context, filters AuditFilters) ([]domain.AuditEntry, error) {
+	if filters.Limit <= 0 {
+		filters.Limit = defaultAuditLimit
+	}
+	if filters.Limit > maxAuditLimit {
+		filters.Limit = maxAuditLimit
+	}
+
+	entries, err := s.repo.GetAudit(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "audit entries listed", "count", len(entries))
+	return entries, nil
+}
+
+// audit guarda en el log de auditoría el cambio realizado sobre el usuario, utilizando la transacción proporcionada.
+func (s *service) audit(ctx context.Context, tx Repository, action string, id uint64, before, after *domain.User) error {
+	changes, err := diffUsers(before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.SaveAudit(ctx, &domain.AuditEntry{
+		Actor:         requestctx.Actor(ctx),
+		DeclaredActor: requestctx.DeclaredActor(ctx),
+		Action:        action,
+		UserID:        id,
+		Changes:       changes,
+		RequestID:     requestctx.RequestID(ctx),
+		CreatedAt:     time.Now().UTC(),
+	})
+}
+
+// publish guarda en el outbox el evento de dominio del cambio realizado, utilizando la transacción proporcionada.
+// El ID del usuario es la clave del evento, de modo que los eventos de un mismo usuario se entregan en orden.
+func (s *service) publish(ctx context.Context, tx Repository, eventType string, id uint64, user *domain.User) error {
+	payload, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return tx.SaveEvent(ctx, &outbox.Event{
+		Type:      eventType,
+		Key:       fmt.Sprint(id),
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// diffUsers devuelve los campos que difieren entre las dos versiones del usuario, con su valor anterior y nuevo.
+// Los campos se identifican por su nombre en JSON; una versión nil representa un usuario inexistente.
+func diffUsers(before, after *domain.User) (map[string]domain.FieldChange, error) {
+	b, err := userFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := userFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]domain.FieldChange{}
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			changes[k] = domain.FieldChange{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = domain.FieldChange{After: v}
+		}
+	}
+	return changes, nil
+}
+
+// userFields devuelve los campos del usuario indexados por su nombre en JSON.
+func userFields(u *domain.User) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if u == nil {
+		return fields, nil
+	}
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// hashToken devuelve el hash SHA-256 en hexadecimal del token proporcionado.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 /*
 Capa de servicio (Service):
 
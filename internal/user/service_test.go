@@ -0,0 +1,78 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/totp"
+)
+
+// errTxStarted indica que la operación pasó la verificación del segundo factor y llegó a la transacción.
+var errTxStarted = errors.New("transaction started")
+
+// twoFactorRepo es un Repository que solo implementa lo necesario para verificar el segundo factor de un usuario con
+// la autenticación de dos factores activada.
+type twoFactorRepo struct {
+	Repository
+	secret string
+}
+
+func (r twoFactorRepo) GetTOTP(context.Context, uint64) (string, bool, error) {
+	return r.secret, true, nil
+}
+
+func (r twoFactorRepo) UseTOTPStep(context.Context, uint64, uint64) (bool, error) {
+	return true, nil
+}
+
+func (r twoFactorRepo) UseRecoveryCode(context.Context, uint64, string) (bool, error) {
+	return false, nil
+}
+
+func (r twoFactorRepo) WithTx(context.Context, func(tx Repository) error) error {
+	return errTxStarted
+}
+
+// TestUpdateAndDeleteRequireTwoFactor verifica que la modificación y la eliminación de un usuario con la autenticación
+// de dos factores activada exijan un código válido en el contexto.
+func TestUpdateAndDeleteRequireTwoFactor(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(slog.New(slog.NewTextHandler(io.Discard, nil)), twoFactorRepo{secret: secret}, nil, "")
+	name := "Ana"
+
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"without code", "", ErrTwoFactorRequired},
+		{"invalid code", "000000x", ErrInvalidTwoFactorCode},
+		{"valid code", code, errTxStarted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.code != "" {
+				ctx = requestctx.WithTwoFactorCode(ctx, tt.code)
+			}
+			if err := s.Update(ctx, 1, &name, nil, nil); !errors.Is(err, tt.want) {
+				t.Errorf("Update() error = %v, want %v", err, tt.want)
+			}
+			if _, err := s.Delete(ctx, 1); !errors.Is(err, tt.want) {
+				t.Errorf("Delete() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
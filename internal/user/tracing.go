@@ -0,0 +1,258 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName es el nombre del tracer con el que se crean los spans del paquete.
+const tracerName = "github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+
+// tracedService es un decorador de Service que crea un span por cada método.
+type tracedService struct {
+	next Service
+}
+
+// NewTracedService devuelve un Service que delega en next y crea un span por cada llamada.
+func NewTracedService(next Service) Service {
+	return &tracedService{next: next}
+}
+
+func (s *tracedService) Create(ctx context.Context, firstName, lastName, email string) (user *domain.User, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Create")
+	defer func() { tracing.End(span, err) }()
+	return s.next.Create(ctx, firstName, lastName, email)
+}
+
+func (s *tracedService) GetAll(ctx context.Context, filters Filters) (users []domain.User, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/GetAll")
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetAll(ctx, filters)
+}
+
+func (s *tracedService) Get(ctx context.Context, id uint64) (user *domain.User, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Get", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.Get(ctx, id)
+}
+
+func (s *tracedService) GetMany(ctx context.Context, ids []uint64) (users []domain.User, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/GetMany", userIDs(ids))
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetMany(ctx, ids)
+}
+
+func (s *tracedService) Search(ctx context.Context, query string, limit int) (results []SearchResult, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Search")
+	defer func() { tracing.End(span, err) }()
+	return s.next.Search(ctx, query, limit)
+}
+
+func (s *tracedService) Update(ctx context.Context, id uint64, firstName, lastName, email *string) (err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Update", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.Update(ctx, id, firstName, lastName, email)
+}
+
+func (s *tracedService) Delete(ctx context.Context, id uint64) (user *domain.User, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Delete", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.Delete(ctx, id)
+}
+
+func (s *tracedService) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+	return s.next.VerifyEmail(ctx, token)
+}
+
+func (s *tracedService) EnrollTwoFactor(ctx context.Context, id uint64) (enrollment *TwoFactorEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/EnrollTwoFactor", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.EnrollTwoFactor(ctx, id)
+}
+
+func (s *tracedService) ConfirmTwoFactor(ctx context.Context, id uint64, code string) (codes []string, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/ConfirmTwoFactor", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.ConfirmTwoFactor(ctx, id, code)
+}
+
+func (s *tracedService) VerifyTwoFactor(ctx context.Context, id uint64, code string) (err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/VerifyTwoFactor", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.VerifyTwoFactor(ctx, id, code)
+}
+
+func (s *tracedService) DisableTwoFactor(ctx context.Context, id uint64, code string) (err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/DisableTwoFactor", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.DisableTwoFactor(ctx, id, code)
+}
+
+func (s *tracedService) History(ctx context.Context, id uint64) (entries []domain.AuditEntry, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/History", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return s.next.History(ctx, id)
+}
+
+func (s *tracedService) Audit(ctx context.Context, filters AuditFilters) (entries []domain.AuditEntry, err error) {
+	ctx, span := tracing.Start(ctx, tracerName, "user.Service/Audit")
+	defer func() { tracing.End(span, err) }()
+	return s.next.Audit(ctx, filters)
+}
+
+// tracedRepo es un decorador de Repository que crea un span por cada método, es decir, por cada acceso a la base de datos.
+type tracedRepo struct {
+	next Repository
+}
+
+// NewTracedRepo devuelve un Repository que delega en next y crea un span por cada llamada.
+func NewTracedRepo(next Repository) Repository {
+	return &tracedRepo{next: next}
+}
+
+// WithTx crea un span para toda la transacción; el repositorio de la transacción también se traza.
+func (r *tracedRepo) WithTx(ctx context.Context, fn func(tx Repository) error) (err error) {
+	ctx, span := r.start(ctx, "WithTx")
+	defer func() { tracing.End(span, err) }()
+	return r.next.WithTx(ctx, func(tx Repository) error {
+		return fn(&tracedRepo{next: tx})
+	})
+}
+
+func (r *tracedRepo) Create(ctx context.Context, user *domain.User) (err error) {
+	ctx, span := r.start(ctx, "Create")
+	defer func() { tracing.End(span, err) }()
+	return r.next.Create(ctx, user)
+}
+
+func (r *tracedRepo) GetAll(ctx context.Context, filters Filters) (users []domain.User, err error) {
+	ctx, span := r.start(ctx, "GetAll")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetAll(ctx, filters)
+}
+
+func (r *tracedRepo) Get(ctx context.Context, id uint64) (user *domain.User, err error) {
+	ctx, span := r.start(ctx, "Get", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.Get(ctx, id)
+}
+
+func (r *tracedRepo) GetMany(ctx context.Context, ids []uint64) (users []domain.User, err error) {
+	ctx, span := r.start(ctx, "GetMany", userIDs(ids))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMany(ctx, ids)
+}
+
+func (r *tracedRepo) Search(ctx context.Context, terms []string, fn func(domain.User)) (err error) {
+	ctx, span := r.start(ctx, "Search")
+	defer func() { tracing.End(span, err) }()
+	return r.next.Search(ctx, terms, fn)
+}
+
+func (r *tracedRepo) BackfillSearchText(ctx context.Context) (n int, err error) {
+	ctx, span := r.start(ctx, "BackfillSearchText")
+	defer func() { tracing.End(span, err) }()
+	return r.next.BackfillSearchText(ctx)
+}
+
+func (r *tracedRepo) Update(ctx context.Context, id uint64, firstName, lastName, email *string) (err error) {
+	ctx, span := r.start(ctx, "Update", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.Update(ctx, id, firstName, lastName, email)
+}
+
+func (r *tracedRepo) Delete(ctx context.Context, id uint64) (user *domain.User, err error) {
+	ctx, span := r.start(ctx, "Delete", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *tracedRepo) SaveVerificationToken(ctx context.Context, id uint64, email, tokenHash string, expiresAt time.Time) (err error) {
+	ctx, span := r.start(ctx, "SaveVerificationToken", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SaveVerificationToken(ctx, id, email, tokenHash, expiresAt)
+}
+
+func (r *tracedRepo) VerifyEmail(ctx context.Context, tokenHash string) (id uint64, err error) {
+	ctx, span := r.start(ctx, "VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+	return r.next.VerifyEmail(ctx, tokenHash)
+}
+
+func (r *tracedRepo) GetTOTP(ctx context.Context, id uint64) (secret string, enabled bool, err error) {
+	ctx, span := r.start(ctx, "GetTOTP", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetTOTP(ctx, id)
+}
+
+func (r *tracedRepo) SetTOTPSecret(ctx context.Context, id uint64, secret string) (err error) {
+	ctx, span := r.start(ctx, "SetTOTPSecret", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SetTOTPSecret(ctx, id, secret)
+}
+
+func (r *tracedRepo) EnableTOTP(ctx context.Context, id uint64, step uint64, recoveryCodeHashes []string) (err error) {
+	ctx, span := r.start(ctx, "EnableTOTP", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.EnableTOTP(ctx, id, step, recoveryCodeHashes)
+}
+
+func (r *tracedRepo) DisableTOTP(ctx context.Context, id uint64) (err error) {
+	ctx, span := r.start(ctx, "DisableTOTP", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.DisableTOTP(ctx, id)
+}
+
+func (r *tracedRepo) UseTOTPStep(ctx context.Context, id uint64, step uint64) (ok bool, err error) {
+	ctx, span := r.start(ctx, "UseTOTPStep", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UseTOTPStep(ctx, id, step)
+}
+
+func (r *tracedRepo) UseRecoveryCode(ctx context.Context, id uint64, codeHash string) (ok bool, err error) {
+	ctx, span := r.start(ctx, "UseRecoveryCode", userID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UseRecoveryCode(ctx, id, codeHash)
+}
+
+func (r *tracedRepo) SaveAudit(ctx context.Context, entry *domain.AuditEntry) (err error) {
+	ctx, span := r.start(ctx, "SaveAudit", userID(entry.UserID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SaveAudit(ctx, entry)
+}
+
+func (r *tracedRepo) GetAudit(ctx context.Context, filters AuditFilters) (entries []domain.AuditEntry, err error) {
+	ctx, span := r.start(ctx, "GetAudit")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetAudit(ctx, filters)
+}
+
+func (r *tracedRepo) SaveEvent(ctx context.Context, event *outbox.Event) (err error) {
+	ctx, span := r.start(ctx, "SaveEvent", attribute.String("event.type", event.Type))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SaveEvent(ctx, event)
+}
+
+// start inicia el span de cliente de base de datos de un método del repositorio.
+func (r *tracedRepo) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "mysql"), attribute.String("db.operation", method))
+	return tracing.Start(ctx, tracerName, "user.Repository/"+method, attrs...)
+}
+
+// userID devuelve el atributo del span con el ID del usuario.
+func userID(id uint64) attribute.KeyValue {
+	return attribute.Int64("user.id", int64(id))
+}
+
+// userIDs devuelve el atributo con la cantidad de IDs de usuario de una consulta por lotes.
+func userIDs(ids []uint64) attribute.KeyValue {
+	return attribute.Int("user.ids.count", len(ids))
+}
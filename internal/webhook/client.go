@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// internalPrefixes son los rangos de direcciones internas que netip.Addr no clasifica por sí misma.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "Esta red", que algunos sistemas enrutan a la propia máquina
+	netip.MustParsePrefix("100.64.0.0/10"), // Espacio compartido de CGNAT
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, que traduce a direcciones IPv4 que pueden ser internas
+}
+
+// NewClient devuelve el cliente HTTP con el que se envían las entregas de webhooks. Salvo que allowPrivate sea true,
+// el cliente se niega a conectarse a direcciones de loopback, privadas, de enlace local o no especificadas. La
+// dirección se verifica al conectarse, después de resolver el nombre, de modo que tampoco se alcanzan los servicios
+// internos con un nombre que resuelve a una dirección interna, que cambia de dirección después de crear la
+// suscripción o con una redirección.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if internalAddr(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateURL, ip)
+			}
+			return nil
+		}
+		// Con un proxy, el cliente se conectaría al proxy y no a la dirección de la suscripción.
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// internalAddr indica si la dirección es de loopback, privada, de enlace local, no especificada o multicast.
+func internalAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, p := range internalPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// internalHost indica si el host de una URL es una dirección interna o un nombre de la propia máquina. Los demás
+// nombres se verifican al conectarse (ver NewClient).
+func internalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	return err == nil && internalAddr(ip)
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+)
+
+// Definición de tipos
+
+type (
+	// Controller: Define un tipo alias para una función que recibe el contexto y la solicitud decodificada y devuelve la respuesta.
+	Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+	// Endpoints: Define una estructura `Endpoints` que agrupa los controladores para los endpoints de webhooks.
+	Endpoints struct {
+		Create        Controller // Controlador para el endpoint de creación de suscripciones.
+		GetAll        Controller // Controlador para el endpoint de obtención de todas las suscripciones.
+		Get           Controller // Controlador para el endpoint de obtención de una suscripción por ID.
+		Update        Controller // Controlador para el endpoint de actualización de una suscripción por ID.
+		Delete        Controller // Controlador para el endpoint de eliminación de una suscripción por ID.
+		Deliveries    Controller // Controlador para el endpoint del log de entregas de una suscripción.
+		RetryDelivery Controller // Controlador para el endpoint de reintento de una entrega.
+	}
+
+	// GetReq: Define una estructura `GetReq` para representar la solicitud de una suscripción por ID.
+	GetReq struct {
+		ID uint64 // ID de la suscripción
+	}
+
+	// CreateReq: Define una estructura `CreateReq` para representar la solicitud de creación de una suscripción.
+	CreateReq struct {
+		URL        string   `json:"url"`         // URL a la que se envían los eventos
+		EventTypes []string `json:"event_types"` // Tipos de eventos a los que se suscribe
+		Secret     string   `json:"secret"`      // Secreto para firmar las entregas (opcional)
+	}
+
+	// UpdateReq: Define una estructura `UpdateReq` para representar la solicitud de actualización de una suscripción.
+	UpdateReq struct {
+		ID         uint64   // ID de la suscripción a actualizar
+		URL        *string  `json:"url"`         // Nueva URL
+		EventTypes []string `json:"event_types"` // Nuevos tipos de eventos
+		Secret     *string  `json:"secret"`      // Nuevo secreto
+		Active     *bool    `json:"active"`      // Activa o desactiva la suscripción
+	}
+
+	// DeleteReq: Define una estructura `DeleteReq` para representar la solicitud de eliminación de una suscripción.
+	DeleteReq struct {
+		ID uint64 // ID de la suscripción a eliminar
+	}
+
+	// DeliveriesReq: Define una estructura `DeliveriesReq` para representar la solicitud del log de entregas de una suscripción.
+	DeliveriesReq struct {
+		ID uint64 // ID de la suscripción
+	}
+
+	// RetryDeliveryReq: Define una estructura `RetryDeliveryReq` para representar la solicitud de reintento de una entrega.
+	RetryDeliveryReq struct {
+		ID uint64 // ID de la entrega
+	}
+)
+
+// MakeEndpoints crea los endpoints de webhooks y asigna los controladores correspondientes.
+func MakeEndpoints(s Service) Endpoints {
+	return Endpoints{
+		Create:        makeCreateEndpoint(s),
+		GetAll:        makeGetAllEndpoint(s),
+		Get:           makeGetEndpoint(s),
+		Update:        makeUpdateEndpoint(s),
+		Delete:        makeDeleteEndpoint(s),
+		Deliveries:    makeDeliveriesEndpoint(s),
+		RetryDelivery: makeRetryDeliveryEndpoint(s),
+	}
+}
+
+// makeCreateEndpoint crea un controlador para el endpoint de creación de suscripciones.
+func makeCreateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateReq)
+
+		sub, err := s.Create(ctx, req.URL, req.EventTypes, req.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return response.Created("success", sub), nil
+	}
+}
+
+// makeGetAllEndpoint crea un controlador para el endpoint de obtención de todas las suscripciones.
+func makeGetAllEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		subs, err := s.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", subs), nil
+	}
+}
+
+// makeGetEndpoint crea un controlador para el endpoint de obtención de una suscripción por ID.
+func makeGetEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		sub, err := s.Get(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", sub), nil
+	}
+}
+
+// makeUpdateEndpoint crea un controlador para el endpoint de actualización de una suscripción por ID.
+func makeUpdateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UpdateReq)
+
+		if err := s.Update(ctx, req.ID, req.URL, req.EventTypes, req.Secret, req.Active); err != nil {
+			return nil, err
+		}
+		return response.OK("success", nil), nil
+	}
+}
+
+// makeDeleteEndpoint crea un controlador para el endpoint de eliminación de una suscripción por ID.
+func makeDeleteEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteReq)
+
+		if err := s.Delete(ctx, req.ID); err != nil {
+			return nil, err
+		}
+		return response.OK("webhook deleted successfully", nil), nil
+	}
+}
+
+// makeDeliveriesEndpoint crea un controlador para el endpoint del log de entregas de una suscripción.
+func makeDeliveriesEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeliveriesReq)
+
+		deliveries, err := s.Deliveries(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return response.OK("success", deliveries), nil
+	}
+}
+
+// makeRetryDeliveryEndpoint crea un controlador para el endpoint de reintento de una entrega.
+func makeRetryDeliveryEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RetryDeliveryReq)
+
+		if err := s.RetryDelivery(ctx, req.ID); err != nil {
+			return nil, err
+		}
+		return response.Accepted("delivery scheduled", nil), nil
+	}
+}
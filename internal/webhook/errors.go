@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+)
+
+// ErrURLRequired se produce cuando se intenta crear una suscripción sin proporcionar una URL.
+var ErrURLRequired = errors.New("url is required")
+
+// ErrInvalidURL se produce cuando la URL de la suscripción no es una URL http o https absoluta.
+var ErrInvalidURL = errors.New("url must be an absolute http or https url")
+
+// ErrPrivateURL se produce cuando la URL de la suscripción apunta a una dirección interna o de loopback y no se
+// permiten las redes privadas (WEBHOOKS_ALLOW_PRIVATE_NETWORKS).
+var ErrPrivateURL = errors.New("url must not point to a loopback, private or link-local address")
+
+// ErrEventTypesRequired se produce cuando se intenta crear una suscripción sin tipos de eventos.
+var ErrEventTypesRequired = errors.New("event types are required")
+
+// ErrThereArentFields se utiliza cuando no se proporcionan campos para actualizar una suscripción.
+var ErrThereArentFields = errors.New("there aren't fields")
+
+// ErrInvalidEventType se produce cuando la suscripción incluye un tipo de evento desconocido.
+type ErrInvalidEventType struct {
+	EventType string // Tipo de evento desconocido.
+}
+
+// Error implementa el método Error de la interfaz error para la estructura ErrInvalidEventType.
+func (e ErrInvalidEventType) Error() string {
+	return fmt.Sprintf("event type '%s' is not valid", e.EventType)
+}
+
+// ErrNotFound se utiliza cuando no se encuentra una suscripción en la base de datos.
+type ErrNotFound struct {
+	ID uint64 // ID de la suscripción que no se encontró.
+}
+
+// Error implementa el método Error de la interfaz error para la estructura ErrNotFound.
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("webhook id '%d' doesn`t exist", e.ID)
+}
+
+// ErrDeliveryNotFound se utiliza cuando no se encuentra una entrega en la base de datos.
+type ErrDeliveryNotFound struct {
+	ID uint64 // ID de la entrega que no se encontró.
+}
+
+// Error implementa el método Error de la interfaz error para la estructura ErrDeliveryNotFound.
+func (e ErrDeliveryNotFound) Error() string {
+	return fmt.Sprintf("delivery id '%d' doesn`t exist", e.ID)
+}
+
+// init registra los errores de webhooks con su código de estado HTTP y su código de error estable, para que
+// encodeError los convierta en la respuesta correspondiente en todas las rutas.
+func init() {
+	apierror.Register(ErrURLRequired, http.StatusBadRequest, "url_required")
+	apierror.Register(ErrInvalidURL, http.StatusBadRequest, "invalid_url")
+	apierror.Register(ErrPrivateURL, http.StatusBadRequest, "private_url")
+	apierror.Register(ErrEventTypesRequired, http.StatusBadRequest, "event_types_required")
+	apierror.Register(ErrThereArentFields, http.StatusBadRequest, "no_fields_to_update")
+	apierror.RegisterType[ErrInvalidEventType](http.StatusBadRequest, "invalid_event_type")
+	apierror.RegisterType[ErrNotFound](http.StatusNotFound, "webhook_not_found")
+	apierror.RegisterType[ErrDeliveryNotFound](http.StatusNotFound, "delivery_not_found")
+}
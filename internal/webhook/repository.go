@@ -0,0 +1,366 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+)
+
+// Repository define las operaciones que debe implementar un repositorio de suscripciones y entregas de webhooks.
+type Repository interface {
+	// Create crea una nueva suscripción.
+	Create(ctx context.Context, sub *domain.WebhookSubscription) error
+	// GetAll devuelve todas las suscripciones.
+	GetAll(ctx context.Context) ([]domain.WebhookSubscription, error)
+	// Get devuelve una suscripción específica basada en su ID.
+	Get(ctx context.Context, id uint64) (*domain.WebhookSubscription, error)
+	// Update actualiza los datos de una suscripción existente.
+	Update(ctx context.Context, id uint64, url *string, eventTypes []string, secret *string, active *bool) error
+	// Delete elimina una suscripción y sus entregas.
+	Delete(ctx context.Context, id uint64) error
+	// GetActiveByEventType devuelve las suscripciones activas al tipo de evento indicado.
+	GetActiveByEventType(ctx context.Context, eventType string) ([]domain.WebhookSubscription, error)
+	// Enqueue guarda una entrega pendiente; si ya existe una entrega del mismo evento a la misma suscripción, no hace nada.
+	Enqueue(ctx context.Context, delivery *domain.WebhookDelivery) error
+	// GetDue devuelve hasta limit entregas pendientes cuyo próximo intento ya venció, ordenadas por ID.
+	GetDue(ctx context.Context, now time.Time, limit int) ([]domain.WebhookDelivery, error)
+	// Claim reserva una entrega pendiente cuyo próximo intento venció antes de now, postergando su próximo intento
+	// hasta until, e indica si la reservó; si otra instancia la reservó antes, devuelve false.
+	Claim(ctx context.Context, id uint64, now, until time.Time) (bool, error)
+	// GetDeliveries devuelve las entregas de una suscripción, de la más reciente a la más antigua.
+	GetDeliveries(ctx context.Context, subscriptionID uint64, limit int) ([]domain.WebhookDelivery, error)
+	// SaveAttempt guarda el resultado de un intento de entrega.
+	SaveAttempt(ctx context.Context, delivery *domain.WebhookDelivery) error
+	// Retry vuelve a dejar pendiente una entrega, reiniciando sus intentos.
+	Retry(ctx context.Context, id uint64) error
+}
+
+// repo es una implementación de la interfaz Repository.
+type repo struct {
+	db      *sql.DB       // Base de datos de webhooks
+	log     *slog.Logger  // Logger para registrar eventos
+	timeout time.Duration // Tiempo máximo de cada operación; cero indica sin límite propio
+}
+
+// NewRepo es una función constructora que devuelve una nueva instancia del repositorio.
+// timeout es el tiempo máximo de cada operación, además del plazo que ya tenga el contexto recibido.
+func NewRepo(db *sql.DB, l *slog.Logger, timeout time.Duration) Repository {
+	return &repo{
+		db:      db,
+		log:     l,
+		timeout: timeout,
+	}
+}
+
+// withTimeout devuelve un contexto derivado de ctx que vence al cumplirse el tiempo máximo de la operación.
+func (r *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// Create crea una nueva suscripción.
+func (r *repo) Create(ctx context.Context, sub *domain.WebhookSubscription) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	eventTypes, err := json.Marshal(sub.EventTypes)
+	if err != nil {
+		return err
+	}
+
+	sqlQ := "INSERT INTO webhook_subscriptions(url, event_types, secret, active, created_at) VALUES(?,?,?,?,?)"
+	res, err := r.db.ExecContext(ctx, sqlQ, sub.URL, eventTypes, sub.Secret, sub.Active, sub.CreatedAt)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Create", "error", err)
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Create", "error", err)
+		return err
+	}
+
+	sub.ID = uint64(id)
+	r.log.DebugContext(ctx, "webhook created", "webhook_id", id)
+	return nil
+}
+
+// GetAll devuelve todas las suscripciones.
+func (r *repo) GetAll(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.query(ctx, "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions")
+}
+
+// Get devuelve una suscripción específica basada en su ID.
+func (r *repo) Get(ctx context.Context, id uint64) (*domain.WebhookSubscription, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	subs, err := r.query(ctx, "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, ErrNotFound{id}
+	}
+	return &subs[0], nil
+}
+
+// Update actualiza los datos de una suscripción existente.
+func (r *repo) Update(ctx context.Context, id uint64, url *string, eventTypes []string, secret *string, active *bool) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var fields []string
+	var values []interface{}
+
+	if url != nil {
+		fields = append(fields, "url=?")
+		values = append(values, *url)
+	}
+	if eventTypes != nil {
+		b, err := json.Marshal(eventTypes)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, "event_types=?")
+		values = append(values, b)
+	}
+	if secret != nil {
+		fields = append(fields, "secret=?")
+		values = append(values, *secret)
+	}
+	if active != nil {
+		fields = append(fields, "active=?")
+		values = append(values, *active)
+	}
+	if len(fields) == 0 {
+		r.log.DebugContext(ctx, "update rejected", "error", ErrThereArentFields)
+		return ErrThereArentFields
+	}
+
+	// Se verifica la existencia por separado, ya que MySQL no cuenta como afectadas las filas que no cambian.
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+
+	values = append(values, id)
+	sqlQ := fmt.Sprintf("UPDATE webhook_subscriptions SET %s WHERE id=?", strings.Join(fields, ","))
+	if _, err := r.db.ExecContext(ctx, sqlQ, values...); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Update", "error", err)
+		return err
+	}
+
+	r.log.DebugContext(ctx, "webhook updated", "webhook_id", id)
+	return nil
+}
+
+// Delete elimina una suscripción; sus entregas se eliminan en cascada.
+func (r *repo) Delete(ctx context.Context, id uint64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Delete", "error", err)
+		return err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Delete", "error", err)
+		return err
+	}
+	if row == 0 {
+		return ErrNotFound{id}
+	}
+
+	r.log.DebugContext(ctx, "webhook deleted", "webhook_id", id)
+	return nil
+}
+
+// GetActiveByEventType devuelve las suscripciones activas al tipo de evento indicado.
+func (r *repo) GetActiveByEventType(ctx context.Context, eventType string) ([]domain.WebhookSubscription, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	subs, err := r.query(ctx, "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions WHERE active = ?", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []domain.WebhookSubscription
+	for _, s := range subs {
+		for _, t := range s.EventTypes {
+			if t == eventType {
+				matching = append(matching, s)
+				break
+			}
+		}
+	}
+	return matching, nil
+}
+
+// Enqueue guarda una entrega pendiente; si ya existe una entrega del mismo evento a la misma suscripción, no hace nada.
+// Esto hace que la recepción repetida de un evento del outbox no genere entregas duplicadas.
+func (r *repo) Enqueue(ctx context.Context, d *domain.WebhookDelivery) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "INSERT IGNORE INTO webhook_deliveries(subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at) VALUES(?,?,?,?,?,?,?,?)"
+	if _, err := r.db.ExecContext(ctx, sqlQ, d.SubscriptionID, d.EventID, d.EventType, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.CreatedAt); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Enqueue", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetDue devuelve hasta limit entregas pendientes cuyo próximo intento ya venció, ordenadas por ID.
+func (r *repo) GetDue(ctx context.Context, now time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.queryDeliveries(ctx, deliverySelect+" WHERE status = ? AND next_attempt_at <= ? ORDER BY id LIMIT ?", domain.DeliveryStatusPending, now, limit)
+}
+
+// Claim reserva una entrega pendiente cuyo próximo intento venció antes de now, postergando su próximo intento
+// hasta until, e indica si la reservó. La condición se evalúa en la misma sentencia que la modificación, de modo que
+// si dos instancias intentan reservar la misma entrega, solo una lo logra; MySQL 5.7 no admite SKIP LOCKED, por lo
+// que la reserva se hace entrega por entrega. Si la instancia se detiene antes de guardar el intento, la entrega
+// vuelve a estar pendiente al llegar until.
+func (r *repo) Claim(ctx context.Context, id uint64, now, until time.Time) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "UPDATE webhook_deliveries SET next_attempt_at=? WHERE id=? AND status=? AND next_attempt_at <= ?"
+	res, err := r.db.ExecContext(ctx, sqlQ, until, id, domain.DeliveryStatusPending, now)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Claim", "error", err)
+		return false, err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Claim", "error", err)
+		return false, err
+	}
+	return row > 0, nil
+}
+
+// GetDeliveries devuelve las entregas de una suscripción, de la más reciente a la más antigua.
+func (r *repo) GetDeliveries(ctx context.Context, subscriptionID uint64, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.queryDeliveries(ctx, deliverySelect+" WHERE subscription_id = ? ORDER BY id DESC LIMIT ?", subscriptionID, limit)
+}
+
+// SaveAttempt guarda el resultado de un intento de entrega.
+func (r *repo) SaveAttempt(ctx context.Context, d *domain.WebhookDelivery) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "UPDATE webhook_deliveries SET status=?, attempts=?, last_status_code=?, last_error=?, next_attempt_at=?, delivered_at=? WHERE id=?"
+	if _, err := r.db.ExecContext(ctx, sqlQ, d.Status, d.Attempts, d.LastStatusCode, d.LastError, d.NextAttemptAt, d.DeliveredAt, d.ID); err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "SaveAttempt", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Retry vuelve a dejar pendiente una entrega, reiniciando sus intentos.
+func (r *repo) Retry(ctx context.Context, id uint64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	sqlQ := "UPDATE webhook_deliveries SET status=?, attempts=0, next_attempt_at=?, delivered_at=NULL WHERE id=?"
+	res, err := r.db.ExecContext(ctx, sqlQ, domain.DeliveryStatusPending, time.Now().UTC(), id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Retry", "error", err)
+		return err
+	}
+	row, err := res.RowsAffected()
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "Retry", "error", err)
+		return err
+	}
+	if row == 0 {
+		return ErrDeliveryNotFound{id}
+	}
+
+	r.log.DebugContext(ctx, "webhook delivery retried", "delivery_id", id)
+	return nil
+}
+
+// query ejecuta una consulta de suscripciones y devuelve las filas obtenidas.
+func (r *repo) query(ctx context.Context, sqlQ string, args ...interface{}) ([]domain.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, sqlQ, args...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "query", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []domain.WebhookSubscription{}
+	for rows.Next() {
+		var (
+			s          domain.WebhookSubscription
+			eventTypes []byte
+		)
+		if err := rows.Scan(&s.ID, &s.URL, &eventTypes, &s.Secret, &s.Active, &s.CreatedAt); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "query", "error", err)
+			return nil, err
+		}
+		if err := json.Unmarshal(eventTypes, &s.EventTypes); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "query", "error", err)
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// deliverySelect es la consulta base para obtener entregas.
+const deliverySelect = "SELECT id, subscription_id, event_id, event_type, payload, status, attempts, last_status_code, last_error, next_attempt_at, delivered_at, created_at FROM webhook_deliveries"
+
+// queryDeliveries ejecuta una consulta de entregas y devuelve las filas obtenidas.
+func (r *repo) queryDeliveries(ctx context.Context, sqlQ string, args ...interface{}) ([]domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, sqlQ, args...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "query failed", "method", "queryDeliveries", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		var (
+			d           domain.WebhookDelivery
+			payload     []byte
+			nextAttempt sql.NullTime
+			deliveredAt sql.NullTime
+		)
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &d.LastError, &nextAttempt, &deliveredAt, &d.CreatedAt); err != nil {
+			r.log.ErrorContext(ctx, "query failed", "method", "queryDeliveries", "error", err)
+			return nil, err
+		}
+		d.Payload = payload
+		if nextAttempt.Valid {
+			d.NextAttemptAt = &nextAttempt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
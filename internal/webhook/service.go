@@ -0,0 +1,396 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+)
+
+const (
+	// Encabezados que acompañan a cada entrega.
+	SignatureHeader = "X-Webhook-Signature" // Firma HMAC-SHA256 de "<timestamp>.<cuerpo>" con el secreto de la suscripción
+	TimestampHeader = "X-Webhook-Timestamp" // Momento del envío en segundos Unix, incluido en la firma para evitar repeticiones
+	EventHeader     = "X-Webhook-Event"     // Tipo del evento entregado
+	DeliveryHeader  = "X-Webhook-Delivery"  // ID de la entrega, que se repite en cada reintento
+
+	maxAttempts      = 8                // Cantidad de intentos antes de pasar la entrega a dead letter
+	claimDuration    = time.Minute      // Tiempo durante el cual una instancia reserva una entrega para enviarla
+	minBackoff       = 10 * time.Second // Espera antes del primer reintento
+	maxBackoff       = time.Hour        // Espera máxima entre reintentos
+	deliveryBatch    = 100              // Cantidad máxima de entregas que se procesan en cada pasada
+	deliveriesLimit  = 100              // Cantidad de entregas que devuelve el log de entregas
+	maxErrorLength   = 1000             // Longitud máxima del error que se guarda de cada intento
+	generatedSecretN = 32               // Cantidad de bytes aleatorios de los secretos generados
+)
+
+// eventTypes contiene los tipos de eventos a los que se puede suscribir un webhook.
+var eventTypes = map[string]bool{
+	domain.EventUserCreated: true,
+	domain.EventUserUpdated: true,
+	domain.EventUserDeleted: true,
+}
+
+// Service define la interfaz del servicio de webhooks.
+// También implementa outbox.Sink: cada evento recibido genera una entrega por cada suscripción activa a su tipo.
+type Service interface {
+	outbox.Sink
+
+	// Create crea una nueva suscripción. Si no se proporciona un secreto, se genera uno aleatorio.
+	Create(ctx context.Context, url string, eventTypes []string, secret string) (*domain.WebhookSubscription, error)
+	// GetAll devuelve todas las suscripciones.
+	GetAll(ctx context.Context) ([]domain.WebhookSubscription, error)
+	// Get devuelve una suscripción específica basada en su ID.
+	Get(ctx context.Context, id uint64) (*domain.WebhookSubscription, error)
+	// Update actualiza los datos de una suscripción existente.
+	Update(ctx context.Context, id uint64, url *string, eventTypes []string, secret *string, active *bool) error
+	// Delete elimina una suscripción y sus entregas.
+	Delete(ctx context.Context, id uint64) error
+	// Deliveries devuelve el log de entregas de una suscripción.
+	Deliveries(ctx context.Context, id uint64) ([]domain.WebhookDelivery, error)
+	// RetryDelivery vuelve a dejar pendiente una entrega, por ejemplo una que pasó a dead letter.
+	RetryDelivery(ctx context.Context, id uint64) error
+	// DeliverDue realiza una pasada de envío de las entregas pendientes cuyo próximo intento ya venció.
+	DeliverDue(ctx context.Context) error
+}
+
+// Config agrupa la configuración del servicio de webhooks.
+type Config struct {
+	// AllowPrivateNetworks permite que las suscripciones apunten a direcciones de loopback, privadas o de enlace
+	// local. Para que tampoco se envíen entregas a esas direcciones, el cliente debe crearse con NewClient.
+	AllowPrivateNetworks bool
+}
+
+// service es una implementación del servicio de webhooks.
+type service struct {
+	log    *slog.Logger // Instancia del logger para registrar mensajes.
+	repo   Repository   // Instancia del repositorio de webhooks.
+	client *http.Client // Cliente HTTP utilizado para enviar las entregas.
+	cfg    Config       // Configuración del servicio.
+}
+
+// NewService es una función constructora que devuelve una nueva instancia del servicio de webhooks.
+func NewService(l *slog.Logger, repo Repository, client *http.Client, cfg Config) Service {
+	return &service{
+		log:    l,
+		repo:   repo,
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// Create crea una nueva suscripción. Si no se proporciona un secreto, se genera uno aleatorio.
+// El secreto solo se devuelve en la respuesta de la creación.
+func (s *service) Create(ctx context.Context, url string, eventTypes []string, secret string) (*domain.WebhookSubscription, error) {
+	if err := s.validateURL(url); err != nil {
+		return nil, err
+	}
+	if err := validateEventTypes(eventTypes); err != nil {
+		return nil, err
+	}
+
+	if secret == "" {
+		b := make([]byte, generatedSecretN)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	sub := &domain.WebhookSubscription{
+		URL:        url,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "webhook created", "webhook_id", sub.ID)
+	return sub, nil
+}
+
+// GetAll devuelve todas las suscripciones, sin sus secretos.
+func (s *service) GetAll(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	subs, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+
+	s.log.InfoContext(ctx, "webhooks listed", "count", len(subs))
+	return subs, nil
+}
+
+// Get devuelve una suscripción específica basada en su ID, sin su secreto.
+func (s *service) Get(ctx context.Context, id uint64) (*domain.WebhookSubscription, error) {
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+
+	s.log.InfoContext(ctx, "webhook fetched", "webhook_id", id)
+	return sub, nil
+}
+
+// Update actualiza los datos de una suscripción existente.
+func (s *service) Update(ctx context.Context, id uint64, url *string, eventTypes []string, secret *string, active *bool) error {
+	if url != nil {
+		if err := s.validateURL(*url); err != nil {
+			return err
+		}
+	}
+	if eventTypes != nil {
+		if err := validateEventTypes(eventTypes); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Update(ctx, id, url, eventTypes, secret, active); err != nil {
+		return err
+	}
+
+	s.log.InfoContext(ctx, "webhook updated", "webhook_id", id)
+	return nil
+}
+
+// Delete elimina una suscripción y sus entregas.
+func (s *service) Delete(ctx context.Context, id uint64) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.log.InfoContext(ctx, "webhook deleted", "webhook_id", id)
+	return nil
+}
+
+// Deliveries devuelve el log de entregas de una suscripción, de la más reciente a la más antigua.
+func (s *service) Deliveries(ctx context.Context, id uint64) ([]domain.WebhookDelivery, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(ctx, id, deliveriesLimit)
+}
+
+// RetryDelivery vuelve a dejar pendiente una entrega, por ejemplo una que pasó a dead letter.
+func (s *service) RetryDelivery(ctx context.Context, id uint64) error {
+	return s.repo.Retry(ctx, id)
+}
+
+// Publish genera una entrega pendiente del evento por cada suscripción activa a su tipo.
+func (s *service) Publish(ctx context.Context, e outbox.Event) error {
+	subs, err := s.repo.GetActiveByEventType(ctx, e.Type)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, sub := range subs {
+		d := &domain.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+			Status:         domain.DeliveryStatusPending,
+			NextAttemptAt:  &now,
+			CreatedAt:      now,
+		}
+		if err := s.repo.Enqueue(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverDue realiza una pasada de envío de las entregas pendientes cuyo próximo intento ya venció.
+// Antes de enviar cada entrega la reserva con Claim, de modo que si varias instancias realizan una pasada a la vez,
+// cada entrega la envía una sola de ellas. Los errores de una entrega no interrumpen la pasada: se registran en el
+// log y se devuelven todos juntos al final.
+func (s *service) DeliverDue(ctx context.Context) error {
+	deliveries, err := s.repo.GetDue(ctx, time.Now().UTC(), deliveryBatch)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	subs := map[uint64]*domain.WebhookSubscription{}
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		d := &deliveries[i]
+
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			if sub, err = s.repo.Get(ctx, d.SubscriptionID); err != nil {
+				// La entrega sigue pendiente y se vuelve a intentar en la próxima pasada.
+				s.log.ErrorContext(ctx, "webhook subscription lookup failed", "delivery_id", d.ID, "webhook_id", d.SubscriptionID, "error", err)
+				errs = append(errs, err)
+				continue
+			}
+			subs[d.SubscriptionID] = sub
+		}
+
+		now := time.Now().UTC()
+		claimed, err := s.repo.Claim(ctx, d.ID, now, now.Add(claimDuration))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			// Otra instancia la reservó después de que se obtuvo.
+			continue
+		}
+
+		s.attempt(ctx, sub, d)
+		if err := s.repo.SaveAttempt(ctx, d); err != nil {
+			// La reserva vence y la entrega se vuelve a enviar, lo que admite la semántica al menos una vez.
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// attempt envía la entrega a la URL de la suscripción y actualiza su estado según el resultado.
+func (s *service) attempt(ctx context.Context, sub *domain.WebhookSubscription, d *domain.WebhookDelivery) {
+	d.Attempts++
+	status, err := s.send(ctx, sub, d)
+	d.LastStatusCode = status
+
+	now := time.Now().UTC()
+	if err == nil {
+		d.Status = domain.DeliveryStatusDelivered
+		d.LastError = ""
+		d.NextAttemptAt = nil
+		d.DeliveredAt = &now
+		return
+	}
+
+	d.LastError = err.Error()
+	if len(d.LastError) > maxErrorLength {
+		d.LastError = d.LastError[:maxErrorLength]
+	}
+
+	if d.Attempts >= maxAttempts {
+		d.Status = domain.DeliveryStatusDead
+		d.NextAttemptAt = nil
+		s.log.ErrorContext(ctx, "webhook delivery dead", "delivery_id", d.ID, "url", sub.URL, "attempts", d.Attempts, "error", d.LastError)
+		return
+	}
+
+	next := now.Add(backoff(d.Attempts))
+	d.NextAttemptAt = &next
+	s.log.WarnContext(ctx, "webhook delivery failed", "delivery_id", d.ID, "url", sub.URL, "attempts", d.Attempts, "next_attempt_at", next, "error", d.LastError)
+}
+
+// send realiza la solicitud HTTP de la entrega y devuelve el código de estado de la respuesta.
+func (s *service) send(ctx context.Context, sub *domain.WebhookSubscription, d *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(d.ID, 10))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign devuelve el valor del encabezado de firma para el cuerpo y el momento proporcionados.
+// Los receptores deben calcular la misma firma con su secreto y compararla con el encabezado X-Webhook-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff devuelve la espera antes del siguiente intento, que se duplica en cada intento fallido hasta maxBackoff.
+func backoff(attempts int) time.Duration {
+	wait := minBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// validateURL verifica que la URL de la suscripción sea una URL http o https absoluta y, salvo que se permitan las
+// redes privadas, que no apunte a la propia máquina ni a una dirección interna.
+func (s *service) validateURL(raw string) error {
+	if raw == "" {
+		return ErrURLRequired
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if !s.cfg.AllowPrivateNetworks && internalHost(u.Hostname()) {
+		return ErrPrivateURL
+	}
+	return nil
+}
+
+// validateEventTypes verifica que se indique al menos un tipo de evento y que todos sean conocidos.
+func validateEventTypes(types []string) error {
+	if len(types) == 0 {
+		return ErrEventTypesRequired
+	}
+	for _, t := range types {
+		if !eventTypes[t] {
+			return ErrInvalidEventType{t}
+		}
+	}
+	return nil
+}
+
+/*
+Capa de servicio (Service):
+
+Las entregas se generan a partir de los eventos del outbox y se envían en segundo plano mediante DeliverDue.
+Cada intento fallido se reintenta con espera exponencial y, tras maxAttempts intentos, la entrega pasa a dead letter.
+*/
@@ -0,0 +1,323 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+)
+
+// memoryRepo es un Repository en memoria para las pruebas del envío de entregas. Solo implementa los métodos que
+// usa DeliverDue; los demás no se llaman.
+type memoryRepo struct {
+	Repository
+
+	mu         sync.Mutex
+	subs       map[uint64]*domain.WebhookSubscription
+	deliveries map[uint64]*domain.WebhookDelivery
+	getErr     map[uint64]error // Errores que devuelve Get para cada suscripción
+	stolen     map[uint64]bool  // Entregas que reserva otra instancia entre GetDue y Claim
+}
+
+func (r *memoryRepo) Get(_ context.Context, id uint64) (*domain.WebhookSubscription, error) {
+	if err := r.getErr[id]; err != nil {
+		return nil, err
+	}
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound{id}
+	}
+	return sub, nil
+}
+
+func (r *memoryRepo) GetDue(_ context.Context, now time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []domain.WebhookDelivery
+	for id := uint64(1); id <= uint64(len(r.deliveries)) && len(due) < limit; id++ {
+		d := r.deliveries[id]
+		if d.Status == domain.DeliveryStatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, *d)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryRepo) Claim(_ context.Context, id uint64, now, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.deliveries[id]
+	if r.stolen[id] {
+		d.NextAttemptAt = &until
+	}
+	if d.Status != domain.DeliveryStatusPending || d.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	d.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *memoryRepo) SaveAttempt(_ context.Context, d *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := *d
+	r.deliveries[d.ID] = &saved
+	return nil
+}
+
+// delivery devuelve una copia de la entrega guardada.
+func (r *memoryRepo) delivery(id uint64) domain.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.deliveries[id]
+}
+
+// makeDue deja la entrega lista para el próximo intento.
+func (r *memoryRepo) makeDue(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	past := time.Now().UTC().Add(-time.Second)
+	r.deliveries[id].NextAttemptAt = &past
+}
+
+// newMemoryRepo crea un repositorio con una suscripción por URL y una entrega pendiente para cada una, con los IDs
+// de las suscripciones y de las entregas empezando en 1.
+func newMemoryRepo(urls ...string) *memoryRepo {
+	r := &memoryRepo{
+		subs:       map[uint64]*domain.WebhookSubscription{},
+		deliveries: map[uint64]*domain.WebhookDelivery{},
+		getErr:     map[uint64]error{},
+		stolen:     map[uint64]bool{},
+	}
+	past := time.Now().UTC().Add(-time.Second)
+	for i, url := range urls {
+		id := uint64(i + 1)
+		r.subs[id] = &domain.WebhookSubscription{ID: id, URL: url, Secret: "secret-" + strconv.Itoa(i+1), Active: true}
+		r.deliveries[id] = &domain.WebhookDelivery{
+			ID: id, SubscriptionID: id, EventID: 100 + id, EventType: domain.EventUserCreated,
+			Payload: []byte(`{"id":` + strconv.Itoa(100+i+1) + `}`), Status: domain.DeliveryStatusPending, NextAttemptAt: &past,
+		}
+	}
+	return r
+}
+
+// receiver es un receptor de webhooks que verifica la firma de cada entrega y responde con los códigos de estado
+// indicados, uno por solicitud.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	requests int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Error(err)
+	}
+
+	// Se calcula la firma como lo haría un receptor, sin usar Sign.
+	mac := hmac.New(sha256.New, []byte(rc.secret))
+	mac.Write([]byte(r.Header.Get(TimestampHeader) + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(SignatureHeader) != want {
+		rc.t.Errorf("%s = %q, want %q", SignatureHeader, r.Header.Get(SignatureHeader), want)
+	}
+	if ts, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		rc.t.Errorf("%s = %q, want the current time", TimestampHeader, r.Header.Get(TimestampHeader))
+	}
+	if r.Header.Get(EventHeader) != domain.EventUserCreated || r.Header.Get(DeliveryHeader) != "1" {
+		rc.t.Errorf("event headers = %q, %q", r.Header.Get(EventHeader), r.Header.Get(DeliveryHeader))
+	}
+
+	rc.mu.Lock()
+	status := rc.statuses[rc.requests]
+	rc.requests++
+	rc.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+// newTestService crea un servicio de webhooks que puede enviar entregas a los servidores de prueba locales.
+func newTestService(repo Repository) Service {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewService(l, repo, NewClient(5*time.Second, true), Config{AllowPrivateNetworks: true})
+}
+
+// TestDeliverDueRetries verifica que las entregas se envíen firmadas, que una respuesta distinta de 2xx deje la
+// entrega pendiente con espera exponencial y que el reintento la marque como entregada.
+func TestDeliverDueRetries(t *testing.T) {
+	rc := &receiver{t: t, secret: "secret-1", statuses: []int{http.StatusInternalServerError, http.StatusOK}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	repo := newMemoryRepo(srv.URL)
+	s := newTestService(repo)
+	ctx := context.Background()
+
+	before := time.Now().UTC()
+	if err := s.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d := repo.delivery(1)
+	if d.Status != domain.DeliveryStatusPending || d.Attempts != 1 || d.LastStatusCode != http.StatusInternalServerError || d.LastError == "" {
+		t.Fatalf("after a failure, delivery = %+v", d)
+	}
+	if d.NextAttemptAt == nil || d.NextAttemptAt.Before(before.Add(minBackoff)) {
+		t.Errorf("after a failure, next attempt = %v, want at least %v later", d.NextAttemptAt, minBackoff)
+	}
+
+	// Hasta que vence la espera no se reintenta.
+	if err := s.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rc.requests != 1 {
+		t.Fatalf("requests before the backoff = %d, want 1", rc.requests)
+	}
+
+	repo.makeDue(1)
+	if err := s.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d = repo.delivery(1)
+	if d.Status != domain.DeliveryStatusDelivered || d.Attempts != 2 || d.LastError != "" || d.NextAttemptAt != nil || d.DeliveredAt == nil {
+		t.Errorf("after a success, delivery = %+v", d)
+	}
+	if rc.requests != 2 {
+		t.Errorf("requests = %d, want 2", rc.requests)
+	}
+}
+
+// TestDeliverDueDeadLetter verifica que la entrega pase a dead letter después de maxAttempts intentos fallidos.
+func TestDeliverDueDeadLetter(t *testing.T) {
+	statuses := make([]int, maxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusBadGateway
+	}
+	rc := &receiver{t: t, secret: "secret-1", statuses: statuses}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	repo := newMemoryRepo(srv.URL)
+	s := newTestService(repo)
+	for i := 0; i < maxAttempts; i++ {
+		repo.makeDue(1)
+		if err := s.DeliverDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := repo.delivery(1)
+	if d.Status != domain.DeliveryStatusDead || d.Attempts != maxAttempts || d.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v, want dead after %d attempts", d, maxAttempts)
+	}
+}
+
+// TestDeliverDueContinues verifica que una falla al obtener una suscripción no interrumpa la pasada y que no se
+// envíen las entregas que reservó otra instancia.
+func TestDeliverDueContinues(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	repo := newMemoryRepo(srv.URL+"/1", srv.URL+"/2", srv.URL+"/3")
+	lookupErr := errors.New("connection reset")
+	repo.getErr[1] = lookupErr
+	repo.stolen[2] = true
+
+	err := newTestService(repo).DeliverDue(context.Background())
+	if !errors.Is(err, lookupErr) {
+		t.Errorf("DeliverDue() = %v, want the lookup error", err)
+	}
+	if want := map[string]int{"/3": 1}; len(received) != 1 || received["/3"] != 1 {
+		t.Errorf("received = %v, want %v", received, want)
+	}
+	if d := repo.delivery(1); d.Status != domain.DeliveryStatusPending || d.Attempts != 0 {
+		t.Errorf("delivery 1 = %+v, want it pending for the next pass", d)
+	}
+	if d := repo.delivery(3); d.Status != domain.DeliveryStatusDelivered {
+		t.Errorf("delivery 3 = %+v, want it delivered", d)
+	}
+}
+
+// TestSign verifica la firma con un valor calculado de forma independiente (HMAC-SHA256 de "1700000000.{"id":1}").
+func TestSign(t *testing.T) {
+	want := "sha256=e79220cb981f992adbc8b93ac6d46028b0217ea19327d27dc9d18bf334403bde"
+	if got := Sign("whsec", "1700000000", []byte(`{"id":1}`)); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+// TestValidateURL verifica que, salvo que se permitan las redes privadas, se rechacen las URLs de la propia máquina
+// y de direcciones internas.
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		err          error
+		allowPrivate error
+	}{
+		{url: "https://hooks.example.com/users"},
+		{url: "http://203.0.113.10:8080/hook"},
+		{url: "", err: ErrURLRequired, allowPrivate: ErrURLRequired},
+		{url: "ftp://example.com", err: ErrInvalidURL, allowPrivate: ErrInvalidURL},
+		{url: "/relative", err: ErrInvalidURL, allowPrivate: ErrInvalidURL},
+		{url: "http://localhost:8080/hook", err: ErrPrivateURL},
+		{url: "http://api.LOCALHOST./hook", err: ErrPrivateURL},
+		{url: "http://127.0.0.1/hook", err: ErrPrivateURL},
+		{url: "http://[::1]:9000/hook", err: ErrPrivateURL},
+		{url: "http://10.1.2.3/hook", err: ErrPrivateURL},
+		{url: "http://192.168.0.10/hook", err: ErrPrivateURL},
+		{url: "http://169.254.169.254/latest/meta-data", err: ErrPrivateURL},
+		{url: "http://0.0.0.0:8080/", err: ErrPrivateURL},
+		{url: "http://[::ffff:127.0.0.1]/", err: ErrPrivateURL},
+		{url: "http://100.64.1.1/", err: ErrPrivateURL},
+	}
+	strict := &service{}
+	lax := &service{cfg: Config{AllowPrivateNetworks: true}}
+	for _, tt := range tests {
+		if err := strict.validateURL(tt.url); !errors.Is(err, tt.err) {
+			t.Errorf("validateURL(%q) = %v, want %v", tt.url, err, tt.err)
+		}
+		if err := lax.validateURL(tt.url); !errors.Is(err, tt.allowPrivate) {
+			t.Errorf("with private networks, validateURL(%q) = %v, want %v", tt.url, err, tt.allowPrivate)
+		}
+	}
+}
+
+// TestNewClientRejectsInternalAddresses verifica que el cliente no se conecte a direcciones internas aunque la URL
+// use un nombre, y que se conecte si se permiten las redes privadas.
+func TestNewClientRejectsInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	addr := netip.MustParseAddrPort(srv.Listener.Addr().String())
+	url := "http://localhost:" + strconv.Itoa(int(addr.Port()))
+
+	if _, err := NewClient(time.Second, false).Get(url); !errors.Is(err, ErrPrivateURL) {
+		t.Errorf("Get(%q) = %v, want ErrPrivateURL", url, err)
+	}
+	resp, err := NewClient(time.Second, true).Get(url)
+	if err != nil {
+		t.Fatalf("with private networks, Get(%q) = %v", url, err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Worker envía en segundo plano las entregas pendientes de webhooks.
+type Worker interface {
+	// Run envía las entregas pendientes cada interval hasta que se cancele el contexto.
+	Run(ctx context.Context)
+}
+
+// worker es una implementación de Worker.
+type worker struct {
+	service  Service
+	log      *slog.Logger
+	interval time.Duration
+}
+
+// NewWorker crea un Worker que utiliza el servicio de webhooks para enviar las entregas pendientes.
+func NewWorker(s Service, l *slog.Logger, interval time.Duration) Worker {
+	return &worker{
+		service:  s,
+		log:      l,
+		interval: interval,
+	}
+}
+
+// Run envía las entregas pendientes cada interval hasta que se cancele el contexto.
+func (w *worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.service.DeliverDue(ctx); err != nil {
+			w.log.ErrorContext(ctx, "webhook delivery pass failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
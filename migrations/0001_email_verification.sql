@@ -0,0 +1,18 @@
+-- Verificación del correo electrónico.
+--
+-- email_verified indica si el usuario confirmó su correo. Los usuarios existentes quedan sin verificar, ya que nunca
+-- confirmaron su correo. email_verifications guarda el hash del token de verificación vigente de cada usuario: a lo
+-- sumo uno por usuario, que se elimina junto con el usuario.
+ALTER TABLE `users`
+    ADD COLUMN `email_verified` TINYINT(1) NOT NULL DEFAULT 0,
+    ADD INDEX `idx_users_email_verified` (`email_verified`);
+
+CREATE TABLE `email_verifications` (
+    `user_id` INT NOT NULL,
+    `email` VARCHAR(45) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    PRIMARY KEY (`user_id`),
+    UNIQUE INDEX `idx_email_verifications_token_hash` (`token_hash`),
+    CONSTRAINT `fk_email_verifications_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Autenticación de dos factores con TOTP.
+--
+-- totp_secret guarda el secreto del usuario y totp_enabled indica si ya confirmó la inscripción con un código válido.
+-- user_recovery_codes guarda solo los hashes de los códigos de recuperación, que se eliminan junto con el usuario.
+ALTER TABLE `users`
+    ADD COLUMN `totp_secret` VARCHAR(64) NULL,
+    ADD COLUMN `totp_enabled` TINYINT(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE `user_recovery_codes` (
+    `user_id` INT NOT NULL,
+    `code_hash` CHAR(64) NOT NULL,
+    PRIMARY KEY (`user_id`, `code_hash`),
+    CONSTRAINT `fk_user_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
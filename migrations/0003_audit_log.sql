@@ -0,0 +1,16 @@
+-- Log de auditoría de los cambios de usuarios.
+--
+-- No tiene clave foránea a users para conservar el historial de los usuarios eliminados.
+CREATE TABLE `audit_log` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `actor` VARCHAR(100) NOT NULL,
+    `action` VARCHAR(20) NOT NULL,
+    `user_id` INT NOT NULL,
+    `changes` JSON NOT NULL,
+    `request_id` VARCHAR(64) NOT NULL,
+    `created_at` DATETIME(6) NOT NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_audit_log_user_id` (`user_id`),
+    INDEX `idx_audit_log_actor` (`actor`),
+    INDEX `idx_audit_log_created_at` (`created_at`)
+);
@@ -0,0 +1,17 @@
+-- Outbox de eventos de dominio.
+--
+-- Los eventos se guardan en la misma transacción que el cambio que los origina y un proceso en segundo plano los
+-- entrega a los sinks configurados.
+CREATE TABLE `outbox_events` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `event_type` VARCHAR(50) NOT NULL,
+    `event_key` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `created_at` DATETIME(6) NOT NULL,
+    `attempts` INT NOT NULL DEFAULT 0,
+    `next_attempt_at` DATETIME(6) NULL,
+    `last_error` TEXT NULL,
+    `dispatched_at` DATETIME(6) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_outbox_events_pending` (`dispatched_at`, `id`)
+);
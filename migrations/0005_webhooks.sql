@@ -0,0 +1,32 @@
+-- Webhooks salientes.
+--
+-- webhook_subscriptions guarda las suscripciones y webhook_deliveries el registro de entregas: cada evento se
+-- entrega a lo sumo una vez por suscripción.
+CREATE TABLE `webhook_subscriptions` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `url` VARCHAR(2048) NOT NULL,
+    `event_types` JSON NOT NULL,
+    `secret` VARCHAR(255) NOT NULL,
+    `active` TINYINT(1) NOT NULL DEFAULT 1,
+    `created_at` DATETIME(6) NOT NULL,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `webhook_deliveries` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `subscription_id` INT NOT NULL,
+    `event_id` BIGINT NOT NULL,
+    `event_type` VARCHAR(50) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` VARCHAR(20) NOT NULL,
+    `attempts` INT NOT NULL DEFAULT 0,
+    `last_status_code` INT NOT NULL DEFAULT 0,
+    `last_error` VARCHAR(1000) NOT NULL DEFAULT '',
+    `next_attempt_at` DATETIME(6) NULL,
+    `delivered_at` DATETIME(6) NULL,
+    `created_at` DATETIME(6) NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_webhook_deliveries_event` (`subscription_id`, `event_id`),
+    INDEX `idx_webhook_deliveries_due` (`status`, `next_attempt_at`),
+    CONSTRAINT `fk_webhook_deliveries_subscription` FOREIGN KEY (`subscription_id`) REFERENCES `webhook_subscriptions` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+-- Búsqueda de usuarios (GET /users/search).
+--
+-- search_text guarda las palabras del nombre, el apellido y el correo del usuario en minúsculas y sin acentos,
+-- separadas por espacios y con un espacio inicial (" jose nunez jose nunez example com"). Lo calcula el repositorio
+-- de usuarios al crear o modificar un usuario, y al iniciarse completa el de los usuarios existentes. Tanto el índice
+-- FULLTEXT como la búsqueda con LIKE usan esta columna, de modo que encuentran los mismos usuarios sin depender de la
+-- intercalación de la base de datos.
+--
+-- La tabla se convierte a utf8mb4 para poder guardar nombres en cualquier alfabeto.
+ALTER TABLE `users` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+ALTER TABLE `users` ADD COLUMN `search_text` VARCHAR(255) NULL;
+
+-- Sin stopwords, el índice incluye palabras como "com", "de" o "la", que la lista predeterminada de InnoDB omite y
+-- que LIKE sí encontraría. La configuración vigente al crear el índice es la que se usa; si se reconstruye (por
+-- ejemplo, con OPTIMIZE TABLE), debe hacerse con innodb_ft_enable_stopword en OFF. Al terminar, la variable vuelve
+-- al valor global, para no afectar a las migraciones siguientes ni a las consultas que reutilicen la conexión.
+SET SESSION innodb_ft_enable_stopword = OFF;
+
+ALTER TABLE `users` ADD FULLTEXT INDEX `idx_users_search` (`search_text`);
+
+SET SESSION innodb_ft_enable_stopword = DEFAULT;
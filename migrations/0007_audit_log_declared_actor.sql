@@ -0,0 +1,6 @@
+-- Actor declarado del log de auditoría.
+--
+-- La columna actor registra solo el actor autenticado: la identidad del certificado de cliente (mTLS) o "token". El
+-- encabezado X-Actor, que el cliente puede completar con cualquier valor, se guarda en declared_actor, recortado a
+-- 100 caracteres.
+ALTER TABLE `audit_log` ADD COLUMN `declared_actor` VARCHAR(100) NULL AFTER `actor`;
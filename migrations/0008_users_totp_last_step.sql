@@ -0,0 +1,6 @@
+-- Último período TOTP utilizado.
+--
+-- totp_last_step guarda el período (contador de la RFC 6238) del último código TOTP aceptado para el usuario. Los
+-- códigos de ese período o de uno anterior se rechazan, de modo que cada código solo se puede utilizar una vez
+-- aunque siga vigente. Se vacía al inscribirse de nuevo o al desactivar la autenticación de dos factores.
+ALTER TABLE `users` ADD COLUMN `totp_last_step` BIGINT UNSIGNED NULL AFTER `totp_enabled`;
@@ -0,0 +1,10 @@
+-- Dead letter del outbox.
+--
+-- dead_at registra el momento en el que un evento pasó a dead letter después de agotar sus intentos de entrega. Los
+-- eventos en dead letter no se vuelven a entregar ni demoran a los eventos posteriores con la misma clave; para
+-- reintentar uno, se vacían dead_at, attempts y next_attempt_at.
+ALTER TABLE `outbox_events` ADD COLUMN `dead_at` DATETIME(6) NULL AFTER `dispatched_at`;
+
+-- Índice con el que la consulta de eventos pendientes busca los eventos anteriores de la misma clave que esperan
+-- su próximo reintento.
+ALTER TABLE `outbox_events` ADD INDEX `idx_outbox_events_key` (`event_key`, `id`);
@@ -0,0 +1,14 @@
+package migrations
+
+/*
+Package migrations contiene las migraciones versionadas del esquema de la base de datos, que la aplicación aplica con
+el paquete migrate al iniciarse. .dockers/mysql/init.sql crea el esquema inicial y cada cambio posterior se agrega
+como un nuevo archivo NNNN_nombre.sql, con la versión siguiente; las migraciones ya publicadas no se modifican.
+*/
+
+import "embed"
+
+// FS contiene los archivos de migración, embebidos en el binario.
+//
+//go:embed *.sql
+var FS embed.FS
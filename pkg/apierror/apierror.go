@@ -0,0 +1,151 @@
+package apierror
+
+/*
+Package apierror centraliza la traducción de los errores de dominio a respuestas HTTP. Cada paquete de dominio
+registra sus errores con el código de estado HTTP y un código de error estable, que los clientes pueden utilizar en
+lugar del mensaje para identificar el error. Los errores que no están registrados se responden como 500.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+)
+
+// Códigos de error de los errores que no pertenecen a ningún dominio.
+const (
+	CodeInternal        = "internal_error"   // Error no registrado
+	CodeRequestCanceled = "request_canceled" // El cliente canceló la solicitud
+	CodeTimeout         = "timeout"          // La operación superó el tiempo máximo
+)
+
+// StatusClientClosedRequest es el código de estado no estándar (popularizado por nginx) que indica que el cliente
+// cerró la conexión antes de recibir la respuesta.
+const StatusClientClosedRequest = 499
+
+// Mapping es la respuesta HTTP que corresponde a un error.
+type Mapping struct {
+	Status  int    // Código de estado HTTP
+	Code    string // Código de error estable
+	Message string // Mensaje fijo de la respuesta; si está vacío se utiliza el mensaje del error
+}
+
+// entry es un error registrado: la función que lo reconoce y su respuesta.
+type entry struct {
+	match   func(err error) bool
+	mapping Mapping
+}
+
+// registry guarda los errores registrados, en el orden en el que se registraron.
+var registry struct {
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// Si el cliente canceló la solicitud se responde 499, si venció el plazo de la operación, 504, y si la solicitud
+// tiene errores en sus campos, 400.
+func init() {
+	add(func(err error) bool { return errors.Is(err, context.Canceled) },
+		Mapping{Status: StatusClientClosedRequest, Code: CodeRequestCanceled, Message: "request canceled by the client"})
+	add(func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		Mapping{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Message: "operation timed out"})
+	RegisterType[ValidationError](http.StatusBadRequest, CodeValidation)
+}
+
+// Register registra un error centinela (por ejemplo, uno creado con errors.New), que se reconoce con errors.Is
+// aunque esté envuelto en otro error.
+func Register(target error, status int, code string) {
+	add(func(err error) bool { return errors.Is(err, target) }, Mapping{Status: status, Code: code})
+}
+
+// RegisterType registra un tipo de error, que se reconoce con errors.As aunque esté envuelto en otro error.
+func RegisterType[T error](status int, code string) {
+	add(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, Mapping{Status: status, Code: code})
+}
+
+// add agrega un error al registro.
+func add(match func(err error) bool, mapping Mapping) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.entries = append(registry.entries, entry{match: match, mapping: mapping})
+}
+
+// Lookup devuelve la respuesta registrada para el error. Si el error coincide con varios registros, se utiliza el
+// primero que se registró.
+func Lookup(err error) (Mapping, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	for _, e := range registry.entries {
+		if e.match(err) {
+			return e.mapping, true
+		}
+	}
+	return Mapping{}, false
+}
+
+// Mappings devuelve todas las respuestas registradas, por ejemplo para documentar los errores de la API.
+func Mappings() []Mapping {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	mappings := make([]Mapping, 0, len(registry.entries))
+	for _, e := range registry.entries {
+		mappings = append(mappings, e.mapping)
+	}
+	return mappings
+}
+
+// Error es una respuesta de error con el formato de go-fundamentals-response al que se agrega el código de error.
+type Error struct {
+	Status  int          `json:"status"`           // Código de estado HTTP
+	Code    string       `json:"code"`             // Código de error estable
+	Message string       `json:"message"`          // Mensaje del error
+	Fields  []FieldError `json:"errors,omitempty"` // Errores de los campos de la solicitud
+}
+
+// New convierte un error en la respuesta HTTP que le corresponde según el registro. Los errores que ya son una
+// response.Response se devuelven sin cambios y los que no están registrados se responden como 500.
+func New(err error) response.Response {
+	var resp response.Response
+	if errors.As(err, &resp) {
+		return resp
+	}
+
+	mapping, ok := Lookup(err)
+	if !ok {
+		mapping = Mapping{Status: http.StatusInternalServerError, Code: CodeInternal}
+	}
+	msg := mapping.Message
+	if msg == "" {
+		msg = err.Error()
+	}
+	return &Error{Status: mapping.Status, Code: mapping.Code, Message: msg, Fields: fieldErrors(err)}
+}
+
+// Error devuelve el mensaje del error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode devuelve el código de estado HTTP del error.
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// GetBody serializa el error a JSON.
+func (e *Error) GetBody() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// GetData devuelve nil ya que las respuestas de error no contienen datos.
+func (e *Error) GetData() interface{} {
+	return nil
+}
@@ -0,0 +1,87 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+)
+
+// ProblemContentType es el tipo de contenido de los documentos RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// CodeValidation es el código de error de las solicitudes con errores en sus campos.
+const CodeValidation = "validation_failed"
+
+// FieldError describe el error de un campo del cuerpo de la solicitud.
+type FieldError struct {
+	Pointer string `json:"pointer"`        // JSON Pointer (RFC 6901) del campo, por ejemplo "/first_name"
+	Code    string `json:"code,omitempty"` // Código de error estable del campo
+	Detail  string `json:"detail"`         // Descripción del error
+}
+
+// ValidationError agrupa los errores de los campos de una solicitud. Se responde con 400 y la lista de campos.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error devuelve los errores de los campos separados por punto y coma.
+func (e ValidationError) Error() string {
+	details := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		details = append(details, f.Detail)
+	}
+	return strings.Join(details, "; ")
+}
+
+// Problem es un documento de error RFC 7807 (application/problem+json), con el código de error y los errores de los
+// campos como miembros de extensión.
+type Problem struct {
+	Type     string       `json:"type"`               // URI que identifica el tipo de error
+	Title    string       `json:"title"`              // Resumen del tipo de error
+	Status   int          `json:"status"`             // Código de estado HTTP
+	Detail   string       `json:"detail,omitempty"`   // Descripción de esta ocurrencia del error
+	Instance string       `json:"instance,omitempty"` // Identificador de esta ocurrencia: el ID de la solicitud
+	Code     string       `json:"code,omitempty"`     // Código de error estable
+	Errors   []FieldError `json:"errors,omitempty"`   // Errores de los campos de la solicitud
+}
+
+// NewProblem convierte un error en un documento RFC 7807 según el registro. instance es el ID de la solicitud y
+// typeBaseURL, la URL base de la documentación de los errores: si se indica, el tipo es typeBaseURL/código; si no,
+// el tipo es "about:blank" y el código se informa solo en el miembro code.
+func NewProblem(err error, instance, typeBaseURL string) *Problem {
+	resp := New(err)
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(resp.StatusCode()),
+		Status:   resp.StatusCode(),
+		Detail:   resp.Error(),
+		Instance: instance,
+	}
+	if p.Title == "" {
+		p.Title = "Error"
+	}
+
+	switch e := resp.(type) {
+	case *Error:
+		p.Code = e.Code
+		p.Errors = e.Fields
+	case *response.ErrorResponse:
+		// ErrorResponse.Error devuelve una cadena vacía; el mensaje está en Message.
+		p.Detail = e.Message
+	}
+	if p.Code != "" && typeBaseURL != "" {
+		p.Type = strings.TrimSuffix(typeBaseURL, "/") + "/" + p.Code
+	}
+	return p
+}
+
+// fieldErrors devuelve los errores de los campos del error, si es un ValidationError.
+func fieldErrors(err error) []FieldError {
+	var v ValidationError
+	if errors.As(err, &v) {
+		return v.Fields
+	}
+	return nil
+}
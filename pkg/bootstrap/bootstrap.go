@@ -5,22 +5,46 @@ Package bootstrap proporciona funciones para inicializar componentes clave antes
 */
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // _ lo importo pero no lo uso
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/logging"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/mail"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/outbox"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"github.com/go-sql-driver/mysql"
 )
 
-// NewLogger crea y devuelve un objeto *log.Logger que se utiliza para registrar mensajes en la consola.
-func NewLogger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+// NewLogger crea el logger estructurado que registra los mensajes en la consola en formato JSON,
+// a partir del nivel mínimo configurado.
+func NewLogger(cfg config.Log) (*slog.Logger, error) {
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stdout, level), nil
 }
 
 // NewBD inicializa y devuelve una conexión a la base de datos MySQL.
-func NewBD() (*sql.DB, error) {
-	// Construye la cadena de conexión utilizando las variables de entorno para las credenciales y la configuración de la base de datos.
-	dbURL := os.ExpandEnv("$DATABASE_USER:$DATABASE_PASSWORD@tcp($DATABASE_HOST:$DATABASE_PORT)/$DATABASE_NAME")
+func NewBD(cfg config.Database) (*sql.DB, error) {
+	// Construye la cadena de conexión utilizando las credenciales y la configuración de la base de datos.
+	dsn := mysql.NewConfig()
+	dsn.User = cfg.User
+	dsn.Passwd = cfg.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dsn.DBName = cfg.Name
+	dsn.ParseTime = true
+	// Las filas afectadas de un UPDATE cuentan las filas encontradas aunque sus valores no cambien, para que una
+	// actualización con los mismos datos no se confunda con un usuario inexistente.
+	dsn.ClientFoundRows = true
+	dbURL := dsn.FormatDSN()
 
 	// Abre una conexión a la base de datos MySQL utilizando las credenciales y la cadena de conexión proporcionadas.
 	db, err := sql.Open("mysql", dbURL)
@@ -31,6 +55,46 @@ func NewBD() (*sql.DB, error) {
 	return db, nil // Devuelve la conexión a la base de datos y ningún error si es exitosa
 }
 
+// NewMailer devuelve el Mailer a utilizar para enviar correos.
+// Si el servidor SMTP está configurado se envían por SMTP, en caso contrario solo se registran en el logger.
+func NewMailer(cfg config.Mail, l *slog.Logger) mail.Mailer {
+	if cfg.SMTPHost == "" {
+		return mail.NewLogMailer(l)
+	}
+
+	return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPTimeout.Std())
+}
+
+// NewOutboxSinks devuelve los sinks a los que se entregan los eventos del outbox según la configuración.
+// File agrega un sink que escribe los eventos en un archivo y WebhookURL uno que los envía por HTTP.
+func NewOutboxSinks(cfg config.Outbox) ([]outbox.Sink, error) {
+	var sinks []outbox.Sink
+
+	if path := cfg.File; path != "" {
+		sink, err := outbox.NewFileSink(path)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if url := cfg.WebhookURL; url != "" {
+		sinks = append(sinks, outbox.NewWebhookSink(url, 10*time.Second))
+	}
+
+	return sinks, nil
+}
+
+// NewTracing configura las trazas de OpenTelemetry según la configuración y devuelve la función que las detiene.
+// El exportador otlp se configura con las variables estándar OTEL_EXPORTER_OTLP_*.
+func NewTracing(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, tracing.Config{
+		ServiceName: "go-fundamentals-web-users",
+		Exporter:    cfg.Exporter,
+		File:        cfg.File,
+	})
+}
+
 /*
 return user.DB{
 		Users: []domain.User{
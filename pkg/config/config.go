@@ -0,0 +1,508 @@
+package config
+
+/*
+Package config carga la configuración de la aplicación en una estructura tipada. Los valores se toman, de menor a mayor
+prioridad, de los valores predeterminados, de un archivo YAML o TOML opcional (CONFIG_FILE) y de las variables de entorno,
+incluidas las definidas en el archivo .env. La configuración se valida antes de devolverla.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/logging"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config agrupa toda la configuración de la aplicación.
+type Config struct {
+	Server      Server      `yaml:"server" toml:"server"`
+	GRPC        GRPC        `yaml:"grpc" toml:"grpc"`
+	Database    Database    `yaml:"database" toml:"database"`
+	Auth        Auth        `yaml:"auth" toml:"auth"`
+	Log         Log         `yaml:"log" toml:"log"`
+	Mail        Mail        `yaml:"mail" toml:"mail"`
+	Outbox      Outbox      `yaml:"outbox" toml:"outbox"`
+	Webhooks    Webhooks    `yaml:"webhooks" toml:"webhooks"`
+	Tracing     Tracing     `yaml:"tracing" toml:"tracing"`
+	CORS        CORS        `yaml:"cors" toml:"cors"`
+	RateLimit   RateLimit   `yaml:"rate_limit" toml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency" toml:"idempotency"`
+	Users       Users       `yaml:"users" toml:"users"`
+	Errors      Errors      `yaml:"errors" toml:"errors"`
+}
+
+// Server agrupa la configuración del servidor HTTP.
+type Server struct {
+	Host            string   `yaml:"host" toml:"host" env:"SERVER_HOST"`                                                      // Dirección en la que escucha el servidor; 0.0.0.0 escucha en todas las interfaces
+	Port            int      `yaml:"port" toml:"port" env:"PORT"`                                                             // Puerto en el que escucha el servidor
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`                         // Tiempo máximo para drenar las solicitudes al detenerse
+	ReadinessDelay  Duration `yaml:"shutdown_readiness_delay" toml:"shutdown_readiness_delay" env:"SHUTDOWN_READINESS_DELAY"` // Espera entre dejar de estar listo y dejar de aceptar conexiones
+	TrustedProxies  []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`                            // IPs o rangos CIDR de los proxies cuyo X-Forwarded-For se acepta
+	TLS             TLS      `yaml:"tls" toml:"tls"`
+}
+
+// TLS agrupa la configuración de TLS y de la autenticación mutua (mTLS) del servidor.
+// TLS se activa al configurar el certificado y la clave; mTLS, al configurar además la CA de clientes.
+type TLS struct {
+	CertFile       string   `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE"`                   // Certificado del servidor en PEM
+	KeyFile        string   `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE"`                      // Clave privada del servidor en PEM
+	ClientCAFile   string   `yaml:"client_ca_file" toml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`    // CA con la que se verifican los certificados de los clientes
+	ClientAuth     string   `yaml:"client_auth" toml:"client_auth" env:"TLS_CLIENT_AUTH"`             // require u optional: si el certificado de cliente es obligatorio
+	ReloadInterval Duration `yaml:"reload_interval" toml:"reload_interval" env:"TLS_RELOAD_INTERVAL"` // Frecuencia con la que se verifican los cambios de los archivos
+}
+
+// Enabled indica si el servidor debe atender con TLS.
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Valores posibles de TLS.ClientAuth.
+const (
+	ClientAuthRequire  = "require"  // Los clientes deben presentar un certificado válido
+	ClientAuthOptional = "optional" // Los clientes pueden presentar un certificado, que se verifica si lo envían
+)
+
+// GRPC agrupa la configuración del servidor gRPC, que escucha en la misma interfaz que el servidor HTTP y con su
+// misma configuración de TLS.
+type GRPC struct {
+	Port       int  `yaml:"port" toml:"port" env:"GRPC_PORT"`                   // Puerto en el que escucha el servidor gRPC; cero lo deshabilita
+	Reflection bool `yaml:"reflection" toml:"reflection" env:"GRPC_REFLECTION"` // Si se publica el servicio de reflexión, que usan grpcurl y otras herramientas
+}
+
+// Database agrupa la configuración de la conexión a MySQL.
+type Database struct {
+	Host         string   `yaml:"host" toml:"host" env:"DATABASE_HOST"`
+	Port         int      `yaml:"port" toml:"port" env:"DATABASE_PORT"`
+	Name         string   `yaml:"name" toml:"name" env:"DATABASE_NAME"`
+	User         string   `yaml:"user" toml:"user" env:"DATABASE_USER"`
+	Password     string   `yaml:"password" toml:"password" env:"DATABASE_PASSWORD"`
+	QueryTimeout Duration `yaml:"query_timeout" toml:"query_timeout" env:"DB_QUERY_TIMEOUT"` // Tiempo máximo de cada operación; cero indica sin límite
+	Migrate      bool     `yaml:"migrate" toml:"migrate" env:"DB_MIGRATE"`                   // Si se aplican las migraciones pendientes al iniciar
+}
+
+// Auth agrupa la configuración de la autenticación de la API. Los clientes se autentican con el token compartido o
+// con su propia clave de API, que además los identifica en los límites de solicitudes y en el log de auditoría.
+type Auth struct {
+	Token   string   `yaml:"token" toml:"token" env:"TOKEN"`          // Token que deben enviar los clientes en el encabezado Authorization
+	APIKeys []string `yaml:"api_keys" toml:"api_keys" env:"API_KEYS"` // Claves de API de los clientes, como nombre:clave
+}
+
+// Clients devuelve el nombre del cliente de cada clave de API. Las entradas sin el formato nombre:clave se omiten;
+// Validate las rechaza.
+func (a Auth) Clients() map[string]string {
+	clients := make(map[string]string, len(a.APIKeys))
+	for _, entry := range a.APIKeys {
+		if name, key, ok := parseAPIKey(entry); ok {
+			clients[key] = name
+		}
+	}
+	return clients
+}
+
+// maxClientNameLength es la longitud máxima del nombre del cliente de una clave de API, que se registra como actor en
+// el log de auditoría (columna audit_log.actor).
+const maxClientNameLength = 100
+
+// parseAPIKey separa una entrada nombre:clave de Auth.APIKeys.
+func parseAPIKey(entry string) (name, key string, ok bool) {
+	name, key, ok = strings.Cut(entry, ":")
+	name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+	return name, key, ok && name != "" && key != ""
+}
+
+// Log agrupa la configuración de los logs.
+type Log struct {
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"` // Nivel mínimo: debug, info, warn o error
+}
+
+// Mail agrupa la configuración del envío de correos.
+type Mail struct {
+	VerifyURL    string   `yaml:"verify_url" toml:"verify_url" env:"VERIFY_URL"` // URL pública del endpoint de verificación de correo
+	SMTPHost     string   `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST"`    // Si está vacío, los correos solo se registran en el log
+	SMTPPort     string   `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUser     string   `yaml:"smtp_user" toml:"smtp_user" env:"SMTP_USER"`
+	SMTPPassword string   `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD"`
+	SMTPFrom     string   `yaml:"smtp_from" toml:"smtp_from" env:"SMTP_FROM"`
+	SMTPTimeout  Duration `yaml:"smtp_timeout" toml:"smtp_timeout" env:"SMTP_TIMEOUT"` // Tiempo máximo para entregar cada correo
+}
+
+// Outbox agrupa la configuración de los destinos adicionales de los eventos de dominio.
+type Outbox struct {
+	File       string `yaml:"file" toml:"file" env:"OUTBOX_FILE"`                      // Archivo en el que se escriben los eventos
+	WebhookURL string `yaml:"webhook_url" toml:"webhook_url" env:"OUTBOX_WEBHOOK_URL"` // URL a la que se envían los eventos
+}
+
+// Webhooks agrupa la configuración del envío de las entregas de webhooks.
+type Webhooks struct {
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" toml:"allow_private_networks" env:"WEBHOOKS_ALLOW_PRIVATE_NETWORKS"` // Si las suscripciones pueden apuntar a direcciones internas o de loopback
+}
+
+// Tracing agrupa la configuración de las trazas de OpenTelemetry.
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"` // none, stdout, file u otlp
+	File     string `yaml:"file" toml:"file" env:"TRACING_FILE"`             // Archivo del exportador file
+}
+
+// CORS agrupa la política CORS que se aplica a las solicitudes de los navegadores desde otros orígenes.
+// Las listas se indican en las variables de entorno separadas por comas.
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`       // Orígenes permitidos: exactos, "*" o con comodín ("https://*.example.com")
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`       // Encabezados que el navegador puede enviar
+	ExposedHeaders   []string `yaml:"exposed_headers" toml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`       // Encabezados de la respuesta que el navegador expone
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"` // Si el navegador puede enviar cookies y credenciales
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE"`                               // Tiempo de caché de las respuestas preflight
+}
+
+// RateLimit agrupa los límites de solicitudes por cliente. Los límites de cada ruta se definen en el servidor HTTP
+// a partir de estos valores: las consultas utilizan Requests y las escrituras WriteRequests. Los códigos de dos
+// factores se limitan por usuario, con TwoFactorRequests por TwoFactorPeriod, para que cambiar de IP o de clave de
+// API no permita probar más códigos del mismo usuario.
+type RateLimit struct {
+	Enabled           bool     `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED"`                                     // Si se limitan las solicitudes
+	Requests          int      `yaml:"requests" toml:"requests" env:"RATE_LIMIT_REQUESTS"`                                  // Solicitudes de consulta por período y cliente
+	WriteRequests     int      `yaml:"write_requests" toml:"write_requests" env:"RATE_LIMIT_WRITE_REQUESTS"`                // Solicitudes de escritura por período y cliente
+	Period            Duration `yaml:"period" toml:"period" env:"RATE_LIMIT_PERIOD"`                                        // Período en el que se recargan las solicitudes
+	TwoFactorRequests int      `yaml:"two_factor_requests" toml:"two_factor_requests" env:"RATE_LIMIT_TWO_FACTOR_REQUESTS"` // Códigos de dos factores por período y usuario
+	TwoFactorPeriod   Duration `yaml:"two_factor_period" toml:"two_factor_period" env:"RATE_LIMIT_TWO_FACTOR_PERIOD"`       // Período en el que se recargan los códigos de dos factores
+}
+
+// Idempotency agrupa la configuración de las claves de idempotencia (encabezado Idempotency-Key).
+type Idempotency struct {
+	TTL Duration `yaml:"ttl" toml:"ttl" env:"IDEMPOTENCY_TTL"` // Tiempo durante el cual se guarda la respuesta de cada clave
+}
+
+// Users agrupa la configuración de los endpoints de usuarios.
+type Users struct {
+	BatchMaxIDs int `yaml:"batch_max_ids" toml:"batch_max_ids" env:"USERS_BATCH_MAX_IDS"` // Cantidad máxima de IDs por solicitud de POST /users/batch-get
+}
+
+// Errors agrupa la configuración del formato de las respuestas de error.
+type Errors struct {
+	Format      string `yaml:"format" toml:"format" env:"ERROR_FORMAT"`                      // envelope o problem
+	TypeBaseURL string `yaml:"type_base_url" toml:"type_base_url" env:"ERROR_TYPE_BASE_URL"` // URL base del miembro type de los documentos RFC 7807
+}
+
+// Valores posibles de Errors.Format.
+const (
+	ErrorFormatEnvelope = "envelope" // Formato de go-fundamentals-response; los clientes pueden pedir RFC 7807 con el encabezado Accept
+	ErrorFormatProblem  = "problem"  // Documentos RFC 7807 (application/problem+json) en todas las respuestas de error
+)
+
+// Duration es un time.Duration que se lee en formato de texto ("15s", "2m") tanto del archivo como de las variables de entorno.
+type Duration time.Duration
+
+// UnmarshalText convierte un texto como "15s" en una Duration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Std devuelve la duración como time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Default devuelve la configuración con los valores predeterminados.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Host:            "127.0.0.1",
+			Port:            8080,
+			ShutdownTimeout: Duration(15 * time.Second),
+			ReadinessDelay:  Duration(5 * time.Second),
+			TLS: TLS{
+				ClientAuth:     ClientAuthRequire,
+				ReloadInterval: Duration(10 * time.Second),
+			},
+		},
+		GRPC: GRPC{
+			Port:       9090,
+			Reflection: false,
+		},
+		Database: Database{
+			Host:         "127.0.0.1",
+			Port:         3336,
+			QueryTimeout: Duration(5 * time.Second),
+			Migrate:      true,
+		},
+		Mail:    Mail{SMTPTimeout: Duration(10 * time.Second)},
+		Log:     Log{Level: "info"},
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+		CORS: CORS{
+			AllowedHeaders: []string{"Accept", "Authorization", "Cache-Control", "Content-Type", "Idempotency-Key", "If-Modified-Since", "X-Actor", "X-Request-ID", "X-Two-Factor-Code", "X-Requested-With", "Traceparent", "Tracestate"},
+			ExposedHeaders: []string{"X-Request-ID"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		RateLimit: RateLimit{
+			Enabled:           true,
+			Requests:          300,
+			WriteRequests:     60,
+			Period:            Duration(time.Minute),
+			TwoFactorRequests: 10,
+			TwoFactorPeriod:   Duration(time.Minute),
+		},
+		Idempotency: Idempotency{TTL: Duration(24 * time.Hour)},
+		Users:       Users{BatchMaxIDs: 100},
+		Errors:      Errors{Format: ErrorFormatEnvelope},
+	}
+}
+
+// Load carga la configuración desde los valores predeterminados, el archivo indicado en CONFIG_FILE (si existe)
+// y las variables de entorno, incluidas las del archivo .env, y la valida.
+func Load() (*Config, error) {
+	// Las variables del archivo .env no reemplazan a las que ya están definidas en el entorno.
+	_ = godotenv.Load()
+
+	cfg := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile carga la configuración de un archivo YAML o TOML, según su extensión.
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, c)
+	case ".toml":
+		err = toml.Unmarshal(b, c)
+	default:
+		return fmt.Errorf("config file: unsupported format '%s'", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv reemplaza los valores de los campos con etiqueta env por los de las variables de entorno no vacías.
+func (c *Config) loadEnv() error {
+	return loadEnv(reflect.ValueOf(c).Elem())
+}
+
+func loadEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, typ := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := loadEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := typ.Tag.Get("env")
+		value := os.Getenv(name)
+		if name == "" || value == "" {
+			continue
+		}
+
+		switch ptr := field.Addr().Interface().(type) {
+		case *string:
+			*ptr = value
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*ptr = n
+		case *bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*ptr = b
+		case *[]string:
+			*ptr = splitList(value)
+		case *Duration:
+			if err := ptr.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("%s: unsupported type %s", name, field.Type())
+		}
+	}
+	return nil
+}
+
+// Validate verifica que la configuración sea válida y devuelve todos los errores encontrados.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server shutdown timeout must be positive")
+	check(c.Server.ReadinessDelay >= 0, "server shutdown readiness delay can't be negative")
+	check(c.Server.Host != "", "server host is required")
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "tls cert file and key file must be set together")
+	check(c.Server.TLS.ClientCAFile == "" || c.Server.TLS.Enabled(), "tls client ca file requires tls cert and key files")
+	check(c.Server.TLS.ClientAuth == ClientAuthRequire || c.Server.TLS.ClientAuth == ClientAuthOptional,
+		"tls client auth must be '%s' or '%s'", ClientAuthRequire, ClientAuthOptional)
+	check(c.Server.TLS.ReloadInterval > 0, "tls reload interval must be positive")
+	for _, p := range c.Server.TrustedProxies {
+		check(validProxy(p), "trusted proxy '%s' must be an IP address or a CIDR range", p)
+	}
+
+	check(c.GRPC.Port == 0 || validPort(c.GRPC.Port), "grpc port must be between 1 and 65535, or 0 to disable it, got %d", c.GRPC.Port)
+	check(c.GRPC.Port == 0 || c.GRPC.Port != c.Server.Port, "grpc port must be different from the server port")
+
+	check(c.Database.Host != "", "database host is required")
+	check(validPort(c.Database.Port), "database port must be between 1 and 65535, got %d", c.Database.Port)
+	check(c.Database.Name != "", "database name is required")
+	check(c.Database.User != "", "database user is required")
+	check(c.Database.QueryTimeout >= 0, "database query timeout can't be negative")
+
+	check(c.Auth.Token != "", "auth token is required")
+	names, keys := map[string]bool{}, map[string]bool{}
+	for i, entry := range c.Auth.APIKeys {
+		name, key, ok := parseAPIKey(entry)
+		if !ok {
+			check(false, "auth api key %d must have the format name:key", i+1)
+			continue
+		}
+		check(len([]rune(name)) <= maxClientNameLength, "auth api key name '%s' can't be longer than %d characters", name, maxClientNameLength)
+		check(!names[name], "auth api key name '%s' is repeated", name)
+		check(!keys[key] && key != c.Auth.Token, "auth api key of '%s' is repeated or equal to the token", name)
+		names[name], keys[key] = true, true
+	}
+
+	_, err := logging.ParseLevel(c.Log.Level)
+	check(err == nil, "log level: %v", err)
+
+	if c.Mail.VerifyURL != "" {
+		u, err := url.Parse(c.Mail.VerifyURL)
+		check(err == nil && u.IsAbs(), "mail verify url must be an absolute URL")
+	}
+	if c.Mail.SMTPHost != "" {
+		// Sin la URL de verificación, los correos enviados tendrían un enlace relativo que no se puede abrir.
+		check(c.Mail.VerifyURL != "", "mail verify url is required when smtp host is set")
+		check(c.Mail.SMTPPort != "", "mail smtp port is required when smtp host is set")
+		check(c.Mail.SMTPFrom != "", "mail smtp from is required when smtp host is set")
+		check(c.Mail.SMTPTimeout > 0, "mail smtp timeout must be greater than zero")
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	case tracing.ExporterFile:
+		check(c.Tracing.File != "", "tracing file is required with the file exporter")
+	default:
+		check(false, "unknown tracing exporter '%s'", c.Tracing.Exporter)
+	}
+
+	for _, o := range c.CORS.AllowedOrigins {
+		check(validOrigin(o), "cors allowed origin '%s' must be '*' or an origin like 'https://app.example.com' or 'https://*.example.com'", o)
+		check(!strings.Contains(o, "*") || !c.CORS.AllowCredentials, "cors allowed origin '%s' has a wildcard and can't be used with allow credentials", o)
+	}
+	check(c.CORS.MaxAge >= 0, "cors max age can't be negative")
+
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.Requests > 0, "rate limit requests must be positive")
+		check(c.RateLimit.WriteRequests > 0, "rate limit write requests must be positive")
+		check(c.RateLimit.Period >= Duration(time.Second), "rate limit period must be at least 1s")
+		check(c.RateLimit.TwoFactorRequests > 0, "rate limit two-factor requests must be positive")
+		check(c.RateLimit.TwoFactorPeriod >= Duration(time.Second), "rate limit two-factor period must be at least 1s")
+	}
+
+	check(c.Idempotency.TTL > 0, "idempotency ttl must be positive")
+
+	check(c.Users.BatchMaxIDs > 0, "users batch max ids must be positive")
+
+	check(c.Errors.Format == ErrorFormatEnvelope || c.Errors.Format == ErrorFormatProblem,
+		"error format must be '%s' or '%s'", ErrorFormatEnvelope, ErrorFormatProblem)
+	if c.Errors.TypeBaseURL != "" {
+		u, err := url.Parse(c.Errors.TypeBaseURL)
+		check(err == nil && u.IsAbs(), "error type base url must be an absolute URL")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validPort indica si port es un número de puerto TCP válido.
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// validProxy indica si p es una dirección IP o un rango CIDR.
+func validProxy(p string) bool {
+	if net.ParseIP(p) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(p)
+	return err == nil
+}
+
+// validOrigin indica si o es "*" o un origen (esquema y host, sin ruta) en el que el host puede tener un comodín. El
+// comodín solo puede ocupar la primera etiqueta del host y debe estar seguido de al menos una etiqueta fija, para que
+// orígenes como "https://*" o "https://*example.com" no acepten cualquier dominio.
+func validOrigin(o string) bool {
+	if o == "*" {
+		return true
+	}
+	wildcard := strings.Contains(o, "*")
+	if wildcard {
+		scheme, rest, _ := strings.Cut(o, "://")
+		if strings.Count(o, "*") != 1 || !strings.HasPrefix(rest, "*.") {
+			return false
+		}
+		o = scheme + "://x" + rest[1:]
+	}
+	u, err := url.Parse(o)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return false
+	}
+	if wildcard {
+		for _, label := range strings.Split(strings.TrimPrefix(u.Hostname(), "x."), ".") {
+			if label == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitList separa una lista de valores separados por comas, descartando los vacíos.
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAuthAndProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(c *Config) {
+			c.Auth.APIKeys = []string{"billing:k1", " crm : k2 "}
+			c.Server.TrustedProxies = []string{"10.0.0.1", "192.168.0.0/16", "::1"}
+		}},
+		{name: "api key without name", modify: func(c *Config) { c.Auth.APIKeys = []string{":k1"} }, wantErr: "format name:key"},
+		{name: "api key without key", modify: func(c *Config) { c.Auth.APIKeys = []string{"billing"} }, wantErr: "format name:key"},
+		{name: "repeated name", modify: func(c *Config) { c.Auth.APIKeys = []string{"billing:k1", "billing:k2"} }, wantErr: "is repeated"},
+		{name: "repeated key", modify: func(c *Config) { c.Auth.APIKeys = []string{"billing:k1", "crm:k1"} }, wantErr: "repeated or equal to the token"},
+		{name: "key equal to the token", modify: func(c *Config) { c.Auth.APIKeys = []string{"billing:secret"} }, wantErr: "repeated or equal to the token"},
+		{name: "long name", modify: func(c *Config) { c.Auth.APIKeys = []string{strings.Repeat("a", 101) + ":k1"} }, wantErr: "longer than 100"},
+		{name: "invalid proxy", modify: func(c *Config) { c.Server.TrustedProxies = []string{"proxy.local"} }, wantErr: "trusted proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Database.Name, c.Database.User, c.Auth.Token = "users", "root", "secret"
+			tt.modify(c)
+
+			err := c.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMail(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "log mailer without verify url"},
+		{name: "smtp", modify: func(c *Config) {
+			c.Mail.VerifyURL = "https://users.example.com/users/verify"
+			c.Mail.SMTPHost, c.Mail.SMTPPort, c.Mail.SMTPFrom = "smtp.example.com", "587", "users@example.com"
+		}},
+		{name: "smtp without verify url", modify: func(c *Config) {
+			c.Mail.SMTPHost, c.Mail.SMTPPort, c.Mail.SMTPFrom = "smtp.example.com", "587", "users@example.com"
+		}, wantErr: "verify url is required"},
+		{name: "relative verify url", modify: func(c *Config) { c.Mail.VerifyURL = "/users/verify" }, wantErr: "absolute URL"},
+		{name: "smtp without timeout", modify: func(c *Config) {
+			c.Mail.VerifyURL = "https://users.example.com/users/verify"
+			c.Mail.SMTPHost, c.Mail.SMTPPort, c.Mail.SMTPFrom = "smtp.example.com", "587", "users@example.com"
+			c.Mail.SMTPTimeout = 0
+		}, wantErr: "smtp timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Database.Name, c.Database.User, c.Auth.Token = "users", "root", "secret"
+			if tt.modify != nil {
+				tt.modify(c)
+			}
+
+			err := c.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "*", want: true},
+		{origin: "https://app.example.com", want: true},
+		{origin: "http://localhost:3000", want: true},
+		{origin: "https://*.example.com", want: true},
+		{origin: "https://*.example.com:8443", want: true},
+		{origin: "https://*.localhost", want: true},
+		{origin: "https://*"},
+		{origin: "https://*:8443"},
+		{origin: "https://*."},
+		{origin: "https://*..example.com"},
+		{origin: "https://*example.com"},
+		{origin: "https://app.*.example.com"},
+		{origin: "https://*.*.example.com"},
+		{origin: "*://app.example.com"},
+		{origin: "https://*.example.com/path"},
+		{origin: "ftp://app.example.com"},
+		{origin: "https://app.example.com/"},
+		{origin: "https://user@app.example.com"},
+		{origin: "app.example.com"},
+	}
+	for _, tt := range tests {
+		if got := validOrigin(tt.origin); got != tt.want {
+			t.Errorf("validOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCORSCredentials(t *testing.T) {
+	tests := []struct {
+		origin  string
+		wantErr bool
+	}{
+		{origin: "https://app.example.com"},
+		{origin: "*", wantErr: true},
+		{origin: "https://*.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		c := Default()
+		c.Database.Name, c.Database.User, c.Auth.Token = "users", "root", "secret"
+		c.CORS.AllowedOrigins, c.CORS.AllowCredentials = []string{tt.origin}, true
+
+		err := c.Validate()
+		if (err != nil) != tt.wantErr || (err != nil && !strings.Contains(err.Error(), "allow credentials")) {
+			t.Errorf("Validate() with origin %q = %v, want error %v", tt.origin, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuthClients(t *testing.T) {
+	a := Auth{APIKeys: []string{"billing:k1", " crm : k2 ", "invalid"}}
+	want := map[string]string{"k1": "billing", "k2": "crm"}
+	if got := a.Clients(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Clients() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,287 @@
+package cors
+
+/*
+Package cors aplica la política CORS (Cross-Origin Resource Sharing) del servicio: qué orígenes pueden llamar a la API
+desde un navegador, con qué métodos y encabezados, qué encabezados de la respuesta pueden leer, si se envían
+credenciales y durante cuánto tiempo el navegador puede reutilizar la respuesta de una solicitud preflight.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// Errores con los que se rechazan las solicitudes que no cumplen la política.
+var (
+	// ErrOriginNotAllowed se produce cuando el origen de la solicitud no está permitido.
+	ErrOriginNotAllowed = errors.New("origin not allowed")
+	// ErrMethodNotAllowed se produce cuando la solicitud preflight pide un método que la ruta no admite.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	// ErrHeaderNotAllowed se produce cuando la solicitud preflight pide un encabezado que no está permitido.
+	ErrHeaderNotAllowed = errors.New("header not allowed")
+)
+
+func init() {
+	apierror.Register(ErrOriginNotAllowed, http.StatusForbidden, "cors_origin_not_allowed")
+	apierror.Register(ErrMethodNotAllowed, http.StatusForbidden, "cors_method_not_allowed")
+	apierror.Register(ErrHeaderNotAllowed, http.StatusForbidden, "cors_header_not_allowed")
+}
+
+// Encabezados de CORS de las solicitudes y de las respuestas.
+const (
+	headerOrigin           = "Origin"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+)
+
+// Options define la política CORS.
+type Options struct {
+	// AllowedOrigins son los orígenes permitidos. Cada uno puede ser "*" (cualquier origen), un origen exacto
+	// ("https://app.example.com") o un origen con comodín en el subdominio ("https://*.example.com").
+	// Si está vacío, solo se aceptan solicitudes del mismo origen.
+	AllowedOrigins []string
+	// AllowedHeaders son los encabezados que el navegador puede enviar en las solicitudes.
+	AllowedHeaders []string
+	// ExposedHeaders son los encabezados de la respuesta que el navegador expone al código del cliente.
+	ExposedHeaders []string
+	// AllowCredentials permite que el navegador envíe cookies y credenciales HTTP en las solicitudes.
+	AllowCredentials bool
+	// MaxAge es el tiempo durante el cual el navegador puede reutilizar la respuesta de una solicitud preflight.
+	MaxAge time.Duration
+	// EncodeError codifica la respuesta de las solicitudes rechazadas. Si es nil, se responde con apierror.New.
+	EncodeError func(c *gin.Context, err error)
+}
+
+// Policy aplica la política CORS a las rutas de un enrutador Gin.
+type Policy interface {
+	// AllowOrigin indica si el origen está permitido.
+	AllowOrigin(origin string) bool
+	// GinMiddleware devuelve un middleware de Gin que agrega los encabezados de CORS a las solicitudes de origen
+	// cruzado y rechaza con 403 las de orígenes no permitidos.
+	GinMiddleware() gin.HandlerFunc
+	// Preflight devuelve el manejador de las solicitudes preflight (OPTIONS) de una ruta que admite los métodos indicados.
+	Preflight(methods ...string) gin.HandlerFunc
+	// Register agrega a un enrutador el manejador preflight de cada ruta registrada, con los métodos que admite.
+	// Debe llamarse después de registrar las rutas.
+	Register(r *gin.Engine)
+}
+
+// policy es una implementación de Policy.
+type policy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	wildcards        []wildcard
+	allowedHeaders   map[string]bool
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+	encodeError      func(c *gin.Context, err error)
+}
+
+// wildcard es un origen con comodín, separado en la parte anterior y la posterior al "*".
+type wildcard struct {
+	prefix, suffix string
+}
+
+// New crea una política CORS con las opciones indicadas.
+func New(opts Options) Policy {
+	p := &policy{
+		origins:          make(map[string]bool),
+		allowedHeaders:   make(map[string]bool),
+		allowCredentials: opts.AllowCredentials,
+		encodeError:      opts.EncodeError,
+	}
+	if p.encodeError == nil {
+		p.encodeError = func(c *gin.Context, err error) {
+			resp := apierror.New(err)
+			c.JSON(resp.StatusCode(), resp)
+		}
+	}
+
+	for _, o := range opts.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch {
+		case o == "*":
+			p.anyOrigin = true
+		case strings.Contains(o, "*"):
+			prefix, suffix, _ := strings.Cut(o, "*")
+			p.wildcards = append(p.wildcards, wildcard{prefix: prefix, suffix: suffix})
+		case o != "":
+			p.origins[o] = true
+		}
+	}
+
+	headers := make([]string, 0, len(opts.AllowedHeaders))
+	for _, h := range opts.AllowedHeaders {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h != "" && !p.allowedHeaders[h] {
+			p.allowedHeaders[h] = true
+			headers = append(headers, h)
+		}
+	}
+	p.allowHeaders = strings.Join(headers, ", ")
+	p.exposeHeaders = strings.Join(opts.ExposedHeaders, ", ")
+
+	if opts.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return p
+}
+
+// AllowOrigin indica si el origen está permitido.
+func (p *policy) AllowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		// El comodín debe reemplazar al menos un carácter, para que "https://*.example.com" no acepte "https://.example.com".
+		if len(origin) > len(w.prefix)+len(w.suffix) && strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GinMiddleware devuelve un middleware de Gin que agrega los encabezados de CORS a las solicitudes de origen
+// cruzado y rechaza con 403 las de orígenes no permitidos. Las solicitudes sin Origin, como las de clientes que no
+// son navegadores, y las del mismo origen no se modifican. Las solicitudes preflight las atiende Preflight.
+func (p *policy) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader(headerOrigin)
+		if origin == "" || isPreflight(c.Request) || sameOrigin(c.Request, origin) {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", headerOrigin)
+		if !p.AllowOrigin(origin) {
+			p.reject(c, ErrOriginNotAllowed)
+			return
+		}
+
+		p.setAllowOrigin(c, origin)
+		if p.exposeHeaders != "" {
+			c.Header(headerExposeHeaders, p.exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+// Preflight devuelve el manejador de las solicitudes preflight (OPTIONS) de una ruta que admite los métodos indicados.
+// Responde 204 si el origen, el método y los encabezados solicitados están permitidos, y 403 en caso contrario.
+func (p *policy) Preflight(methods ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	allowMethods := strings.Join(methods, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader(headerOrigin)
+		c.Writer.Header().Add("Vary", headerOrigin)
+		c.Writer.Header().Add("Vary", headerRequestMethod)
+		c.Writer.Header().Add("Vary", headerRequestHeaders)
+
+		// Una solicitud OPTIONS que no es preflight solo informa los métodos que admite la ruta.
+		if !isPreflight(c.Request) {
+			c.Header("Allow", allowMethods)
+			c.Status(http.StatusNoContent)
+			return
+		}
+
+		if !p.AllowOrigin(origin) && !sameOrigin(c.Request, origin) {
+			p.reject(c, ErrOriginNotAllowed)
+			return
+		}
+		if method := c.GetHeader(headerRequestMethod); !allowed[method] {
+			p.reject(c, fmt.Errorf("%w: '%s'", ErrMethodNotAllowed, method))
+			return
+		}
+		for _, h := range strings.Split(c.GetHeader(headerRequestHeaders), ",") {
+			if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" && !p.allowedHeaders[h] {
+				p.reject(c, fmt.Errorf("%w: '%s'", ErrHeaderNotAllowed, h))
+				return
+			}
+		}
+
+		p.setAllowOrigin(c, origin)
+		c.Header(headerAllowMethods, allowMethods)
+		if p.allowHeaders != "" {
+			c.Header(headerAllowHeaders, p.allowHeaders)
+		}
+		if p.maxAge != "" {
+			c.Header(headerMaxAge, p.maxAge)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Register agrega a un enrutador el manejador preflight de cada ruta registrada, con los métodos que admite.
+// Debe llamarse después de registrar las rutas.
+func (p *policy) Register(r *gin.Engine) {
+	methods := make(map[string][]string)
+	for _, route := range r.Routes() {
+		if route.Method != http.MethodOptions {
+			methods[route.Path] = append(methods[route.Path], route.Method)
+		}
+	}
+
+	for path, m := range methods {
+		sort.Strings(m)
+		r.OPTIONS(path, p.Preflight(m...))
+	}
+}
+
+// setAllowOrigin agrega los encabezados que autorizan al origen y, si corresponde, el envío de credenciales.
+// Con credenciales el navegador no acepta "*", por lo que siempre se devuelve el origen de la solicitud.
+func (p *policy) setAllowOrigin(c *gin.Context, origin string) {
+	if p.anyOrigin && !p.allowCredentials {
+		c.Header(headerAllowOrigin, "*")
+	} else {
+		c.Header(headerAllowOrigin, origin)
+	}
+	if p.allowCredentials {
+		c.Header(headerAllowCredentials, "true")
+	}
+}
+
+// isPreflight indica si la solicitud es una solicitud preflight de CORS.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(headerOrigin) != "" && r.Header.Get(headerRequestMethod) != ""
+}
+
+// sameOrigin indica si el origen coincide con el del propio servidor; esas solicitudes no son de origen cruzado.
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return u.Scheme == scheme && strings.EqualFold(u.Host, r.Host)
+}
+
+// reject responde 403 con el motivo del rechazo y detiene la cadena de manejadores.
+func (p *policy) reject(c *gin.Context, err error) {
+	p.encodeError(c, err)
+	c.Abort()
+}
@@ -0,0 +1,82 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchFunc obtiene los valores de varias claves en una sola operación, por ejemplo una consulta WHERE id IN (...).
+// Las claves que no están en el resultado se consideran inexistentes y se cargan con el valor cero de V.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// result es el valor de una clave, o el error del lote en el que se consultó.
+type result[V any] struct {
+	value V
+	err   error
+	done  bool
+}
+
+// Loader agrupa las cargas individuales de claves en lotes. Load no consulta la clave: la agrega al lote pendiente y
+// devuelve una función que obtiene su valor; la primera vez que se llama a alguna de esas funciones se consultan
+// todas las claves pendientes con llamadas a la BatchFunc de hasta maxBatch claves. Los valores se guardan, de modo
+// que cada clave se consulta una sola vez durante la vida del Loader; por eso se crea un Loader por solicitud.
+type Loader[K comparable, V any] struct {
+	batch    BatchFunc[K, V]
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*result[V]
+}
+
+// New crea un Loader que obtiene los lotes de claves con batch, de a maxBatch claves como máximo por llamada; si
+// maxBatch es cero o negativo, los lotes no tienen límite.
+func New[K comparable, V any](maxBatch int, batch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		batch:    batch,
+		maxBatch: maxBatch,
+		results:  make(map[K]*result[V]),
+	}
+}
+
+// Load agrega la clave al lote pendiente, si no se cargó antes, y devuelve una función que devuelve su valor.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	r, ok := l.results[key]
+	if !ok {
+		r = &result[V]{}
+		l.results[key] = r
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !r.done {
+			l.dispatch(ctx)
+		}
+		return r.value, r.err
+	}
+}
+
+// dispatch consulta las claves pendientes con llamadas a la BatchFunc de hasta maxBatch claves y guarda sus valores.
+// Si una consulta falla, el error se devuelve para todas las claves de ese lote. Debe llamarse con mu bloqueado.
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	pending := l.pending
+	l.pending = nil
+
+	for len(pending) > 0 {
+		keys := pending
+		if l.maxBatch > 0 && len(keys) > l.maxBatch {
+			keys = keys[:l.maxBatch]
+		}
+		pending = pending[len(keys):]
+
+		values, err := l.batch(ctx, keys)
+		for _, key := range keys {
+			r := l.results[key]
+			r.value, r.err, r.done = values[key], err, true
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestLoaderBatches verifica que las claves pendientes se consulten en lotes de hasta maxBatch claves, cada clave una
+// sola vez, y que el error de un lote se devuelva solo para sus claves.
+func TestLoaderBatches(t *testing.T) {
+	var batches [][]int
+	l := New(2, func(_ context.Context, keys []int) (map[int]string, error) {
+		batches = append(batches, append([]int(nil), keys...))
+		if keys[0] == 5 {
+			return nil, errors.New("batch failed")
+		}
+		values := make(map[int]string, len(keys))
+		for _, k := range keys {
+			values[k] = string(rune('a' + k))
+		}
+		return values, nil
+	})
+
+	ctx := context.Background()
+	var thunks []func() (string, error)
+	for _, k := range []int{1, 2, 3, 1, 4, 5} {
+		thunks = append(thunks, l.Load(ctx, k))
+	}
+	for i, want := range []string{"b", "c", "d", "b", "e"} {
+		if got, err := thunks[i](); err != nil || got != want {
+			t.Errorf("thunk %d = %q, %v; want %q", i, got, err, want)
+		}
+	}
+	if _, err := thunks[5](); err == nil {
+		t.Error("thunk 5 returned no error")
+	}
+
+	if want := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches = %v, want %v", batches, want)
+	}
+}
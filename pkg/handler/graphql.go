@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/metrics"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/openapi"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	// graphQLPath es la ruta del endpoint de GraphQL.
+	graphQLPath = "/graphql"
+
+	// graphQLRequestKey es la clave con la que graphQLRequest guarda en el contexto de Gin la operación decodificada.
+	graphQLRequestKey = "graphQLRequest"
+)
+
+// NewUserGraphQLServer configura un servidor HTTP utilizando Gin para el endpoint de GraphQL de usuarios, con los
+// mismos middlewares, el mismo token de autorización y los mismos límites de solicitudes por cliente que las rutas de
+// usuarios. Las operaciones se envían con POST, con la consulta, el nombre de la operación y las variables en JSON.
+func NewUserGraphQLServer(cfg *config.Config, gql user.GraphQL, m metrics.Metrics, store ratelimit.Store, l *slog.Logger) http.Handler {
+	r := newEngine(cfg.Server)
+
+	// Guarda en el contexto de cada solicitud su ID, el actor que la realiza y el formato de los errores, la traza, la registra en el log y la mide.
+	// Los pánicos de los manejadores se registran con su stack y responden 500, y se aplica la política CORS a las
+	// solicitudes de los navegadores desde otros orígenes.
+	policy := newCORS(cfg.CORS)
+	r.Use(gin.Recovery(), requestContext(), errorFormat(cfg.Errors), tracing.GinMiddleware(tracerName), requestLog(l), m.GinMiddleware(), transport.GinRecovery(l, encodeError), policy.GinMiddleware())
+
+	// El endpoint requiere el token de autorización. Cada solicitud consume un token del límite de las consultas y,
+	// además, un token del límite de las escrituras por cada mutación de la operación, de modo que una solicitud con
+	// varias mutaciones (por ejemplo, con alias) no evade el límite de las escrituras.
+	auth := authorize(newAuthenticator(cfg.Auth))
+	limit := rateLimit(cfg.RateLimit, store, l)
+	read := ratelimit.Limit{Requests: cfg.RateLimit.Requests, Period: cfg.RateLimit.Period.Std()}
+	write := ratelimit.Limit{Requests: cfg.RateLimit.WriteRequests, Period: cfg.RateLimit.Period.Std()}
+	mutations := func(c *gin.Context) int {
+		req, _ := c.Get(graphQLRequestKey)
+		return gql.Mutations(req.(user.GraphQLReq))
+	}
+
+	// Si la solicitud envía el encabezado X-Two-Factor-Code, cada mutación updateUser o deleteUser consume además un
+	// token del límite de los códigos de dos factores del usuario al que se refiere, el mismo que en las rutas HTTP y
+	// en gRPC.
+	twoFactor := graphQLTwoFactorRateLimit(cfg.RateLimit, store, l, func(c *gin.Context) []uint64 {
+		if c.GetHeader(twoFactorCodeHeader) == "" {
+			return nil
+		}
+		req, _ := c.Get(graphQLRequestKey)
+		return gql.TwoFactorTargets(req.(user.GraphQLReq))
+	})
+
+	r.POST(graphQLPath, auth, limit("POST /graphql", read), graphQLRequest(), rateLimitCost(cfg.RateLimit, store, l, "POST /graphql mutations", write, mutations), twoFactor, transport.GinServer(
+		makeGraphQLEndpoint(gql),
+		decodeGraphQL,
+		encodeGraphQL,
+		encodeError,
+	))
+
+	// La ruta responde a las solicitudes preflight con los métodos que admite.
+	policy.Register(r)
+
+	return r
+}
+
+// graphQLTwoFactorRateLimit devuelve un middleware que consume un token del balde de los códigos de dos factores de
+// cada usuario que devuelve targets (ver twoFactorRateLimit). Si los límites están deshabilitados, el middleware no
+// hace nada.
+func graphQLTwoFactorRateLimit(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger, targets func(c *gin.Context) []uint64) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limit := ratelimit.Limit{Requests: cfg.TwoFactorRequests, Period: cfg.TwoFactorPeriod.Std()}
+	keys := func(c *gin.Context) []string {
+		ids := targets(c)
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = twoFactorKey(id)
+		}
+		return keys
+	}
+	return ratelimit.GinKeysMiddleware(store, twoFactorBucket, limit, keys, encodeError, l)
+}
+
+// makeGraphQLEndpoint crea el endpoint que ejecuta las operaciones de GraphQL. Los errores de la operación no son
+// errores del endpoint: se devuelven en el resultado, junto con los datos que sí se pudieron obtener.
+func makeGraphQLEndpoint(gql user.GraphQL) transport.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return gql.Execute(ctx, request.(user.GraphQLReq)), nil
+	}
+}
+
+// graphQLRequest es un middleware que decodifica el cuerpo JSON de la solicitud con la operación de GraphQL, analiza
+// su consulta una sola vez (ver user.GraphQLReq.Parse) y la guarda en el contexto de Gin, para que los límites de las
+// mutaciones y de los códigos de dos factores puedan analizarla antes de ejecutarla. Si el cuerpo no es válido,
+// responde 400, y si supera maxBodyBytes, 413, sin ejecutar el endpoint.
+func graphQLRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req user.GraphQLReq
+		if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				encodeError(c, openapi.ErrBodyTooLarge)
+			} else {
+				encodeError(c, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error())))
+			}
+			c.Abort()
+			return
+		}
+		if req.Query == "" {
+			encodeError(c, response.BadRequest("query is required"))
+			c.Abort()
+			return
+		}
+		req.Parse()
+		c.Set(graphQLRequestKey, req)
+		c.Next()
+	}
+}
+
+// decodeGraphQL devuelve la operación de GraphQL que decodificó graphQLRequest.
+func decodeGraphQL(c *gin.Context) (interface{}, error) {
+	req, ok := c.Get(graphQLRequestKey)
+	if !ok {
+		return nil, fmt.Errorf("graphql request not found in context")
+	}
+	return req, nil
+}
+
+// encodeGraphQL codifica el resultado de la operación con el formato de GraphQL ({data, errors}). Si la operación no
+// se pudo ejecutar porque la consulta no es válida para el esquema, sus errores no corresponden a ningún campo y se
+// responde 400; en los demás casos se responde 200, aunque algún campo tenga errores.
+func encodeGraphQL(c *gin.Context, resp interface{}) {
+	result, ok := resp.(*graphql.Result)
+	if !ok {
+		encodeError(c, fmt.Errorf("unexpected response type %T", resp))
+		return
+	}
+
+	status := http.StatusOK
+	if result.HasErrors() && len(result.Errors[0].Path) == 0 {
+		status = http.StatusBadRequest
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.JSON(status, result)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/gin-gonic/gin"
+)
+
+// TestGraphQLRequest verifica que el cuerpo de las operaciones de GraphQL se limite a maxBodyBytes y que las
+// operaciones válidas lleguen al endpoint decodificadas.
+func TestGraphQLRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/graphql", graphQLRequest(), func(c *gin.Context) {
+		req, _ := decodeGraphQL(c)
+		c.String(http.StatusOK, req.(user.GraphQLReq).Query)
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid", body: `{"query": "{ user(id: \"1\") { id } }"}`, wantStatus: http.StatusOK},
+		{name: "invalid json", body: `{"query":`, wantStatus: http.StatusBadRequest},
+		{name: "without query", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "too large", body: `{"query": "` + strings.Repeat(" ", maxBodyBytes) + `{ user(id: \"1\") { id } }"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Errorf("POST /graphql = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
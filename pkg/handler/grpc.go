@@ -0,0 +1,396 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	userv1 "github.com/EmiiFernandez/go-fundamentals-web-users/api/user/v1"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/openapi"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// NewUserGRPCServer configura un servidor gRPC con el servicio user.v1.UserService sobre los mismos endpoints de
+// usuarios que el servidor HTTP. Cada llamada requiere el token de autorización en el metadato authorization, se
+// traza y se registra en el log con el ID de la solicitud del metadato x-request-id (o uno nuevo, que se devuelve en
+// los encabezados de la respuesta). Las llamadas de cada cliente se limitan con los mismos límites que las rutas
+// equivalentes del servidor HTTP, en baldes propios guardados en store, y los campos de las altas y modificaciones se validan contra el
+// mismo esquema. opts permite agregar opciones al servidor, como las credenciales TLS.
+func NewUserGRPCServer(cfg *config.Config, endpoints user.Endpoints, store ratelimit.Store, l *slog.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		grpcRequestContext(),
+		tracing.GRPCUnaryInterceptor(tracerName),
+		grpcRequestLog(l),
+		transport.GRPCRecovery(l),
+		grpcAuthorize(newAuthenticator(cfg.Auth)),
+		grpcRateLimit(cfg.RateLimit, store, l),
+		grpcTwoFactorRateLimit(cfg.RateLimit, store, l),
+		grpcIdempotencyKey(),
+	))
+	s := grpc.NewServer(opts...)
+
+	spec := userSpec()
+	userv1.RegisterUserServiceServer(s, &userGRPCServer{
+		create: transport.GRPCServer(transport.Endpoint(endpoints.Create), decodeCreateUserGRPC(spec), encodeCreateUserGRPC),
+		get:    transport.GRPCServer(transport.Endpoint(endpoints.Get), decodeGetUserGRPC, encodeGetUserGRPC),
+		list:   transport.GRPCServer(transport.Endpoint(endpoints.GetAll), decodeListUsersGRPC, encodeListUsersGRPC),
+		update: transport.GRPCServer(transport.Endpoint(endpoints.Update), decodeUpdateUserGRPC(spec), encodeUpdateUserGRPC),
+		delete: transport.GRPCServer(transport.Endpoint(endpoints.Delete), decodeDeleteUserGRPC, encodeDeleteUserGRPC),
+	})
+
+	// El servicio de reflexión permite a herramientas como grpcurl descubrir los servicios y mensajes sin el .proto.
+	// Es un servicio de streaming, de modo que no pasa por los interceptores unarios y no requiere el token; por eso
+	// está deshabilitado de forma predeterminada.
+	if cfg.GRPC.Reflection {
+		reflection.Register(s)
+	}
+	return s
+}
+
+// userGRPCServer implementa userv1.UserServiceServer delegando cada método en el endpoint adaptado con transport.GRPCServer.
+type userGRPCServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	create func(context.Context, *userv1.CreateUserRequest) (*userv1.CreateUserResponse, error)
+	get    func(context.Context, *userv1.GetUserRequest) (*userv1.GetUserResponse, error)
+	list   func(context.Context, *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error)
+	update func(context.Context, *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error)
+	delete func(context.Context, *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error)
+}
+
+// CreateUser crea un usuario.
+func (s *userGRPCServer) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.CreateUserResponse, error) {
+	return s.create(ctx, req)
+}
+
+// GetUser obtiene un usuario por su ID.
+func (s *userGRPCServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	return s.get(ctx, req)
+}
+
+// ListUsers obtiene todos los usuarios que cumplen los filtros.
+func (s *userGRPCServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	return s.list(ctx, req)
+}
+
+// UpdateUser modifica los atributos enviados de un usuario.
+func (s *userGRPCServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
+	return s.update(ctx, req)
+}
+
+// DeleteUser elimina un usuario.
+func (s *userGRPCServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	return s.delete(ctx, req)
+}
+
+// grpcRequestContext es un interceptor que guarda en el contexto de la llamada su ID, el actor que la realiza, el actor
+// declarado, el código del segundo factor y la identidad del certificado de cliente (mTLS), tomados de los metadatos
+// x-request-id, x-actor y x-two-factor-code, del mismo modo que requestContext en el servidor HTTP.
+func grpcRequestContext() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		client := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				client = clientIdentity(&tlsInfo.State)
+			}
+		}
+
+		ctx, id := newRequestContext(ctx, firstMetadata(md, requestIDHeader), client, firstMetadata(md, actorHeader))
+		if code := firstMetadata(md, twoFactorCodeHeader); code != "" {
+			ctx = requestctx.WithTwoFactorCode(ctx, code)
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+		return handler(ctx, req)
+	}
+}
+
+// grpcIdempotencyKey es un interceptor que guarda en el contexto la clave del metadato idempotency-key, si se envía,
+// del mismo modo que idempotencyKey en el servidor HTTP. La clave se combina con el método y el cliente, para que dos
+// clientes o dos métodos no compartan la misma respuesta; por eso se ejecuta después de grpcAuthorize.
+func grpcIdempotencyKey() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if key := firstMetadata(md, idempotencyKeyHeader); key != "" {
+			if len(key) > maxIdempotencyKeyLength {
+				return nil, status.Errorf(codes.InvalidArgument, "%s can't be longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+			}
+			scoped := info.FullMethod + ":" + clientKeyOf(ctx, peerIP(ctx)) + ":" + key
+			ctx = requestctx.WithIdempotencyKey(ctx, scoped)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// grpcRequestLog devuelve un interceptor que registra cada llamada al finalizar, con su método, código y duración.
+// Las llamadas que terminan con un error del servidor se registran con nivel error.
+func grpcRequestLog(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown || code == codes.DataLoss {
+			level = slog.LevelError
+		}
+		l.Log(ctx, level, "grpc request",
+			"method", info.FullMethod,
+			"code", code.String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", peerIP(ctx),
+		)
+		return resp, err
+	}
+}
+
+// grpcAuthorize devuelve un interceptor que verifica las credenciales del metadato authorization con auth, del mismo
+// modo que authorize en el servidor HTTP. Si no son válidas, responde Unauthenticated sin ejecutar el endpoint.
+func grpcAuthorize(auth authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		client, ok := auth.authenticate(firstMetadata(md, "authorization"))
+		if !ok {
+			return nil, transport.GRPCError(response.Unauthorized("invalid token"))
+		}
+		return handler(authenticated(ctx, client), req)
+	}
+}
+
+// grpcRateLimit devuelve un interceptor que limita las llamadas de cada cliente a cada método, del mismo modo que
+// rateLimit en el servidor HTTP: las consultas utilizan el límite de consultas y las escrituras el de escrituras. El
+// cliente se identifica con clientKeyOf, por lo que se ejecuta después de grpcAuthorize. Si los límites están
+// deshabilitados, el interceptor no hace nada.
+func grpcRateLimit(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger) grpc.UnaryServerInterceptor {
+	if !cfg.Enabled {
+		return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(ctx, req)
+		}
+	}
+
+	read := ratelimit.Limit{Requests: cfg.Requests, Period: cfg.Period.Std()}
+	write := ratelimit.Limit{Requests: cfg.WriteRequests, Period: cfg.Period.Std()}
+	limits := map[string]ratelimit.Limit{
+		userv1.UserService_CreateUser_FullMethodName: write,
+		userv1.UserService_GetUser_FullMethodName:    read,
+		userv1.UserService_ListUsers_FullMethodName:  read,
+		userv1.UserService_UpdateUser_FullMethodName: write,
+		userv1.UserService_DeleteUser_FullMethodName: write,
+	}
+	key := func(ctx context.Context) string { return clientKeyOf(ctx, peerIP(ctx)) }
+	return ratelimit.GRPCUnaryInterceptor(store, limits, key, transport.GRPCError, l)
+}
+
+// grpcTwoFactorRateLimit devuelve un interceptor que limita los códigos de dos factores que se prueban para cada
+// usuario con el metadato x-two-factor-code en UpdateUser y DeleteUser, en el mismo balde por usuario que
+// twoFactorRateLimit en el servidor HTTP. Si los límites están deshabilitados, el interceptor no hace nada.
+func grpcTwoFactorRateLimit(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger) grpc.UnaryServerInterceptor {
+	if !cfg.Enabled {
+		return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(ctx, req)
+		}
+	}
+
+	limit := ratelimit.Limit{Requests: cfg.TwoFactorRequests, Period: cfg.TwoFactorPeriod.Std()}
+	key := func(ctx context.Context, _ string, req interface{}) string {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if firstMetadata(md, twoFactorCodeHeader) == "" {
+			return ""
+		}
+		switch r := req.(type) {
+		case *userv1.UpdateUserRequest:
+			return twoFactorKey(r.GetId())
+		case *userv1.DeleteUserRequest:
+			return twoFactorKey(r.GetId())
+		}
+		return ""
+	}
+	return ratelimit.GRPCRequestInterceptor(store, twoFactorBucket, limit, key, transport.GRPCError, l)
+}
+
+// firstMetadata devuelve el primer valor de una clave de los metadatos, o una cadena vacía si no está.
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP devuelve la dirección IP del cliente de la llamada.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// decodeCreateUserGRPC devuelve el decodificador de la solicitud de creación de un usuario, que valida los campos
+// contra el esquema del cuerpo de POST /users de spec, del mismo modo que el servidor HTTP.
+func decodeCreateUserGRPC(spec *openapi.Document) func(context.Context, *userv1.CreateUserRequest) (interface{}, error) {
+	return func(_ context.Context, req *userv1.CreateUserRequest) (interface{}, error) {
+		r := user.CreateReq{
+			FirstName: req.GetFirstName(),
+			LastName:  req.GetLastName(),
+			Email:     req.GetEmail(),
+		}
+		if err := validateGRPC(spec, http.MethodPost, "/users", r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// decodeGetUserGRPC decodifica la solicitud de un usuario por su ID.
+func decodeGetUserGRPC(_ context.Context, req *userv1.GetUserRequest) (interface{}, error) {
+	return user.GetReq{ID: req.GetId()}, nil
+}
+
+// decodeListUsersGRPC decodifica los filtros de la consulta de usuarios.
+func decodeListUsersGRPC(_ context.Context, req *userv1.ListUsersRequest) (interface{}, error) {
+	return user.GetAllReq{EmailVerified: req.EmailVerified}, nil
+}
+
+// decodeUpdateUserGRPC devuelve el decodificador de la solicitud de modificación de un usuario, que valida los campos
+// enviados contra el esquema del cuerpo de PATCH /users/:id de spec; los campos ausentes no se modifican.
+func decodeUpdateUserGRPC(spec *openapi.Document) func(context.Context, *userv1.UpdateUserRequest) (interface{}, error) {
+	return func(_ context.Context, req *userv1.UpdateUserRequest) (interface{}, error) {
+		fields := make(map[string]string)
+		for name, value := range map[string]*string{"first_name": req.FirstName, "last_name": req.LastName, "email": req.Email} {
+			if value != nil {
+				fields[name] = *value
+			}
+		}
+		if err := validateGRPC(spec, http.MethodPatch, "/users/:id", fields); err != nil {
+			return nil, err
+		}
+		return user.UpdateReq{
+			ID:        req.GetId(),
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+			Email:     req.Email,
+		}, nil
+	}
+}
+
+// validateGRPC valida los campos de una solicitud de gRPC, codificados como JSON, contra el esquema del cuerpo de la
+// ruta equivalente del servidor HTTP. Devuelve el apierror.ValidationError de spec.ValidateBody, que
+// transport.GRPCError convierte en InvalidArgument con un detalle BadRequest por cada campo.
+func validateGRPC(spec *openapi.Document, method, path string, fields interface{}) error {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return spec.ValidateBody(method, path, body)
+}
+
+// decodeDeleteUserGRPC decodifica la solicitud de eliminación de un usuario.
+func decodeDeleteUserGRPC(_ context.Context, req *userv1.DeleteUserRequest) (interface{}, error) {
+	return user.DeleteReq{ID: req.GetId()}, nil
+}
+
+// encodeCreateUserGRPC codifica el usuario creado.
+func encodeCreateUserGRPC(_ context.Context, resp interface{}) (*userv1.CreateUserResponse, error) {
+	var u domain.User
+	if err := responseData(resp, &u); err != nil {
+		return nil, err
+	}
+	return &userv1.CreateUserResponse{User: userToProto(u)}, nil
+}
+
+// encodeGetUserGRPC codifica el usuario solicitado.
+func encodeGetUserGRPC(_ context.Context, resp interface{}) (*userv1.GetUserResponse, error) {
+	var u domain.User
+	if err := responseData(resp, &u); err != nil {
+		return nil, err
+	}
+	return &userv1.GetUserResponse{User: userToProto(u)}, nil
+}
+
+// encodeListUsersGRPC codifica los usuarios que cumplen los filtros.
+func encodeListUsersGRPC(_ context.Context, resp interface{}) (*userv1.ListUsersResponse, error) {
+	var users []domain.User
+	if err := responseData(resp, &users); err != nil {
+		return nil, err
+	}
+	out := &userv1.ListUsersResponse{Users: make([]*userv1.User, 0, len(users))}
+	for _, u := range users {
+		out.Users = append(out.Users, userToProto(u))
+	}
+	return out, nil
+}
+
+// encodeUpdateUserGRPC codifica la respuesta de la modificación de un usuario.
+func encodeUpdateUserGRPC(_ context.Context, resp interface{}) (*userv1.UpdateUserResponse, error) {
+	return &userv1.UpdateUserResponse{}, nil
+}
+
+// encodeDeleteUserGRPC codifica la respuesta de la eliminación de un usuario.
+func encodeDeleteUserGRPC(_ context.Context, resp interface{}) (*userv1.DeleteUserResponse, error) {
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// responseData copia en target los datos de la respuesta de un endpoint. Los endpoints devuelven sus datos con el
+// tipo de dominio, salvo las respuestas repetidas por idempotencia, que los conservan como JSON; en ese caso los
+// datos se decodifican con las mismas etiquetas json con las que se guardaron.
+func responseData(resp interface{}, target interface{}) error {
+	r, ok := resp.(response.Response)
+	if !ok {
+		return fmt.Errorf("unexpected response type %T", resp)
+	}
+
+	switch data := r.GetData().(type) {
+	case *domain.User:
+		if u, ok := target.(*domain.User); ok && data != nil {
+			*u = *data
+			return nil
+		}
+	case []domain.User:
+		if users, ok := target.(*[]domain.User); ok {
+			*users = data
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(r.GetData())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
+
+// userToProto convierte un usuario del dominio en el mensaje de gRPC.
+func userToProto(u domain.User) *userv1.User {
+	return &userv1.User{
+		Id:               u.ID,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Email:            u.Email,
+		EmailVerified:    u.EmailVerified,
+		TwoFactorEnabled: u.TwoFactorEnabled,
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	userv1 "github.com/EmiiFernandez/go-fundamentals-web-users/api/user/v1"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestDecodeUserGRPC verifica que los campos de las altas y modificaciones de gRPC se validen con las mismas
+// restricciones que los cuerpos de la API HTTP y que los errores se conviertan en InvalidArgument.
+func TestDecodeUserGRPC(t *testing.T) {
+	spec := userSpec()
+	long := strings.Repeat("a", 46)
+	name := "Ada"
+
+	tests := []struct {
+		name   string
+		decode func() (interface{}, error)
+		valid  bool
+	}{
+		{
+			name: "create válido",
+			decode: func() (interface{}, error) {
+				return decodeCreateUserGRPC(spec)(context.Background(), &userv1.CreateUserRequest{FirstName: "Ada", LastName: "Lovelace"})
+			},
+			valid: true,
+		},
+		{
+			name: "create con nombre demasiado largo",
+			decode: func() (interface{}, error) {
+				return decodeCreateUserGRPC(spec)(context.Background(), &userv1.CreateUserRequest{FirstName: long, LastName: "Lovelace"})
+			},
+		},
+		{
+			name: "update parcial válido",
+			decode: func() (interface{}, error) {
+				return decodeUpdateUserGRPC(spec)(context.Background(), &userv1.UpdateUserRequest{Id: 1, FirstName: &name})
+			},
+			valid: true,
+		},
+		{
+			name: "update con correo demasiado largo",
+			decode: func() (interface{}, error) {
+				email := long + "@example.com"
+				return decodeUpdateUserGRPC(spec)(context.Background(), &userv1.UpdateUserRequest{Id: 1, Email: &email})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.decode()
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("decode = %v; want nil", err)
+				}
+				return
+			}
+			if code := status.Code(transport.GRPCError(err)); code != codes.InvalidArgument {
+				t.Fatalf("code = %s (%v); want InvalidArgument", code, err)
+			}
+		})
+	}
+}
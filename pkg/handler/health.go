@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/health"
+	"github.com/gin-gonic/gin"
+)
+
+// NewHealthHTTPServer configura un servidor HTTP utilizando Gin para los endpoints de liveness y readiness.
+// Estos endpoints no requieren token de autorización, ya que los consulta el orquestador.
+func NewHealthHTTPServer(readiness health.Readiness) http.Handler {
+	r := gin.New()
+
+	// /healthz responde mientras el proceso esté en ejecución.
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+	})
+
+	// /readyz verifica las dependencias y responde 503 si alguna falla o si el servicio se está deteniendo.
+	r.GET("/readyz", func(c *gin.Context) {
+		report := readiness.Report(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	})
+
+	return r
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// NewMetricsHTTPServer configura un servidor HTTP utilizando Gin para el endpoint de métricas de Prometheus. A
+// diferencia de los endpoints de salud, requiere el mismo token de autorización que las rutas de usuarios, ya que
+// las métricas revelan las rutas, el volumen de tráfico y el estado de la base de datos. Conviene que Prometheus use
+// una clave de API propia (API_KEYS), enviada en el encabezado Authorization.
+func NewMetricsHTTPServer(cfg *config.Config, metrics http.Handler) http.Handler {
+	r := newEngine(cfg.Server)
+	r.Use(gin.Recovery(), requestContext(), errorFormat(cfg.Errors))
+
+	r.GET("/metrics", authorize(newAuthenticator(cfg.Auth)), gin.WrapH(metrics))
+
+	return r
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+)
+
+// TestMetricsRequiresToken verifica que el endpoint de métricas solo responda con el token o una clave de API.
+func TestMetricsRequiresToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Token, cfg.Auth.APIKeys = "secret", []string{"prometheus:k1"}
+	h := NewMetricsHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("up 1\n"))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "without token", want: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "other", want: http.StatusUnauthorized},
+		{name: "token", authorization: "secret", want: http.StatusOK},
+		{name: "api key", authorization: "k1", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("GET /metrics = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/openapi"
+)
+
+const (
+	// specPath es la ruta en la que se publica la especificación OpenAPI de las rutas de usuarios.
+	specPath = "/openapi.json"
+
+	// docsPath es la ruta de la interfaz web de la especificación.
+	docsPath = "/docs"
+
+	// docsScriptPath es la ruta del script de Redoc que usa la interfaz web.
+	docsScriptPath = "/docs/redoc.standalone.js"
+)
+
+// userSpec genera la especificación OpenAPI de las rutas de NewUserHTTPServer.
+func userSpec() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "go-fundamentals-web-users",
+		Version:     "1.0.0",
+		Description: "API de usuarios: alta, consulta, modificación y baja, verificación de correo, autenticación de dos factores y auditoría.",
+	}, userRoutes())
+}
+
+// userRoutes describe las rutas que registra NewUserHTTPServer, con los tipos de sus solicitudes y respuestas.
+// Debe mantenerse sincronizada con las rutas del servidor; TestUserSpecMatchesRoutes falla si difieren.
+func userRoutes() []openapi.Route {
+	id := openapi.Param{Name: "id", In: "path", Description: "ID del usuario", Type: uint64(0)}
+	twoFactorCode := openapi.Param{
+		Name: twoFactorCodeHeader, In: "header",
+		Description: "Código TOTP o de recuperación; obligatorio si el usuario tiene activada la autenticación de dos factores.",
+	}
+	users := []string{"users"}
+	twoFactor := []string{"two-factor"}
+	audit := []string{"audit"}
+
+	return []openapi.Route{
+		{
+			Method: http.MethodPost, Path: "/users", OperationID: "createUser", Summary: "Crea un usuario", Tags: users,
+			Params: []openapi.Param{{
+				Name: idempotencyKeyHeader, In: "header",
+				Description: "Clave con la que el cliente identifica los reintentos de la solicitud; los reintentos con la misma clave devuelven la misma respuesta.",
+			}},
+			Body: user.CreateReq{}, Status: http.StatusCreated, Data: domain.User{},
+			Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		},
+		{
+			Method: http.MethodGet, Path: "/users", OperationID: "listUsers", Summary: "Lista los usuarios", Tags: users,
+			Params: []openapi.Param{{Name: "email_verified", In: "query", Description: "Filtra los usuarios según hayan verificado o no su correo", Type: false}},
+			Data:   []domain.User{}, Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/batch-get", OperationID: "batchGetUsers", Summary: "Obtiene varios usuarios por sus IDs", Tags: users,
+			Body: user.BatchGetReq{}, Data: user.BatchGetResp{},
+			Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodGet, Path: "/users/search", OperationID: "searchUsers", Summary: "Busca usuarios por nombre, apellido o correo", Tags: users,
+			Params: []openapi.Param{
+				{Name: "q", In: "query", Description: "Palabras a buscar, sin distinguir mayúsculas ni acentos; cada palabra puede ser parte de una palabra del usuario", Required: true},
+				{Name: "limit", In: "query", Description: "Cantidad máxima de resultados (predeterminado: 10, máximo: 50)", Type: 0},
+			},
+			Data:   []user.SearchResult{},
+			Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodGet, Path: "/users/verify", OperationID: "verifyUserEmail", Summary: "Verifica el correo de un usuario", Tags: users,
+			Public: true,
+			Params: []openapi.Param{{Name: "token", In: "query", Description: "Token de verificación recibido por correo", Required: true}},
+			Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodGet, Path: "/users/:id", OperationID: "getUser", Summary: "Obtiene un usuario", Tags: users,
+			Params: []openapi.Param{id}, Data: domain.User{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPatch, Path: "/users/:id", OperationID: "updateUser", Summary: "Modifica los atributos enviados de un usuario", Tags: users,
+			Params: []openapi.Param{id, twoFactorCode}, Body: user.UpdateReq{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: "/users/:id", OperationID: "deleteUser", Summary: "Elimina un usuario", Tags: users,
+			Params: []openapi.Param{id, twoFactorCode},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/2fa/enroll", OperationID: "enrollTwoFactor", Summary: "Genera el secreto TOTP del usuario", Tags: twoFactor,
+			Params: []openapi.Param{id}, Data: user.TwoFactorEnrollment{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/2fa/confirm", OperationID: "confirmTwoFactor", Summary: "Activa la autenticación de dos factores con un código TOTP", Tags: twoFactor,
+			Params: []openapi.Param{id}, Body: user.TwoFactorReq{},
+			Data: struct {
+				RecoveryCodes []string `json:"recovery_codes"`
+			}{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/2fa/verify", OperationID: "verifyTwoFactor", Summary: "Verifica un código TOTP o de recuperación", Tags: twoFactor,
+			Params: []openapi.Param{id}, Body: user.TwoFactorReq{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPost, Path: "/users/:id/2fa/disable", OperationID: "disableTwoFactor", Summary: "Desactiva la autenticación de dos factores", Tags: twoFactor,
+			Params: []openapi.Param{id}, Body: user.TwoFactorReq{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: "/users/:id/history", OperationID: "getUserHistory", Summary: "Obtiene el historial de cambios de un usuario", Tags: audit,
+			Params: []openapi.Param{id}, Data: []domain.AuditEntry{},
+			Errors: []int{http.StatusBadRequest},
+		},
+		{
+			Method: http.MethodGet, Path: "/audit", OperationID: "listAuditEntries", Summary: "Consulta el log de auditoría", Tags: audit,
+			Params: []openapi.Param{
+				{Name: "user_id", In: "query", Description: "Filtra los registros del usuario indicado", Type: uint64(0)},
+				{Name: "actor", In: "query", Description: "Filtra los registros realizados por el actor indicado"},
+				{Name: "action", In: "query", Description: "Filtra los registros de la acción indicada", Enum: []string{domain.AuditActionCreate, domain.AuditActionUpdate, domain.AuditActionDelete}},
+				{Name: "from", In: "query", Description: "Filtra los registros realizados a partir de esa fecha (RFC 3339)", Format: "date-time"},
+				{Name: "to", In: "query", Description: "Filtra los registros realizados hasta esa fecha (RFC 3339)", Format: "date-time"},
+				{Name: "limit", In: "query", Description: "Cantidad máxima de registros a devolver", Type: 0},
+			},
+			Data:   []domain.AuditEntry{},
+			Errors: []int{http.StatusBadRequest},
+		},
+	}
+}
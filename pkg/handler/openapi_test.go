@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/metrics"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// TestUserSpecMatchesRoutes verifica que la especificación OpenAPI documente exactamente las rutas que registra
+// NewUserHTTPServer: falla si se agrega una ruta sin documentarla o si la especificación describe una ruta que ya no existe.
+func TestUserSpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewUserHTTPServer(config.Default(), user.Endpoints{}, metrics.New(), ratelimit.NewMemoryStore(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var registered []string
+	for _, route := range h.(*gin.Engine).Routes() {
+		// Las rutas preflight y las de la propia especificación no forman parte de la API documentada.
+		if route.Method == http.MethodOptions || route.Path == specPath || route.Path == docsPath || route.Path == docsScriptPath {
+			continue
+		}
+		registered = append(registered, route.Method+" "+route.Path)
+	}
+	sort.Strings(registered)
+
+	documented := userSpec().Routes()
+
+	for _, missing := range difference(registered, documented) {
+		t.Errorf("route %s is registered but not documented in the OpenAPI spec", missing)
+	}
+	for _, stale := range difference(documented, registered) {
+		t.Errorf("route %s is documented in the OpenAPI spec but not registered", stale)
+	}
+}
+
+// difference devuelve los elementos de a que no están en b.
+func difference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, s := range b {
+		in[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !in[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
@@ -1,66 +1,348 @@
 package handler
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/domain"
 	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/user"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/cors"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/metrics"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/openapi"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
 	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// requestIDHeader es el encabezado que transporta el ID de la solicitud.
+	requestIDHeader = "X-Request-ID"
+
+	// actorHeader es el encabezado con el que el cliente declara a quién realiza la solicitud para el log de auditoría.
+	actorHeader = "X-Actor"
+
+	// defaultActor es el actor que se registra cuando la solicitud no presenta un certificado de cliente; identifica al
+	// portador del token compartido.
+	defaultActor = "token"
+
+	// errorFormatKey es la clave con la que errorFormat guarda en el contexto de Gin el formato de los errores.
+	errorFormatKey = "errorFormat"
+
+	// twoFactorCodeHeader es el encabezado con el código del segundo factor que exigen la modificación y la eliminación
+	// de los usuarios con la autenticación de dos factores activada.
+	twoFactorCodeHeader = "X-Two-Factor-Code"
+
+	// idempotencyKeyHeader es el encabezado con el que el cliente identifica los reintentos de una misma solicitud.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// maxIdempotencyKeyLength es la longitud máxima de la clave de idempotencia.
+	maxIdempotencyKeyLength = 255
+
+	// maxBodyBytes es el tamaño máximo del cuerpo de las solicitudes que se validan contra la especificación OpenAPI y
+	// de las operaciones de GraphQL; el más grande, el de la obtención por lotes con 100 IDs, ocupa unos pocos KiB.
+	maxBodyBytes = 1 << 20
+
+	// tracerName es el nombre del tracer con el que se crean los spans de las solicitudes HTTP.
+	tracerName = "github.com/EmiiFernandez/go-fundamentals-web-users/pkg/handler"
+)
+
 // NewUserHTTPServer configura un servidor HTTP utilizando Gin para los endpoints relacionados con usuarios.
-func NewUserHTTPServer(endpoints user.Endpoints) http.Handler {
-	// Se crea un nuevo enrutador Gin con la configuración predeterminada.
-	r := gin.Default()
+// Cada ruta limita las solicitudes de cada cliente con un token bucket cuyo estado se guarda en store.
+func NewUserHTTPServer(cfg *config.Config, endpoints user.Endpoints, m metrics.Metrics, store ratelimit.Store, l *slog.Logger) http.Handler {
+	// Se crea un nuevo enrutador Gin; el log de las solicitudes lo realiza requestLog en formato estructurado.
+	r := newEngine(cfg.Server)
+
+	// Guarda en el contexto de cada solicitud su ID, el actor que la realiza y el formato de los errores, la traza, la registra en el log y la mide.
+	// Los pánicos de los manejadores se registran con su stack y responden 500, y se aplica la política CORS a las
+	// solicitudes de los navegadores desde otros orígenes.
+	policy := newCORS(cfg.CORS)
+	r.Use(gin.Recovery(), requestContext(), errorFormat(cfg.Errors), tracing.GinMiddleware(tracerName), requestLog(l), m.GinMiddleware(), transport.GinRecovery(l, encodeError), policy.GinMiddleware())
+
+	// Todos los endpoints requieren el token de autorización, salvo la verificación de correo.
+	auth := authorize(newAuthenticator(cfg.Auth))
+
+	// Los cuerpos de las solicitudes de alta, modificación y obtención por lotes se validan contra el esquema que
+	// publica la especificación OpenAPI antes de decodificarlos, para rechazar campos desconocidos, tipos incorrectos
+	// y cadenas demasiado largas. Los cuerpos de más de maxBodyBytes se rechazan sin terminar de leerlos.
+	spec := userSpec()
+	validate := openapi.GinValidator(spec, maxBodyBytes, encodeError)
+
+	// Límites de solicitudes por cliente de cada ruta: las consultas admiten más solicitudes que las escrituras. Los
+	// códigos de dos factores tienen además un límite por usuario, más estricto y compartido por todos los clientes
+	// y rutas, para dificultar la fuerza bruta: twoFactor lo aplica a las rutas de dos factores y twoFactorCode a la
+	// modificación y la eliminación cuando envían el encabezado X-Two-Factor-Code.
+	limit := rateLimit(cfg.RateLimit, store, l)
+	read := ratelimit.Limit{Requests: cfg.RateLimit.Requests, Period: cfg.RateLimit.Period.Std()}
+	write := ratelimit.Limit{Requests: cfg.RateLimit.WriteRequests, Period: cfg.RateLimit.Period.Std()}
+	twoFactor := twoFactorRateLimit(cfg.RateLimit, store, l, func(*gin.Context) int { return 1 })
+	twoFactorCode := twoFactorRateLimit(cfg.RateLimit, store, l, func(c *gin.Context) int {
+		if c.GetHeader(twoFactorCodeHeader) == "" {
+			return 0
+		}
+		return 1
+	})
 
 	// Configuración de los endpoints para crear, obtener todos, obtener uno y actualizar usuarios.
-	r.POST("/users", transport.GinServer(
+	r.POST("/users", auth, limit("POST /users", write), validate, idempotencyKey(), transport.GinServer(
 		transport.Endpoint(endpoints.Create),
 		decodeCreateUser,
 		encodeResponse,
 		encodeError,
 	))
-	r.GET("/users", transport.GinServer(
+	r.GET("/users", auth, limit("GET /users", read), transport.GinServer(
 		transport.Endpoint(endpoints.GetAll),
 		decodeGetAllUser,
 		encodeResponse,
 		encodeError,
 	))
-	r.GET("/users/:id", transport.GinServer(
+	r.POST("/users/batch-get", auth, limit("POST /users/batch-get", read), validate, transport.GinServer(
+		transport.Endpoint(endpoints.BatchGet),
+		decodeBatchGetUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/users/search", auth, limit("GET /users/search", read), transport.GinServer(
+		transport.Endpoint(endpoints.Search),
+		decodeSearchUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/users/verify", limit("GET /users/verify", write), transport.GinServer(
+		transport.Endpoint(endpoints.Verify),
+		decodeVerifyUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/users/:id", auth, limit("GET /users/:id", read), transport.GinServer(
 		transport.Endpoint(endpoints.Get),
 		decodeGetUser,
 		encodeResponse,
 		encodeError,
 	))
-	r.PATCH("/users/:id", transport.GinServer(
+	r.PATCH("/users/:id", auth, limit("PATCH /users/:id", write), twoFactorCode, validate, transport.GinServer(
 		transport.Endpoint(endpoints.Update),
 		decodeUpdateUser,
 		encodeResponse,
 		encodeError,
 	))
-	r.DELETE("/users/:id", transport.GinServer(
+	r.DELETE("/users/:id", auth, limit("DELETE /users/:id", write), twoFactorCode, transport.GinServer(
 		transport.Endpoint(endpoints.Delete),
 		decodeDeleteUser,
 		encodeResponse,
 		encodeError,
 	))
 
+	// Configuración de los endpoints de autenticación de dos factores.
+	r.POST("/users/:id/2fa/enroll", auth, limit("POST /users/:id/2fa/enroll", write), transport.GinServer(
+		transport.Endpoint(endpoints.EnrollTwoFactor),
+		decodeTwoFactorUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.POST("/users/:id/2fa/confirm", auth, limit("POST /users/:id/2fa/confirm", write), twoFactor, transport.GinServer(
+		transport.Endpoint(endpoints.ConfirmTwoFactor),
+		decodeTwoFactorUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.POST("/users/:id/2fa/verify", auth, limit("POST /users/:id/2fa/verify", write), twoFactor, transport.GinServer(
+		transport.Endpoint(endpoints.VerifyTwoFactor),
+		decodeTwoFactorUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.POST("/users/:id/2fa/disable", auth, limit("POST /users/:id/2fa/disable", write), twoFactor, transport.GinServer(
+		transport.Endpoint(endpoints.DisableTwoFactor),
+		decodeTwoFactorUser,
+		encodeResponse,
+		encodeError,
+	))
+
+	// Configuración de los endpoints de auditoría.
+	r.GET("/users/:id/history", auth, limit("GET /users/:id/history", read), transport.GinServer(
+		transport.Endpoint(endpoints.History),
+		decodeHistoryUser,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/audit", auth, limit("GET /audit", read), transport.GinServer(
+		transport.Endpoint(endpoints.Audit),
+		decodeAudit,
+		encodeResponse,
+		encodeError,
+	))
+
+	// Especificación OpenAPI de las rutas anteriores y su interfaz web; no requieren el token de autorización.
+	r.GET(specPath, openapi.JSONHandler(spec))
+	r.GET(docsPath, openapi.UIHandler("go-fundamentals-web-users", specPath, docsScriptPath))
+	r.GET(docsScriptPath, openapi.ScriptHandler())
+
+	// Cada ruta responde a las solicitudes preflight con los métodos que admite.
+	policy.Register(r)
+
 	return r // Retorna el enrutador Gin como un manejador HTTP.
 }
 
-// decodeGetUser decodifica los parámetros de la solicitud para obtener el ID del usuario.
-func decodeGetUser(c *gin.Context) (interface{}, error) {
-	// Verifica si el token de autorización es válido.
-	if err := tokenVerify(c.Request.Header.Get("Authorization")); err != nil {
-		return nil, response.Unauthorized(err.Error())
+// requestContext es un middleware que guarda en el contexto de la solicitud su ID, el actor que la realiza, el código
+// del segundo factor del encabezado X-Two-Factor-Code, si se envía, y, si la conexión presentó un certificado de
+// cliente verificado (mTLS), la identidad del cliente.
+// El ID se toma del encabezado X-Request-ID si es válido (ver requestctx.ValidRequestID) o se genera uno nuevo, y se
+// devuelve en la respuesta. El actor es la identidad del cliente o, sin certificado, "token"; el encabezado X-Actor no
+// está autenticado, por lo que se guarda aparte como actor declarado.
+func requestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, id := newRequestContext(c.Request.Context(), c.GetHeader(requestIDHeader), clientIdentity(c.Request.TLS), c.GetHeader(actorHeader))
+		if code := c.GetHeader(twoFactorCodeHeader); code != "" {
+			ctx = requestctx.WithTwoFactorCode(ctx, code)
+		}
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// newRequestContext devuelve una copia del contexto con los datos de la solicitud a partir del ID de solicitud, la
+// identidad del certificado de cliente y el actor declarado que envía el cliente, y el ID de la solicitud. Lo
+// comparten los servidores HTTP y gRPC.
+func newRequestContext(ctx context.Context, id, client, declared string) (context.Context, string) {
+	if !requestctx.ValidRequestID(id) {
+		id = newRequestID()
+	}
+	ctx = requestctx.WithRequestID(ctx, id)
+
+	actor := defaultActor
+	if client != "" {
+		ctx = requestctx.WithClientIdentity(ctx, client)
+		actor = requestctx.CleanActor(client)
+	}
+	ctx = requestctx.WithActor(ctx, actor)
+
+	if declared = requestctx.CleanActor(declared); declared != "" {
+		ctx = requestctx.WithDeclaredActor(ctx, declared)
+	}
+	return ctx, id
+}
+
+// newEngine crea un enrutador Gin que solo acepta la IP del cliente del encabezado X-Forwarded-For si la conexión
+// proviene de uno de los proxies de confianza de la configuración; sin proxies, la IP del cliente es la de la conexión.
+func newEngine(cfg config.Server) *gin.Engine {
+	r := gin.New()
+	// Los proxies se validan al cargar la configuración.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("trusted proxies: %v", err))
+	}
+	return r
+}
+
+// newRequestID genera un ID de solicitud aleatorio.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// problemFormat indica que los errores de la solicitud se codifican como documentos RFC 7807.
+type problemFormat struct {
+	typeBaseURL string // URL base del miembro type
+}
+
+// errorFormat es un middleware que elige el formato de los errores de la solicitud. Con el formato problem de la
+// configuración, todos los errores se envían como documentos RFC 7807; con el formato envelope (predeterminado), solo
+// los de las solicitudes cuyo encabezado Accept incluye application/problem+json, y el resto conserva el formato de
+// go-fundamentals-response.
+func errorFormat(cfg config.Errors) gin.HandlerFunc {
+	format := problemFormat{typeBaseURL: cfg.TypeBaseURL}
+	return func(c *gin.Context) {
+		if cfg.Format == config.ErrorFormatProblem {
+			c.Set(errorFormatKey, format)
+			c.Next()
+			return
+		}
+
+		// La respuesta de error depende del encabezado Accept, por lo que las cachés deben distinguirlo.
+		c.Writer.Header().Add("Vary", "Accept")
+		if acceptsProblem(c.GetHeader("Accept")) {
+			c.Set(errorFormatKey, format)
+		}
+		c.Next()
+	}
+}
+
+// acceptsProblem indica si el encabezado Accept incluye el tipo application/problem+json con una calidad mayor a 0.
+func acceptsProblem(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), apierror.ProblemContentType) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		if q != "q=0" && !strings.HasPrefix(q, "q=0;") && q != "q=0.0" {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIdentity devuelve la identidad del certificado de cliente verificado de la conexión: su Common Name o,
+// si no tiene, su primer nombre DNS o URI alternativo. Devuelve una cadena vacía si no hay un certificado verificado.
+func clientIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	cert := state.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.String()
+}
+
+// requestLog devuelve un middleware de Gin que registra cada solicitud al finalizar, con su ruta, código de estado y duración.
+// Las solicitudes que terminan con un error del servidor se registran con nivel error.
+func requestLog(l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		l.Log(c.Request.Context(), level, "http request",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
 	}
+}
 
+// decodeGetUser decodifica los parámetros de la solicitud para obtener el ID del usuario.
+func decodeGetUser(c *gin.Context) (interface{}, error) {
 	// Obtiene el ID del usuario de los parámetros de la URL.
 	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
 	if err != nil {
@@ -75,22 +357,35 @@ func decodeGetUser(c *gin.Context) (interface{}, error) {
 
 // decodeGetAllUser decodifica los parámetros de la solicitud para obtener todos los usuarios.
 func decodeGetAllUser(c *gin.Context) (interface{}, error) {
-	// Verifica si el token de autorización es válido.
-	if err := tokenVerify(c.Request.Header.Get("Authorization")); err != nil {
-		return nil, response.Unauthorized(err.Error())
+	var req user.GetAllReq
+
+	// Obtiene el filtro opcional por correo verificado de los parámetros de consulta.
+	if v := c.Query("email_verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.EmailVerified = &verified
 	}
 
-	// No se necesitan parámetros adicionales para obtener todos los usuarios.
-	return nil, nil
+	return req, nil
 }
 
-// decodeCreateUser decodifica los datos de la solicitud para crear un nuevo usuario.
-func decodeCreateUser(c *gin.Context) (interface{}, error) {
-	// Verifica si el token de autorización es válido.
-	if err := tokenVerify(c.Request.Header.Get("Authorization")); err != nil {
-		return nil, response.Unauthorized(err.Error())
+// decodeVerifyUser decodifica los parámetros de la solicitud para obtener el token de verificación de correo.
+// No requiere token de autorización, ya que el enlace se abre desde el correo del usuario.
+func decodeVerifyUser(c *gin.Context) (interface{}, error) {
+	token := c.Query("token")
+	if token == "" {
+		return nil, response.BadRequest("token is required")
 	}
 
+	return user.VerifyReq{
+		Token: token,
+	}, nil
+}
+
+// decodeCreateUser decodifica los datos de la solicitud para crear un nuevo usuario.
+func decodeCreateUser(c *gin.Context) (interface{}, error) {
 	// Decodifica el cuerpo JSON de la solicitud en la estructura CreateReq.
 	var req user.CreateReq
 	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
@@ -99,6 +394,28 @@ func decodeCreateUser(c *gin.Context) (interface{}, error) {
 	return req, nil
 }
 
+// decodeBatchGetUser decodifica el cuerpo JSON de la solicitud con los IDs de los usuarios a obtener.
+func decodeBatchGetUser(c *gin.Context) (interface{}, error) {
+	var req user.BatchGetReq
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error()))
+	}
+	return req, nil
+}
+
+// decodeSearchUser decodifica el texto de la búsqueda (parámetro q) y la cantidad máxima de resultados (parámetro limit).
+func decodeSearchUser(c *gin.Context) (interface{}, error) {
+	req := user.SearchReq{Query: c.Query("q")}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.Limit = limit
+	}
+	return req, nil
+}
+
 // decodeUpdateUser decodifica los datos de la solicitud para modificar un atributo del usuario.
 func decodeUpdateUser(c *gin.Context) (interface{}, error) {
 	// Se declara una variable para contener los datos de la solicitud de actualización del usuario.
@@ -109,11 +426,6 @@ func decodeUpdateUser(c *gin.Context) (interface{}, error) {
 		return nil, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error()))
 	}
 
-	// Verifica si el token de autorización es válido.
-	if err := tokenVerify(c.Request.Header.Get("Authorization")); err != nil {
-		return nil, response.Unauthorized(err.Error())
-	}
-
 	// Convierte el ID de usuario de tipo cadena a tipo uint64 para usarlo en la solicitud de actualización.
 	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
 	if err != nil {
@@ -127,11 +439,6 @@ func decodeUpdateUser(c *gin.Context) (interface{}, error) {
 
 // decodeDeleteUser decodifica los parámetros de la solicitud para obtener el ID del usuario y eliminar el usuario.
 func decodeDeleteUser(c *gin.Context) (interface{}, error) {
-	// Verifica si el token de autorización es válido.
-	if err := tokenVerify(c.Request.Header.Get("Authorization")); err != nil {
-		return nil, response.Unauthorized(err.Error())
-	}
-
 	// Obtiene el ID del usuario de los parámetros de la URL.
 	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
 	if err != nil {
@@ -147,6 +454,82 @@ func decodeDeleteUser(c *gin.Context) (interface{}, error) {
 	return req, nil
 }
 
+// decodeTwoFactorUser decodifica el ID del usuario y, si se envía, el código de la autenticación de dos factores.
+func decodeTwoFactorUser(c *gin.Context) (interface{}, error) {
+	// Obtiene el ID del usuario de los parámetros de la URL.
+	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
+	if err != nil {
+		return nil, response.BadRequest(err.Error())
+	}
+
+	// El cuerpo es opcional: la inscripción no requiere código.
+	var req user.TwoFactorReq
+	if c.Request.ContentLength != 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			return nil, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error()))
+		}
+	}
+
+	req.ID = id
+	return req, nil
+}
+
+// decodeHistoryUser decodifica los parámetros de la solicitud para obtener el historial de cambios de un usuario.
+func decodeHistoryUser(c *gin.Context) (interface{}, error) {
+	// Obtiene el ID del usuario de los parámetros de la URL.
+	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
+	if err != nil {
+		return nil, response.BadRequest(err.Error())
+	}
+
+	return user.HistoryReq{
+		ID: id,
+	}, nil
+}
+
+// decodeAudit decodifica los filtros de la consulta del log de auditoría.
+// Acepta user_id, actor, action, from y to (RFC 3339) y limit.
+func decodeAudit(c *gin.Context) (interface{}, error) {
+	req := user.AuditReq{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+	}
+
+	if req.Action != "" && req.Action != domain.AuditActionCreate && req.Action != domain.AuditActionUpdate && req.Action != domain.AuditActionDelete {
+		return nil, response.BadRequest(fmt.Sprintf("invalid action '%s'", req.Action))
+	}
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.UserID = &id
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.From = &from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.To = &to
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, response.BadRequest(err.Error())
+		}
+		req.Limit = limit
+	}
+
+	return req, nil
+}
+
 /*
 // decodeGetUser decodifica los parámetros de la solicitud para obtener
 	// Obtiene el ID del usuario de los parámetros de la URL.
@@ -160,30 +543,196 @@ func decodeDeleteUser(c *gin.Context) (interface{}, error) {
 }
 */
 
-// tokenVerify verifica si el token proporcionado coincide con el token almacenado en las variables de entorno.
-// Si el token no coincide, devuelve un error.
-func tokenVerify(token string) error {
-	// Compara el token proporcionado con el token almacenado en las variables de entorno.
-	// Si no coinciden, devuelve un error de "token inválido".
-	if os.Getenv("TOKEN") != token {
-		return errors.New("invalid token")
+// authenticator verifica las credenciales del encabezado Authorization: el token compartido o la clave de API de un
+// cliente.
+type authenticator struct {
+	token   string            // Token compartido
+	clients map[string]string // Nombre del cliente de cada clave de API
+}
+
+// newAuthenticator crea un authenticator a partir de la configuración de la autenticación.
+func newAuthenticator(cfg config.Auth) authenticator {
+	return authenticator{token: cfg.Token, clients: cfg.Clients()}
+}
+
+// authenticate indica si las credenciales son válidas y devuelve el nombre del cliente de la clave de API, o una
+// cadena vacía si son el token compartido. Se comparan con todas las claves, sin detenerse en la que coincide, para
+// no revelar cuál es a partir del tiempo de respuesta.
+func (a authenticator) authenticate(authorization string) (string, bool) {
+	ok := validToken(authorization, a.token)
+	client := ""
+	for key, name := range a.clients {
+		if validToken(authorization, key) {
+			client, ok = name, true
+		}
+	}
+	return client, ok
+}
+
+// authenticated devuelve una copia del contexto con el cliente de la clave de API, que pasa a ser el actor de la
+// solicitud salvo que el cliente se haya identificado con un certificado (mTLS).
+func authenticated(ctx context.Context, client string) context.Context {
+	if client == "" {
+		return ctx
+	}
+	ctx = requestctx.WithAPIClient(ctx, client)
+	if requestctx.ClientIdentity(ctx) == "" {
+		ctx = requestctx.WithActor(ctx, requestctx.CleanActor(client))
+	}
+	return ctx
+}
+
+// authorize devuelve un middleware que verifica las credenciales del encabezado Authorization con auth.
+// Si no son válidas, responde 401 sin ejecutar el endpoint.
+func authorize(auth authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := auth.authenticate(c.Request.Header.Get("Authorization"))
+		if !ok {
+			encodeError(c, response.Unauthorized("invalid token"))
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(authenticated(c.Request.Context(), client))
+		c.Next()
+	}
+}
+
+// validToken indica si el token enviado por el cliente coincide con el configurado.
+// La comparación en tiempo constante evita revelar el token a partir del tiempo de respuesta.
+func validToken(got, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// rateLimit devuelve una función que crea el middleware que limita las solicitudes de cada cliente a una ruta.
+// Si los límites están deshabilitados, los middlewares no hacen nada.
+func rateLimit(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger) func(name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(name string, limit ratelimit.Limit) gin.HandlerFunc {
+		if !cfg.Enabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return ratelimit.GinMiddleware(store, name, limit, clientKey, encodeError, l)
+	}
+}
+
+// rateLimitCost devuelve un middleware que limita las solicitudes de cada cliente a una ruta consumiendo la cantidad
+// de tokens que indica cost. Si los límites están deshabilitados, el middleware no hace nada.
+func rateLimitCost(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger, name string, limit ratelimit.Limit, cost ratelimit.CostFunc) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
 	}
+	return ratelimit.GinCostMiddleware(store, name, limit, clientKey, cost, encodeError, l)
+}
+
+// twoFactorBucket es el nombre del balde de los códigos de dos factores de cada usuario, que comparten las rutas HTTP
+// y los métodos de gRPC.
+const twoFactorBucket = "two-factor"
+
+// twoFactorRateLimit devuelve un middleware que limita los códigos de dos factores que se prueban para el usuario de
+// la ruta (parámetro id), consumiendo la cantidad de tokens que indica cost. El balde es del usuario y no del cliente,
+// de modo que cambiar de IP o de clave de API no permite probar más códigos; a cambio, quien supera el límite impide
+// por un tiempo que el usuario verifique sus códigos. Si los límites están deshabilitados, el middleware no hace nada.
+func twoFactorRateLimit(cfg config.RateLimit, store ratelimit.Store, l *slog.Logger, cost ratelimit.CostFunc) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limit := ratelimit.Limit{Requests: cfg.TwoFactorRequests, Period: cfg.TwoFactorPeriod.Std()}
+	// La clave se arma con el ID ya convertido a número, para que "/users/007" y "/users/7" compartan el balde. Si el
+	// ID no es válido, la solicitud no consume tokens: el decodificador de la ruta la rechaza con 400.
+	key := func(c *gin.Context) string {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		return twoFactorKey(id)
+	}
+	validCost := func(c *gin.Context) int {
+		if _, err := strconv.ParseUint(c.Param("id"), 10, 64); err != nil {
+			return 0
+		}
+		return cost(c)
+	}
+	return ratelimit.GinCostMiddleware(store, twoFactorBucket, limit, key, validCost, encodeError, l)
+}
+
+// twoFactorKey devuelve la clave del balde de los códigos de dos factores del usuario.
+func twoFactorKey(id uint64) string {
+	return "user:" + strconv.FormatUint(id, 10)
+}
+
+// clientKey identifica al cliente de una solicitud para los límites de solicitudes y las claves de idempotencia (ver clientKeyOf).
+func clientKey(c *gin.Context) string {
+	return clientKeyOf(c.Request.Context(), c.ClientIP())
+}
 
-	// Si los tokens coinciden, devuelve nil (sin error).
-	return nil
+// clientKeyOf identifica a un cliente: por la identidad de su certificado (mTLS) si se autenticó con uno, por el
+// nombre de su clave de API o, si usa el token compartido o no se autenticó, por su dirección IP. El token compartido
+// no distingue clientes, de modo que no se usa como clave. La IP solo se toma de X-Forwarded-For si la conexión
+// proviene de un proxy de confianza (TRUSTED_PROXIES).
+func clientKeyOf(ctx context.Context, ip string) string {
+	if identity := requestctx.ClientIdentity(ctx); identity != "" {
+		return "client:" + identity
+	}
+	if client := requestctx.APIClient(ctx); client != "" {
+		return "key:" + client
+	}
+	return "ip:" + ip
+}
+
+// idempotencyKey es un middleware que guarda en el contexto la clave del encabezado Idempotency-Key, si se envía.
+// La clave se combina con la ruta y el cliente, para que dos clientes o dos rutas no compartan la misma respuesta.
+func idempotencyKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			encodeError(c, response.BadRequest(fmt.Sprintf("%s can't be longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)))
+			c.Abort()
+			return
+		}
+
+		scoped := c.Request.Method + " " + c.FullPath() + ":" + clientKey(c) + ":" + key
+		c.Request = c.Request.WithContext(requestctx.WithIdempotencyKey(c.Request.Context(), scoped))
+		c.Next()
+	}
+}
+
+// newCORS crea la política CORS a partir de la configuración.
+func newCORS(cfg config.CORS) cors.Policy {
+	return cors.New(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge.Std(),
+		EncodeError:      encodeError,
+	})
 }
 
 // encodeResponse codifica la respuesta en formato JSON.
 func encodeResponse(c *gin.Context, resp interface{}) {
-	// Obtiene la respuesta como una estructura de respuesta genérica.
-	r := resp.(response.Response)
+	// Obtiene la respuesta como una estructura de respuesta genérica; si el endpoint devolvió otro tipo, responde 500.
+	r, ok := resp.(response.Response)
+	if !ok {
+		encodeError(c, fmt.Errorf("unexpected response type %T", resp))
+		return
+	}
 	c.Header("Content-Type", "application/json; charset=utf-8")
 	c.JSON(r.StatusCode(), resp) // Codifica la respuesta como JSON y la envía al cliente.
 }
 
 // encodeError codifica los errores en formato JSON.
+// Los errores de dominio se convierten en la respuesta que tienen registrada en apierror, con su código de estado y
+// su código de error; los que no están registrados se envían como 500. Si la solicitud usa el formato RFC 7807
+// (ver errorFormat), el error se envía como un documento application/problem+json cuyo instance es el ID de la solicitud.
 func encodeError(c *gin.Context, err error) {
+	if format, ok := c.Value(errorFormatKey).(problemFormat); ok {
+		p := apierror.NewProblem(err, requestctx.RequestID(c.Request.Context()), format.typeBaseURL)
+		c.Header("Content-Type", apierror.ProblemContentType)
+		c.JSON(p.Status, p)
+		return
+	}
+
 	c.Header("Content-Type", "application/json; charset=utf-8")
-	resp := err.(response.Response)
+	resp := apierror.New(err)
 	c.JSON(resp.StatusCode(), resp) // Codifica el error como JSON y lo envía al cliente.
 }
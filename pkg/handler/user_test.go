@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/ratelimit"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/gin-gonic/gin"
+)
+
+// TestNewRequestContext verifica que el ID de la solicitud enviado por el cliente solo se use si es válido, y que el
+// encabezado X-Actor no reemplace al actor autenticado.
+func TestNewRequestContext(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           string
+		client       string
+		declared     string
+		keepID       bool
+		wantActor    string
+		wantDeclared string
+	}{
+		{name: "token", id: "abc-123", keepID: true, wantActor: defaultActor},
+		{name: "declared actor does not replace token", id: "abc-123", declared: "alice", keepID: true, wantActor: defaultActor, wantDeclared: "alice"},
+		{name: "certificate wins over declared actor", client: "billing", declared: "alice", wantActor: "billing", wantDeclared: "alice"},
+		{name: "long request id is replaced", id: strings.Repeat("x", 65), wantActor: defaultActor},
+		{name: "request id with line breaks is replaced", id: "abc\r\nSet-Cookie: x", wantActor: defaultActor},
+		{name: "declared actor is truncated", declared: strings.Repeat("a", 150), wantActor: defaultActor, wantDeclared: strings.Repeat("a", requestctx.MaxActorLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, id := newRequestContext(context.Background(), tt.id, tt.client, tt.declared)
+
+			if tt.keepID && id != tt.id {
+				t.Errorf("request id = %q, want %q", id, tt.id)
+			}
+			if !tt.keepID && (id == tt.id || !requestctx.ValidRequestID(id)) {
+				t.Errorf("request id = %q, want a new valid id", id)
+			}
+			if got := requestctx.RequestID(ctx); got != id {
+				t.Errorf("context request id = %q, want %q", got, id)
+			}
+			if got := requestctx.Actor(ctx); got != tt.wantActor {
+				t.Errorf("actor = %q, want %q", got, tt.wantActor)
+			}
+			if got := requestctx.DeclaredActor(ctx); got != tt.wantDeclared {
+				t.Errorf("declared actor = %q, want %q", got, tt.wantDeclared)
+			}
+			if got := requestctx.ClientIdentity(ctx); got != tt.client {
+				t.Errorf("client identity = %q, want %q", got, tt.client)
+			}
+		})
+	}
+}
+
+// TestClientKey verifica que los límites de solicitudes identifiquen a cada cliente por su certificado o por su clave
+// de API, y que la IP solo se tome de X-Forwarded-For si la conexión proviene de un proxy de confianza.
+func TestClientKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := newAuthenticator(config.Auth{Token: "shared", APIKeys: []string{"billing:k1", "crm:k2"}})
+
+	tests := []struct {
+		name          string
+		proxies       []string
+		authorization string
+		remoteAddr    string
+		forwardedFor  string
+		wantStatus    int
+		wantKey       string
+	}{
+		{name: "api key", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK, wantKey: "key:billing"},
+		{name: "other api key", authorization: "k2", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK, wantKey: "key:crm"},
+		{name: "shared token uses the ip", authorization: "shared", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK, wantKey: "ip:10.0.0.1"},
+		{name: "forwarded for from an untrusted peer", authorization: "shared", remoteAddr: "10.0.0.1:1234", forwardedFor: "1.2.3.4", wantStatus: http.StatusOK, wantKey: "ip:10.0.0.1"},
+		{name: "forwarded for from a trusted proxy", proxies: []string{"10.0.0.0/8"}, authorization: "shared", remoteAddr: "10.0.0.1:1234", forwardedFor: "1.2.3.4", wantStatus: http.StatusOK, wantKey: "ip:1.2.3.4"},
+		{name: "invalid token", authorization: "k3", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newEngine(config.Server{TrustedProxies: tt.proxies})
+			var key string
+			r.GET("/", authorize(auth), func(c *gin.Context) {
+				key = clientKey(c)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("Authorization", tt.authorization)
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if key != tt.wantKey {
+				t.Errorf("client key = %q, want %q", key, tt.wantKey)
+			}
+		})
+	}
+}
+
+// TestAuthenticatedActor verifica que el cliente de la clave de API sea el actor, salvo que la solicitud presente un
+// certificado de cliente.
+func TestAuthenticatedActor(t *testing.T) {
+	ctx, _ := newRequestContext(context.Background(), "", "", "")
+	if got := requestctx.Actor(authenticated(ctx, "billing")); got != "billing" {
+		t.Errorf("actor = %q, want %q", got, "billing")
+	}
+
+	ctx, _ = newRequestContext(context.Background(), "", "cert-client", "")
+	ctx = authenticated(ctx, "billing")
+	if got := requestctx.Actor(ctx); got != "cert-client" {
+		t.Errorf("actor = %q, want %q", got, "cert-client")
+	}
+	if got := clientKeyOf(ctx, "10.0.0.1"); got != "client:cert-client" {
+		t.Errorf("client key = %q, want %q", got, "client:cert-client")
+	}
+}
+
+// TestTwoFactorRateLimit verifica que los códigos de dos factores se limiten por usuario: dos clientes distintos
+// comparten el límite de un mismo usuario, cada usuario tiene el suyo y las solicitudes sin código no lo consumen.
+func TestTwoFactorRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.RateLimit{Enabled: true, TwoFactorRequests: 2, TwoFactorPeriod: config.Duration(time.Minute)}
+	store := ratelimit.NewMemoryStore()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auth := newAuthenticator(config.Auth{Token: "shared", APIKeys: []string{"billing:k1", "crm:k2"}})
+
+	r := newEngine(config.Server{})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/users/:id/2fa/verify", authorize(auth), twoFactorRateLimit(cfg, store, l, func(*gin.Context) int { return 1 }), ok)
+	r.PATCH("/users/:id", authorize(auth), twoFactorRateLimit(cfg, store, l, func(c *gin.Context) int {
+		if c.GetHeader(twoFactorCodeHeader) == "" {
+			return 0
+		}
+		return 1
+	}), ok)
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		remoteAddr    string
+		code          string
+		wantStatus    int
+	}{
+		{name: "first client", method: http.MethodPost, path: "/users/1/2fa/verify", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK},
+		{name: "update without code", method: http.MethodPatch, path: "/users/1", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK},
+		{name: "second client", method: http.MethodPost, path: "/users/1/2fa/verify", authorization: "k2", remoteAddr: "10.0.0.2:1234", wantStatus: http.StatusOK},
+		{name: "third ip exceeds the user limit", method: http.MethodPost, path: "/users/1/2fa/verify", authorization: "shared", remoteAddr: "10.0.0.3:1234", wantStatus: http.StatusTooManyRequests},
+		{name: "update with code shares the limit", method: http.MethodPatch, path: "/users/1", authorization: "k2", remoteAddr: "10.0.0.2:1234", code: "123456", wantStatus: http.StatusTooManyRequests},
+		{name: "leading zeros share the limit", method: http.MethodPost, path: "/users/001/2fa/verify", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusTooManyRequests},
+		{name: "invalid id does not consume tokens", method: http.MethodPost, path: "/users/abc/2fa/verify", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK},
+		{name: "other user", method: http.MethodPost, path: "/users/2/2fa/verify", authorization: "k1", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("Authorization", tt.authorization)
+		if tt.code != "" {
+			req.Header.Set(twoFactorCodeHeader, tt.code)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+}
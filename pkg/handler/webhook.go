@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/internal/webhook"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/config"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/metrics"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+	"github.com/gin-gonic/gin"
+)
+
+// NewWebhookHTTPServer configura un servidor HTTP utilizando Gin para los endpoints de suscripciones a webhooks.
+func NewWebhookHTTPServer(cfg *config.Config, endpoints webhook.Endpoints, m metrics.Metrics, l *slog.Logger) http.Handler {
+	r := newEngine(cfg.Server)
+
+	// Guarda en el contexto de cada solicitud su ID, el actor que la realiza y el formato de los errores, la traza, la registra en el log y la mide.
+	// Los pánicos de los manejadores se registran con su stack y responden 500, y se aplica la política CORS a las
+	// solicitudes de los navegadores desde otros orígenes.
+	policy := newCORS(cfg.CORS)
+	r.Use(gin.Recovery(), requestContext(), errorFormat(cfg.Errors), tracing.GinMiddleware(tracerName), requestLog(l), m.GinMiddleware(), transport.GinRecovery(l, encodeError), policy.GinMiddleware())
+
+	// Todos los endpoints requieren el token de autorización.
+	auth := authorize(newAuthenticator(cfg.Auth))
+
+	r.POST("/webhooks", auth, transport.GinServer(
+		transport.Endpoint(endpoints.Create),
+		decodeCreateWebhook,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/webhooks", auth, transport.GinServer(
+		transport.Endpoint(endpoints.GetAll),
+		decodeGetAllWebhook,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/webhooks/:id", auth, transport.GinServer(
+		transport.Endpoint(endpoints.Get),
+		decodeGetWebhook,
+		encodeResponse,
+		encodeError,
+	))
+	r.PATCH("/webhooks/:id", auth, transport.GinServer(
+		transport.Endpoint(endpoints.Update),
+		decodeUpdateWebhook,
+		encodeResponse,
+		encodeError,
+	))
+	r.DELETE("/webhooks/:id", auth, transport.GinServer(
+		transport.Endpoint(endpoints.Delete),
+		decodeDeleteWebhook,
+		encodeResponse,
+		encodeError,
+	))
+	r.GET("/webhooks/:id/deliveries", auth, transport.GinServer(
+		transport.Endpoint(endpoints.Deliveries),
+		decodeWebhookDeliveries,
+		encodeResponse,
+		encodeError,
+	))
+	r.POST("/webhooks/deliveries/:id/retry", auth, transport.GinServer(
+		transport.Endpoint(endpoints.RetryDelivery),
+		decodeRetryWebhookDelivery,
+		encodeResponse,
+		encodeError,
+	))
+
+	// Cada ruta responde a las solicitudes preflight con los métodos que admite.
+	policy.Register(r)
+
+	return r
+}
+
+// decodeCreateWebhook decodifica los datos de la solicitud para crear una suscripción.
+func decodeCreateWebhook(c *gin.Context) (interface{}, error) {
+	var req webhook.CreateReq
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error()))
+	}
+	return req, nil
+}
+
+// decodeGetAllWebhook decodifica la solicitud para obtener todas las suscripciones, que no tiene parámetros.
+func decodeGetAllWebhook(c *gin.Context) (interface{}, error) {
+	return nil, nil
+}
+
+// decodeGetWebhook decodifica los parámetros de la solicitud para obtener el ID de la suscripción.
+func decodeGetWebhook(c *gin.Context) (interface{}, error) {
+	id, err := decodeWebhookID(c)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.GetReq{ID: id}, nil
+}
+
+// decodeUpdateWebhook decodifica los datos de la solicitud para actualizar una suscripción.
+func decodeUpdateWebhook(c *gin.Context) (interface{}, error) {
+	id, err := decodeWebhookID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var req webhook.UpdateReq
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return nil, response.BadRequest(fmt.Sprintf("Invalid request format: '%v'", err.Error()))
+	}
+	req.ID = id
+	return req, nil
+}
+
+// decodeDeleteWebhook decodifica los parámetros de la solicitud para eliminar una suscripción.
+func decodeDeleteWebhook(c *gin.Context) (interface{}, error) {
+	id, err := decodeWebhookID(c)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.DeleteReq{ID: id}, nil
+}
+
+// decodeWebhookDeliveries decodifica los parámetros de la solicitud para obtener el log de entregas de una suscripción.
+func decodeWebhookDeliveries(c *gin.Context) (interface{}, error) {
+	id, err := decodeWebhookID(c)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.DeliveriesReq{ID: id}, nil
+}
+
+// decodeRetryWebhookDelivery decodifica los parámetros de la solicitud para reintentar una entrega.
+func decodeRetryWebhookDelivery(c *gin.Context) (interface{}, error) {
+	id, err := decodeWebhookID(c)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.RetryDeliveryReq{ID: id}, nil
+}
+
+// decodeWebhookID obtiene el ID de los parámetros de la URL.
+func decodeWebhookID(c *gin.Context) (uint64, error) {
+	id, err := strconv.ParseUint(c.Params.ByName("id"), 10, 64)
+	if err != nil {
+		return 0, response.BadRequest(err.Error())
+	}
+	return id, nil
+}
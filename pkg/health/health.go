@@ -0,0 +1,127 @@
+package health
+
+/*
+Package health implementa las verificaciones de liveness y readiness que utiliza el orquestador para sondear el servicio.
+*/
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Estados posibles del servicio y de cada dependencia.
+const (
+	StatusOK           = "ok"            // El servicio o la dependencia funciona correctamente
+	StatusFail         = "fail"          // La dependencia no respondió correctamente
+	StatusShuttingDown = "shutting_down" // El servicio se está deteniendo y no debe recibir tráfico
+)
+
+// Check verifica el estado de una dependencia; devuelve un error si no está disponible.
+type Check func(ctx context.Context) error
+
+// CheckResult es el resultado de la verificación de una dependencia.
+type CheckResult struct {
+	Status     string `json:"status"`          // ok o fail
+	Error      string `json:"error,omitempty"` // Error devuelto por la verificación, si falló
+	DurationMS int64  `json:"duration_ms"`     // Duración de la verificación en milisegundos
+}
+
+// Report es el resultado de la verificación de readiness.
+type Report struct {
+	Status string                 `json:"status"` // ok, fail o shutting_down
+	Checks map[string]CheckResult `json:"checks"` // Resultado de cada dependencia
+}
+
+// Ready indica si el servicio puede recibir tráfico.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Readiness agrupa las verificaciones de las dependencias del servicio.
+type Readiness interface {
+	// Add registra la verificación de una dependencia con el nombre indicado.
+	Add(name string, check Check)
+	// SetShuttingDown marca el servicio como no disponible porque se está deteniendo.
+	SetShuttingDown()
+	// Report ejecuta todas las verificaciones en paralelo y devuelve el resultado.
+	Report(ctx context.Context) Report
+}
+
+// readiness es una implementación de Readiness.
+type readiness struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewReadiness crea un Readiness cuyas verificaciones se cancelan si superan el timeout indicado.
+func NewReadiness(timeout time.Duration) Readiness {
+	return &readiness{
+		checks:  map[string]Check{},
+		timeout: timeout,
+	}
+}
+
+// Add registra la verificación de una dependencia con el nombre indicado.
+func (r *readiness) Add(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// SetShuttingDown marca el servicio como no disponible porque se está deteniendo.
+func (r *readiness) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Report ejecuta todas las verificaciones en paralelo y devuelve el resultado.
+func (r *readiness) Report(ctx context.Context) Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(r.checks)),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, check := range r.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			result := CheckResult{
+				Status:     StatusOK,
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusFail
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	if r.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
@@ -0,0 +1,210 @@
+package idempotency
+
+/*
+Package idempotency permite que los clientes reintenten de forma segura las solicitudes que crean recursos enviando el
+encabezado Idempotency-Key: la primera respuesta de cada clave se guarda junto con una huella de la solicitud y se
+devuelve de nuevo en los reintentos dentro del TTL, sin volver a ejecutar el endpoint.
+*/
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+)
+
+// ErrKeyReused se produce cuando se reutiliza una clave de idempotencia con una solicitud distinta.
+var ErrKeyReused = errors.New("idempotency key was already used with a different request")
+
+func init() {
+	apierror.Register(ErrKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused")
+}
+
+// Record es la respuesta guardada para una clave de idempotencia. De las respuestas exitosas se guarda el cuerpo y de
+// los errores, su estado, código, mensaje y campos, para que el reintento se codifique con el formato de error de la
+// solicitud (envoltura o RFC 7807) y no con el del primer intento.
+type Record struct {
+	Fingerprint string                // Huella de la solicitud que generó la respuesta
+	Status      int                   // Código de estado de la respuesta
+	Body        []byte                // Cuerpo JSON de la respuesta, si el endpoint no devolvió un error
+	Code        string                // Código de error estable, si el endpoint devolvió un error registrado
+	Message     string                // Mensaje del error
+	Fields      []apierror.FieldError // Errores de los campos de la solicitud
+	Failed      bool                  // Si el endpoint devolvió la respuesta como error
+}
+
+// Store guarda las respuestas de las claves de idempotencia y serializa las solicitudes con la misma clave.
+// Para compartir las claves entre varias instancias del servicio, Store debe implementarse sobre un almacenamiento
+// compartido con un bloqueo distribuido.
+type Store interface {
+	// Lock espera a que ninguna otra solicitud esté procesando la clave y la reserva.
+	// Devuelve la función que la libera, o un error si el contexto se cancela antes.
+	Lock(ctx context.Context, key string) (func(), error)
+	// Get devuelve la respuesta guardada para la clave, si existe y no expiró.
+	Get(ctx context.Context, key string) (Record, bool, error)
+	// Save guarda la respuesta de la clave durante ttl.
+	Save(ctx context.Context, key string, record Record, ttl time.Duration) error
+}
+
+// Wrap devuelve un endpoint que, si el contexto contiene una clave de idempotencia (ver requestctx.WithIdempotencyKey),
+// ejecuta next una sola vez por clave y devuelve la misma respuesta en los reintentos durante ttl. Las solicitudes
+// concurrentes con la misma clave se procesan de a una. Si la clave se reutiliza con una solicitud distinta, responde
+// 422. Solo se guardan los resultados definitivos (ver newRecord), para que el cliente pueda reintentar los demás. Sin
+// clave, llama directamente a next.
+//
+// Si la respuesta no se puede guardar, el error se registra en el log y la respuesta se devuelve igual: el endpoint ya
+// se ejecutó (por ejemplo, el usuario ya se creó), por lo que responder con un error haría creer al cliente que falló.
+func Wrap(next transport.Endpoint, store Store, ttl time.Duration, l *slog.Logger) transport.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		key := requestctx.IdempotencyKey(ctx)
+		if key == "" {
+			return next(ctx, request)
+		}
+
+		fingerprint, err := fingerprint(request)
+		if err != nil {
+			return nil, err
+		}
+
+		unlock, err := store.Lock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		record, ok, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if record.Fingerprint != fingerprint {
+				return nil, ErrKeyReused
+			}
+			return record.replay()
+		}
+
+		resp, err := next(ctx, request)
+		if record, ok := newRecord(fingerprint, resp, err); ok {
+			// La respuesta se guarda aunque el cliente haya cancelado la solicitud después de ejecutarse el endpoint.
+			if saveErr := store.Save(context.WithoutCancel(ctx), key, record, ttl); saveErr != nil {
+				l.ErrorContext(ctx, "idempotency record not saved", "status", record.Status, "error", saveErr)
+			}
+		}
+		return resp, err
+	}
+}
+
+// newRecord crea el registro de la respuesta de un endpoint; los errores se guardan con la respuesta que tienen
+// registrada en apierror. Devuelve false si la respuesta no debe guardarse: si no es una response.Response o si no es
+// un resultado definitivo, es decir, si es un error del servidor o un error que el mismo reintento puede no repetir
+// (ver transient).
+func newRecord(fingerprint string, resp interface{}, err error) (Record, bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return Record{}, false
+		}
+		record := Record{Fingerprint: fingerprint, Failed: true}
+		switch e := apierror.New(err).(type) {
+		case *apierror.Error:
+			record.Status, record.Code, record.Message, record.Fields = e.Status, e.Code, e.Message, e.Fields
+		case *response.ErrorResponse:
+			record.Status, record.Message = e.Status, e.Message
+		default:
+			record.Status, record.Message = e.StatusCode(), e.Error()
+		}
+		return record, record.Status < http.StatusInternalServerError && !transient(record.Status)
+	}
+
+	r, ok := resp.(response.Response)
+	if !ok || r.StatusCode() >= http.StatusInternalServerError || transient(r.StatusCode()) {
+		return Record{}, false
+	}
+	body, bodyErr := r.GetBody()
+	if bodyErr != nil {
+		return Record{}, false
+	}
+	return Record{Fingerprint: fingerprint, Status: r.StatusCode(), Body: body}, true
+}
+
+// transient indica si el código de estado corresponde a un error que no depende de la solicitud sino del momento en
+// que se envió: el tiempo de espera vencido (408), el límite de solicitudes superado (429) o la solicitud cancelada por
+// el cliente (499). Guardarlos haría que el reintento recibiera el mismo error durante todo el TTL.
+func transient(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, apierror.StatusClientClosedRequest:
+		return true
+	}
+	return false
+}
+
+// replay devuelve la respuesta guardada del mismo modo que la devolvió el endpoint: como respuesta o como error. Los
+// errores se reconstruyen con el mismo tipo que devuelve apierror.New, para que el encoder de errores los presente
+// igual que en el primer intento con el formato que pida el reintento.
+func (r Record) replay() (interface{}, error) {
+	if !r.Failed {
+		return &replayed{status: r.Status, body: r.Body}, nil
+	}
+	if r.Code == "" && len(r.Fields) == 0 {
+		return nil, &response.ErrorResponse{Status: r.Status, Message: r.Message}
+	}
+	return nil, &apierror.Error{Status: r.Status, Code: r.Code, Message: r.Message, Fields: r.Fields}
+}
+
+// fingerprint devuelve la huella de una solicitud decodificada: el SHA-256 de su representación JSON. Al calcularse
+// sobre la solicitud decodificada, no cambia con el orden de los campos ni los espacios del cuerpo original.
+func fingerprint(request interface{}) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayed es una response.Response que devuelve una respuesta exitosa guardada tal como se envió originalmente.
+type replayed struct {
+	status int
+	body   json.RawMessage
+}
+
+// StatusCode devuelve el código de estado de la respuesta guardada.
+func (r *replayed) StatusCode() int {
+	return r.status
+}
+
+// GetBody devuelve el cuerpo de la respuesta guardada.
+func (r *replayed) GetBody() ([]byte, error) {
+	return r.body, nil
+}
+
+// Error devuelve el mensaje de la respuesta guardada.
+func (r *replayed) Error() string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(r.body, &body)
+	return body.Message
+}
+
+// GetData devuelve los datos de la respuesta guardada.
+func (r *replayed) GetData() interface{} {
+	var body struct {
+		Data interface{} `json:"data"`
+	}
+	_ = json.Unmarshal(r.body, &body)
+	return body.Data
+}
+
+// MarshalJSON devuelve el cuerpo de la respuesta guardada, para que se codifique igual que la respuesta original.
+func (r *replayed) MarshalJSON() ([]byte, error) {
+	return r.body, nil
+}
@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+)
+
+// errTest es un error registrado con un código propio para las pruebas.
+var errTest = errors.New("test conflict")
+
+func init() {
+	apierror.Register(errTest, http.StatusConflict, "test_conflict")
+}
+
+// discard es un logger que descarta los mensajes.
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// TestWrapReplaysErrors verifica que los reintentos de una solicitud que falló devuelvan el mismo error, con su
+// estado, código, mensaje y campos, sin volver a ejecutar el endpoint, de modo que se pueda codificar tanto con la
+// envoltura como con el formato RFC 7807.
+func TestWrapReplaysErrors(t *testing.T) {
+	validation := apierror.ValidationError{Fields: []apierror.FieldError{{Pointer: "/first_name", Code: "max_length", Detail: "too long"}}}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "error registrado", err: errTest},
+		{name: "error de validación", err: validation},
+		{name: "respuesta de error", err: response.BadRequest("invalid request")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			endpoint := Wrap(func(context.Context, interface{}) (interface{}, error) {
+				calls++
+				return nil, tt.err
+			}, NewMemoryStore(), time.Minute, discard)
+			ctx := requestctx.WithIdempotencyKey(context.Background(), "key")
+
+			_, first := endpoint(ctx, "request")
+			_, retry := endpoint(ctx, "request")
+			if calls != 1 {
+				t.Fatalf("endpoint called %d times; want 1", calls)
+			}
+
+			want := apierror.NewProblem(first, "", "")
+			got := apierror.NewProblem(retry, "", "")
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("replayed problem = %+v; want %+v", got, want)
+			}
+
+			wantBody, _ := apierror.New(first).GetBody()
+			gotBody, _ := apierror.New(retry).GetBody()
+			if string(gotBody) != string(wantBody) {
+				t.Fatalf("replayed body = %s; want %s", gotBody, wantBody)
+			}
+		})
+	}
+}
+
+// TestWrapRetriesTransientErrors verifica que los errores que no son definitivos (cancelaciones, tiempos de espera,
+// límites de solicitudes y errores del servidor) no se guarden, de modo que el reintento vuelva a ejecutar el endpoint.
+func TestWrapRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "contexto cancelado", err: context.Canceled},
+		{name: "plazo vencido", err: fmt.Errorf("query: %w", context.DeadlineExceeded)},
+		{name: "tiempo de espera", err: &response.ErrorResponse{Status: http.StatusRequestTimeout, Message: "timeout"}},
+		{name: "límite de solicitudes", err: &response.ErrorResponse{Status: http.StatusTooManyRequests, Message: "slow down"}},
+		{name: "solicitud cancelada", err: &response.ErrorResponse{Status: apierror.StatusClientClosedRequest, Message: "canceled"}},
+		{name: "error del servidor", err: response.InternalServerError("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			endpoint := Wrap(func(context.Context, interface{}) (interface{}, error) {
+				calls++
+				if calls == 1 {
+					return nil, tt.err
+				}
+				return response.Created("created", "user"), nil
+			}, NewMemoryStore(), time.Minute, discard)
+			ctx := requestctx.WithIdempotencyKey(context.Background(), "key")
+
+			_, _ = endpoint(ctx, "request")
+			if _, err := endpoint(ctx, "request"); err != nil || calls != 2 {
+				t.Fatalf("retry = %v after %d calls; want the endpoint to run again", err, calls)
+			}
+		})
+	}
+}
+
+// failingStore es un Store que no puede guardar las respuestas.
+type failingStore struct{ Store }
+
+func (s failingStore) Save(context.Context, string, Record, time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+// TestWrapSaveFailure verifica que, si la respuesta no se puede guardar, se devuelva igual la respuesta del endpoint.
+func TestWrapSaveFailure(t *testing.T) {
+	endpoint := Wrap(func(context.Context, interface{}) (interface{}, error) {
+		return response.Created("created", "user"), nil
+	}, failingStore{NewMemoryStore()}, time.Minute, discard)
+	ctx := requestctx.WithIdempotencyKey(context.Background(), "key")
+
+	resp, err := endpoint(ctx, "request")
+	if err != nil || resp.(response.Response).StatusCode() != http.StatusCreated {
+		t.Fatalf("endpoint = %v, %v; want the created response", resp, err)
+	}
+}
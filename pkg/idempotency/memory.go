@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval es cada cuánto el almacenamiento en memoria elimina las respuestas expiradas.
+const sweepInterval = time.Minute
+
+// keyLock es el bloqueo de una clave; waiters cuenta las solicitudes que lo tienen o lo esperan.
+type keyLock struct {
+	ch      chan struct{}
+	waiters int
+}
+
+// entry es una respuesta guardada junto con su vencimiento.
+type entry struct {
+	record  Record
+	expires time.Time
+}
+
+// memoryStore es una implementación de Store en memoria, válida para una sola instancia del servicio.
+type memoryStore struct {
+	mu        sync.Mutex
+	locks     map[string]*keyLock
+	entries   map[string]entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore crea un Store que guarda las respuestas en memoria.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		locks:   make(map[string]*keyLock),
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Lock espera a que ninguna otra solicitud esté procesando la clave y la reserva.
+func (s *memoryStore) Lock(ctx context.Context, key string) (func(), error) {
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		s.locks[key] = l
+	}
+	l.waiters++
+	s.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			s.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		s.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+// release descuenta una solicitud del bloqueo de la clave y lo elimina si ya nadie lo utiliza.
+func (s *memoryStore) release(key string, l *keyLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l.waiters--
+	if l.waiters == 0 {
+		delete(s.locks, key)
+	}
+}
+
+// Get devuelve la respuesta guardada para la clave, si existe y no expiró.
+func (s *memoryStore) Get(_ context.Context, key string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expires) {
+		return Record{}, false, nil
+	}
+	return e.record, true, nil
+}
+
+// Save guarda la respuesta de la clave durante ttl.
+func (s *memoryStore) Save(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	s.entries[key] = entry{record: record, expires: now.Add(ttl)}
+	return nil
+}
+
+// sweep elimina las respuestas expiradas, como mucho una vez cada sweepInterval.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package logging
+
+/*
+Package logging crea el logger estructurado de la aplicación: escribe en JSON con niveles y agrega a cada línea los datos
+de correlación de la solicitud que viajan en el contexto (ID de la solicitud, actor y traza).
+*/
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/requestctx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New devuelve un logger que escribe en w en formato JSON a partir del nivel indicado.
+// Las llamadas que reciben un contexto (InfoContext, ErrorContext, etc.) agregan los datos de correlación de la solicitud.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(&contextHandler{
+		next: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}),
+	})
+}
+
+// ParseLevel convierte el nombre de un nivel (debug, info, warn o error) en un slog.Level.
+// Un nombre vacío equivale a info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// contextHandler es un slog.Handler que agrega a cada registro los datos de correlación del contexto.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle agrega el ID de la solicitud, el actor, el actor declarado, la identidad del cliente y los IDs de traza y span, si están presentes en el contexto.
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestctx.RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if actor := requestctx.Actor(ctx); actor != "" {
+		r.AddAttrs(slog.String("actor", actor))
+	}
+	if declared := requestctx.DeclaredActor(ctx); declared != "" {
+		r.AddAttrs(slog.String("declared_actor", declared))
+	}
+	if client := requestctx.ClientIdentity(ctx); client != "" {
+		r.AddAttrs(slog.String("client", client))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,166 @@
+package mail
+
+/*
+Package mail define la interfaz para enviar correos electrónicos y sus implementaciones disponibles.
+*/
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHeader se produce cuando el remitente, el destinatario o el asunto contienen saltos de línea, que
+// permitirían agregar encabezados al correo (por ejemplo, Bcc).
+var ErrInvalidHeader = errors.New("mail: header values can't contain line breaks")
+
+// Message representa un correo electrónico a enviar.
+type Message struct {
+	To      string // Dirección del destinatario
+	Subject string // Asunto del correo
+	Body    string // Cuerpo del correo en texto plano
+}
+
+// Mailer define el contrato que debe cumplir cualquier implementación de envío de correos.
+type Mailer interface {
+	// Send envía el mensaje proporcionado.
+	Send(ctx context.Context, msg Message) error
+}
+
+// logMailer es una implementación de Mailer que solo registra los mensajes en el logger.
+// Es útil en desarrollo, cuando no hay un servidor SMTP disponible. El cuerpo no se registra, porque puede contener
+// secretos como el token de verificación del correo.
+type logMailer struct {
+	log *slog.Logger
+}
+
+// NewLogMailer crea un Mailer que escribe los mensajes en el logger en lugar de enviarlos.
+func NewLogMailer(l *slog.Logger) Mailer {
+	return &logMailer{
+		log: l,
+	}
+}
+
+// Send registra el destinatario y el asunto del mensaje en el logger.
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	m.log.InfoContext(ctx, "mail", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// smtpMailer es una implementación de Mailer que envía los mensajes a través de un servidor SMTP.
+type smtpMailer struct {
+	host    string        // Nombre del servidor SMTP, para verificar su certificado
+	addr    string        // Dirección del servidor SMTP (host:puerto)
+	auth    smtp.Auth     // Credenciales del servidor SMTP
+	from    string        // Remitente de los correos
+	timeout time.Duration // Tiempo máximo para entregar cada mensaje
+}
+
+// NewSMTPMailer crea un Mailer que envía los mensajes utilizando el servidor SMTP indicado. Cada envío, desde la
+// conexión hasta la entrega del mensaje, debe terminar dentro de timeout.
+func NewSMTPMailer(host, port, user, password, from string, timeout time.Duration) Mailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &smtpMailer{
+		host:    host,
+		addr:    net.JoinHostPort(host, port),
+		auth:    auth,
+		from:    from,
+		timeout: timeout,
+	}
+}
+
+// Send envía el mensaje a través del servidor SMTP. Rechaza los mensajes cuyos encabezados contienen saltos de línea.
+// El envío se interrumpe al cancelarse el contexto o al superar el tiempo máximo del Mailer, de modo que un servidor
+// que no responde no bloquea al llamador.
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	for _, v := range []string{m.from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return ErrInvalidHeader
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// El plazo de la conexión es el del contexto; si el contexto se cancela antes, el plazo se adelanta para
+	// interrumpir la lectura o la escritura en curso.
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := m.deliver(conn, msg.To, b.String()); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// deliver entrega el mensaje a través de la conexión con el servidor, con los mismos pasos que smtp.SendMail: usa
+// STARTTLS si el servidor lo admite y se autentica si el Mailer tiene credenciales.
+func (m *smtpMailer) deliver(conn net.Conn, to, data string) error {
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("mail: smtp server doesn't support AUTH")
+		}
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
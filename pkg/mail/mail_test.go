@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSMTPMailerTimeout verifica que el envío a un servidor que acepta la conexión pero no responde termine al
+// cumplirse el tiempo máximo del Mailer o al cancelarse el contexto.
+func TestSMTPMailerTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	canceled, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		timeout time.Duration
+		want    error
+	}{
+		{name: "mailer timeout", ctx: context.Background(), timeout: 50 * time.Millisecond, want: context.DeadlineExceeded},
+		{name: "canceled context", ctx: canceled, timeout: time.Minute, want: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewSMTPMailer(host, port, "", "", "from@example.com", tt.timeout)
+			start := time.Now()
+			err := m.Send(tt.ctx, Message{To: "to@example.com", Subject: "Hola", Body: "Hola"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Send() = %v, want %v", err, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Send() took %v", elapsed)
+			}
+		})
+	}
+}
+
+// TestLogMailerOmitsBody verifica que el Mailer de desarrollo registre el destinatario y el asunto, pero no el cuerpo,
+// que puede contener el token de verificación.
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(slog.New(slog.NewTextHandler(&buf, nil)))
+	if err := m.Send(context.Background(), Message{To: "to@example.com", Subject: "Verificá tu correo", Body: "token=secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "to@example.com") || !strings.Contains(out, "Verificá tu correo") || strings.Contains(out, "secret") {
+		t.Errorf("log = %q", out)
+	}
+}
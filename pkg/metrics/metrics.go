@@ -0,0 +1,135 @@
+package metrics
+
+/*
+Package metrics expone las métricas del servicio en formato Prometheus: solicitudes HTTP por ruta y código de estado,
+duración de las consultas de los repositorios y estadísticas del pool de conexiones a la base de datos.
+*/
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute es la etiqueta de ruta de las solicitudes que no coinciden con ninguna ruta registrada.
+// Se utiliza para no generar una serie por cada ruta inexistente.
+const unmatchedRoute = "unmatched"
+
+// otherMethod es la etiqueta de método de las solicitudes con un método HTTP no estándar. El método lo elige el
+// cliente, de modo que sin normalizarlo cada método inventado generaría series nuevas.
+const otherMethod = "OTHER"
+
+// knownMethods son los métodos HTTP estándar, que se usan como etiqueta sin cambios.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// ObserveFunc registra la duración de una llamada al método de un repositorio y si terminó con error.
+type ObserveFunc func(method string, d time.Duration, err error)
+
+// Metrics agrupa las métricas del servicio.
+type Metrics interface {
+	// Handler devuelve el manejador HTTP que expone las métricas en formato Prometheus.
+	Handler() http.Handler
+	// GinMiddleware devuelve un middleware de Gin que mide las solicitudes por método, ruta y código de estado.
+	GinMiddleware() gin.HandlerFunc
+	// ObserveQuery devuelve la función que registra la duración de las consultas del repositorio indicado.
+	ObserveQuery(repository string) ObserveFunc
+	// RegisterDB registra las estadísticas del pool de conexiones a la base de datos.
+	RegisterDB(name string, db *sql.DB) error
+}
+
+// metrics es una implementación de Metrics sobre un registro de Prometheus propio.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	queries  *prometheus.HistogramVec
+}
+
+// New crea las métricas del servicio e incluye las métricas del runtime de Go y del proceso.
+func New() Metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repository_query_duration_seconds",
+			Help:    "Repository call latency by repository, method and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"repository", "method", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.queries,
+	)
+	return m
+}
+
+// Handler devuelve el manejador HTTP que expone las métricas en formato Prometheus.
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// GinMiddleware devuelve un middleware de Gin que mide las solicitudes por método, ruta y código de estado.
+// La ruta es la plantilla registrada (por ejemplo /users/:id) y no la ruta real, para acotar la cantidad de series.
+func (m *metrics) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		if !knownMethods[method] {
+			method = otherMethod
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.requests.WithLabelValues(method, route, status).Inc()
+		m.duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveQuery devuelve la función que registra la duración de las consultas del repositorio indicado.
+func (m *metrics) ObserveQuery(repository string) ObserveFunc {
+	return func(method string, d time.Duration, err error) {
+		outcome := "ok"
+		if err != nil {
+			outcome = "error"
+		}
+		m.queries.WithLabelValues(repository, method, outcome).Observe(d.Seconds())
+	}
+}
+
+// RegisterDB registra las estadísticas del pool de conexiones a la base de datos (conexiones abiertas, en uso, esperas, etc.).
+func (m *metrics) RegisterDB(name string, db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,183 @@
+package migrate
+
+/*
+Package migrate aplica las migraciones versionadas del esquema de la base de datos MySQL. Cada migración es un archivo
+NNNN_nombre.sql, donde NNNN es su versión; las versiones aplicadas se registran en la tabla schema_migrations, de
+modo que cada migración se aplica una sola vez y en orden de versión.
+*/
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lockName es el nombre del bloqueo de MySQL que impide que dos instancias apliquen las migraciones a la vez.
+	lockName = "schema_migrations"
+
+	// lockTimeout es el tiempo máximo, en segundos, de espera del bloqueo.
+	lockTimeout = 60
+)
+
+// Migration es una migración del esquema.
+type Migration struct {
+	Version    int      // Versión de la migración, tomada del prefijo numérico del archivo
+	Name       string   // Nombre del archivo
+	Statements []string // Sentencias SQL de la migración, en orden
+}
+
+// Load lee las migraciones NNNN_nombre.sql de fsys y las devuelve ordenadas por versión. Las sentencias de cada archivo
+// deben terminar con ";" al final de una línea; las líneas que empiezan con "--" son comentarios.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, name := range files {
+		prefix, _, ok := strings.Cut(path.Base(name), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: %s: the file name must start with a positive version, like 0001_name.sql", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrate: %s and %s have the same version", other, name)
+		}
+		seen[version] = name
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		statements := split(string(b))
+		if len(statements) == 0 {
+			return nil, fmt.Errorf("migrate: %s has no statements", name)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Statements: statements})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// split divide el contenido de un archivo en sentencias terminadas con ";" al final de una línea, sin los comentarios.
+func split(content string) []string {
+	var (
+		statements []string
+		current    []string
+	)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";")
+			statements = append(statements, statement)
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return statements
+}
+
+// Up aplica, en orden de versión, las migraciones de fsys que aún no se aplicaron en la base de datos. Todas las
+// sentencias se ejecutan en la misma conexión, de modo que una migración puede cambiar variables de sesión para sus
+// sentencias siguientes, y un bloqueo de MySQL (GET_LOCK) impide que varias instancias migren a la vez.
+//
+// Las migraciones que cambian variables de sesión deben restaurarlas al terminar. Si una migración falla, la conexión
+// se descarta en lugar de devolverse al pool, para que las variables que quedaron cambiadas no afecten a otras
+// consultas.
+//
+// MySQL confirma implícitamente las sentencias DDL, por lo que una migración que falla a mitad de camino no se
+// revierte: se registra solo al terminar, y debe completarse a mano antes de volver a iniciar la aplicación.
+func Up(ctx context.Context, db *sql.DB, fsys fs.FS, l *slog.Logger) (err error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// Con driver.ErrBadConn, database/sql cierra la conexión en lugar de devolverla al pool.
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&locked); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("migrate: another instance is applying the migrations")
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}()
+
+	create := "CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL, name VARCHAR(255) NOT NULL, applied_at DATETIME(6) NOT NULL, PRIMARY KEY (version))"
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		start := time.Now()
+		for i, statement := range m.Statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("migrate: %s: statement %d: %w", m.Name, i+1, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations(version, name, applied_at) VALUES(?,?,?)", m.Version, m.Name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("migrate: %s: %w", m.Name, err)
+		}
+		l.InfoContext(ctx, "migration applied", "version", m.Version, "name", m.Name, "duration_ms", time.Since(start).Milliseconds())
+	}
+	return nil
+}
+
+// appliedVersions devuelve las versiones registradas en la tabla schema_migrations.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/migrations"
+)
+
+func TestSplit(t *testing.T) {
+	content := `-- Comentario
+ALTER TABLE users
+    ADD COLUMN a INT;
+
+-- Otro comentario
+SET SESSION x = 1;
+SELECT 'a;b'`
+	want := []string{"ALTER TABLE users\n    ADD COLUMN a INT", "SET SESSION x = 1", "SELECT 'a;b'"}
+	if got := split(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("split() = %q, want %q", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_b.sql": {Data: []byte("SELECT 2;\n")},
+		"0001_a.sql": {Data: []byte("SELECT 1;\nSELECT 11;\n")},
+		"README.md":  {Data: []byte("no es una migración")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "0001_a.sql", Statements: []string{"SELECT 1", "SELECT 11"}},
+		{Version: 2, Name: "0002_b.sql", Statements: []string{"SELECT 2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version":        {"users.sql": {Data: []byte("SELECT 1;")}},
+		"duplicate version": {"0001_a.sql": {Data: []byte("SELECT 1;")}, "1_b.sql": {Data: []byte("SELECT 1;")}},
+		"empty":             {"0001_a.sql": {Data: []byte("-- nada\n")}},
+	}
+	for name, fsys := range tests {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load() returned no error", name)
+		}
+	}
+}
+
+// TestLoadEmbedded verifica que las migraciones del repositorio se puedan cargar.
+func TestLoadEmbedded(t *testing.T) {
+	if _, err := Load(migrations.FS); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEmbeddedTables verifica que cada migración del repositorio modifique solo tablas que crea .dockers/mysql/init.sql
+// o una migración anterior, de modo que se puedan aplicar sobre una base de datos existente.
+func TestEmbeddedTables(t *testing.T) {
+	create := regexp.MustCompile("(?i)CREATE TABLE (?:IF NOT EXISTS )?`?(\\w+)`?")
+	alter := regexp.MustCompile("(?i)ALTER TABLE `?(\\w+)`?")
+
+	baseline, err := os.ReadFile("../../.dockers/mysql/init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]bool{}
+	for _, m := range create.FindAllStringSubmatch(string(baseline), -1) {
+		tables[m[1]] = true
+	}
+
+	all, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		for _, statement := range m.Statements {
+			if a := alter.FindStringSubmatch(statement); a != nil && !tables[a[1]] {
+				t.Errorf("%s alters table %s before it is created", m.Name, a[1])
+			}
+			if c := create.FindStringSubmatch(statement); c != nil {
+				tables[c[1]] = true
+			}
+		}
+	}
+}
+
+// TestEmbeddedSessionVariables verifica que cada migración del repositorio que cambia una variable de sesión la
+// restaure al valor global antes de terminar, ya que Up aplica todas las migraciones en la misma conexión.
+func TestEmbeddedSessionVariables(t *testing.T) {
+	set := regexp.MustCompile(`(?i)^SET SESSION (\w+)\s*=\s*(\w+)$`)
+
+	all, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		changed := map[string]bool{}
+		for _, statement := range m.Statements {
+			if s := set.FindStringSubmatch(statement); s != nil {
+				changed[s[1]] = !strings.EqualFold(s[2], "DEFAULT")
+			}
+		}
+		for name, pending := range changed {
+			if pending {
+				t.Errorf("%s doesn't reset session variable %s", m.Name, name)
+			}
+		}
+	}
+}
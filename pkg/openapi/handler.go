@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiHTML es la página de la interfaz web de la especificación, que la presenta con Redoc.
+//
+//go:embed ui.html
+var uiHTML string
+
+// uiTemplate es la plantilla de la página de la interfaz web.
+var uiTemplate = template.Must(template.New("ui").Parse(uiHTML))
+
+// redocVersionFile contiene la versión de Redoc que se descarga con go generate y se sirve embebida en el binario, para
+// que la interfaz web no dependa de un CDN ni ejecute un script que puede cambiar sin que cambie el servicio. Es la
+// única fuente de la versión: la directiva go:generate la lee del mismo archivo, así que para cambiarla basta con
+// editar redoc/VERSION y volver a ejecutar go generate.
+//
+//go:embed redoc/VERSION
+var redocVersionFile string
+
+// redocVersion es la versión de Redoc de redoc/VERSION.
+var redocVersion = strings.TrimSpace(redocVersionFile)
+
+//go:generate sh -c "curl -fsSL -o redoc/redoc.standalone.js https://cdn.redoc.ly/redoc/$(cat redoc/VERSION)/bundles/redoc.standalone.js"
+
+// redocFiles contiene el script de Redoc descargado con go generate.
+//
+//go:embed all:redoc
+var redocFiles embed.FS
+
+// redocScript es el nombre del script de Redoc en redocFiles.
+const redocScript = "redoc/redoc.standalone.js"
+
+// JSONHandler devuelve un manejador de Gin que responde el documento en JSON. El documento se codifica una sola vez,
+// al crear el manejador, ya que las rutas no cambian mientras el servicio está en ejecución.
+func JSONHandler(doc *Document) gin.HandlerFunc {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		// Los tipos del documento siempre se pueden codificar; un error indica un error de programación.
+		panic(err)
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+// UIHandler devuelve un manejador de Gin que responde la interfaz web que presenta el documento publicado en specURL,
+// que carga Redoc desde scriptURL (ver ScriptHandler).
+func UIHandler(title, specURL, scriptURL string) gin.HandlerFunc {
+	var page bytes.Buffer
+	if err := uiTemplate.Execute(&page, struct{ Title, SpecURL, ScriptURL string }{title, specURL, scriptURL}); err != nil {
+		panic(err)
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}
+}
+
+// ScriptHandler devuelve un manejador de Gin que responde el script de Redoc embebido en el binario. Como el script
+// solo cambia con el binario, se puede guardar en caché. Si el binario se compiló sin descargar el script con
+// go generate, responde 404.
+func ScriptHandler() gin.HandlerFunc {
+	script, err := redocFiles.ReadFile(redocScript)
+	return func(c *gin.Context) {
+		if err != nil {
+			c.String(http.StatusNotFound, "redoc %s was not embedded; run go generate ./pkg/openapi", redocVersion)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", script)
+	}
+}
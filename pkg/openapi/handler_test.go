@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRedocVersion verifica que redoc/VERSION tenga una versión fija de Redoc, que go generate usa en la URL.
+func TestRedocVersion(t *testing.T) {
+	if !regexp.MustCompile(`^v\d+\.\d+\.\d+$`).MatchString(redocVersion) {
+		t.Errorf("redocVersion = %q, want a pinned version like v2.1.5", redocVersion)
+	}
+}
+
+// TestScriptHandler verifica que se sirva el script embebido o, si no se descargó, un 404 que indica la versión.
+func TestScriptHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/redoc.standalone.js", ScriptHandler())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/redoc.standalone.js", nil))
+
+	if _, err := redocFiles.ReadFile(redocScript); err != nil {
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), redocVersion) {
+			t.Errorf("GET without script = %d %q, want 404 naming %s", w.Code, w.Body.String(), redocVersion)
+		}
+		return
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "" {
+		t.Errorf("GET = %d, Cache-Control %q; want 200 with caching", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,289 @@
+package openapi
+
+/*
+Package openapi genera la especificación OpenAPI 3.1 del servicio a partir de la descripción de sus rutas y de los
+tipos de Go de las solicitudes y las respuestas, y la publica junto con una interfaz web para consultarla. Las
+respuestas se documentan con la envoltura de go-fundamentals-response ({message, status, data}) y los errores, con
+los formatos de apierror: la envoltura con código de error y el documento RFC 7807.
+*/
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+)
+
+// Version es la versión de OpenAPI de los documentos generados.
+const Version = "3.1.0"
+
+// securityScheme es el nombre del esquema de seguridad del token de autorización.
+const securityScheme = "token"
+
+// Info describe la API documentada.
+type Info struct {
+	Title       string `json:"title"`                 // Nombre de la API
+	Version     string `json:"version"`               // Versión de la API
+	Description string `json:"description,omitempty"` // Descripción de la API
+}
+
+// Param describe un parámetro de una ruta.
+type Param struct {
+	Name        string      // Nombre del parámetro
+	In          string      // Ubicación del parámetro: path, query o header
+	Description string      // Descripción del parámetro
+	Required    bool        // Si el parámetro es obligatorio; los parámetros de la ruta siempre lo son
+	Type        interface{} // Valor de ejemplo del tipo del parámetro; si es nil, el parámetro es una cadena
+	Format      string      // Formato del valor, por ejemplo "date-time"
+	Enum        []string    // Valores permitidos
+}
+
+// Route describe una ruta del servicio.
+type Route struct {
+	Method      string   // Método HTTP
+	Path        string   // Ruta con la sintaxis de Gin, por ejemplo /users/:id
+	OperationID string   // Identificador único de la operación
+	Summary     string   // Resumen de la operación
+	Tags        []string // Etiquetas con las que se agrupa la operación
+	Public      bool     // Si la ruta no requiere el token de autorización
+	Params      []Param  // Parámetros de la ruta, de la consulta y de los encabezados
+	// Body es un valor del tipo del cuerpo de la solicitud, por ejemplo user.CreateReq{}; si es nil, la ruta no tiene cuerpo.
+	Body interface{}
+	// BodyOptional indica que el cuerpo de la solicitud puede omitirse.
+	BodyOptional bool
+	// Status es el código de estado de la respuesta exitosa; si es 0, 200.
+	Status int
+	// Data es un valor del tipo del miembro data de la respuesta, por ejemplo domain.User{}; si es nil, data es null.
+	Data interface{}
+	// Errors son los códigos de estado de los errores propios de la ruta. Se agregan siempre 401 a las rutas que
+	// requieren autorización y 429 por los límites de solicitudes; el resto se documenta como respuesta default.
+	Errors []int
+}
+
+// Document es un documento OpenAPI.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Components agrupa los esquemas, las respuestas y los esquemas de seguridad reutilizables del documento.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]Response       `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// Operation es una operación de una ruta del documento.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter es un parámetro de una operación del documento.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody es el cuerpo de la solicitud de una operación del documento.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response es una respuesta de una operación del documento, o una referencia a una respuesta de components.
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType es el esquema de un tipo de contenido.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// SecurityScheme es un esquema de seguridad del documento.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Build genera el documento OpenAPI de las rutas. Los tipos de los cuerpos y de los datos de las respuestas se
+// describen con JSON Schema a partir de sus etiquetas json y se agregan a components/schemas.
+func Build(info Info, routes []Route) *Document {
+	g := newGenerator()
+	errorRef := g.schemaOf(apierror.Error{})
+	problemRef := g.schemaOf(apierror.Problem{})
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			Schemas: g.schemas,
+			Responses: map[string]Response{
+				"Error": {
+					Description: "Error. El formato depende del encabezado Accept o de la configuración del servicio.",
+					Content: map[string]MediaType{
+						"application/json":          {Schema: errorRef},
+						apierror.ProblemContentType: {Schema: problemRef},
+					},
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				securityScheme: {Type: "apiKey", In: "header", Name: "Authorization", Description: "Token compartido del servicio."},
+			},
+		},
+	}
+
+	for _, r := range routes {
+		path, params := pathParams(r)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = operation(g, r, params)
+	}
+	return doc
+}
+
+// operation genera la operación de una ruta.
+func operation(g *generator, r Route, params []Param) Operation {
+	op := Operation{
+		OperationID: r.OperationID,
+		Summary:     r.Summary,
+		Tags:        r.Tags,
+		Responses:   make(map[string]Response),
+	}
+	if !r.Public {
+		op.Security = []map[string][]string{{securityScheme: {}}}
+	}
+
+	for _, p := range params {
+		schema := g.schemaOf(p.Type)
+		if p.Type == nil {
+			schema = &Schema{Type: "string"}
+		}
+		if p.Format != "" {
+			schema.Format = p.Format
+		}
+		for _, v := range p.Enum {
+			schema.Enum = append(schema.Enum, v)
+		}
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Schema:      schema,
+		})
+	}
+
+	if r.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: !r.BodyOptional,
+			Content:  map[string]MediaType{"application/json": {Schema: g.requestOf(r.Body)}},
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = Response{
+		Description: http.StatusText(status),
+		Content:     map[string]MediaType{"application/json": {Schema: envelope(g.schemaOf(r.Data))}},
+	}
+
+	statuses := append([]int{http.StatusTooManyRequests}, r.Errors...)
+	if !r.Public {
+		statuses = append(statuses, http.StatusUnauthorized)
+	}
+	for _, code := range statuses {
+		op.Responses[strconv.Itoa(code)] = Response{Ref: "#/components/responses/Error"}
+	}
+	op.Responses["default"] = Response{Ref: "#/components/responses/Error"}
+	return op
+}
+
+// envelope devuelve el esquema de la envoltura de go-fundamentals-response con los datos indicados.
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"message": {Type: "string"},
+			"status":  {Type: "integer", Format: "int32"},
+			"data":    data,
+		},
+		Required: []string{"message", "status", "data"},
+	}
+}
+
+// pathParams convierte una ruta de Gin a la sintaxis de OpenAPI (/users/:id a /users/{id}) y devuelve los
+// parámetros de la ruta, con los de la ruta de Gin que no se describieron documentados como cadenas.
+func pathParams(r Route) (string, []Param) {
+	declared := make(map[string]bool)
+	for _, p := range r.Params {
+		if p.In == "path" {
+			declared[p.Name] = true
+		}
+	}
+
+	var params []Param
+	for _, s := range strings.Split(r.Path, "/") {
+		if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			continue
+		}
+		if name := s[1:]; !declared[name] {
+			params = append(params, Param{Name: name, In: "path"})
+		}
+	}
+	params = append(params, r.Params...)
+
+	// Los parámetros de la ruta se documentan primero, en el orden en el que aparecen.
+	sort.SliceStable(params, func(i, j int) bool { return params[i].In == "path" && params[j].In != "path" })
+	return openAPIPath(r.Path), params
+}
+
+// openAPIPath convierte una ruta de Gin a la sintaxis de OpenAPI: /users/:id a /users/{id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Routes devuelve las rutas del documento con la sintaxis de Gin, como "GET /users/:id", ordenadas.
+func (d *Document) Routes() []string {
+	var routes []string
+	for path, ops := range d.Paths {
+		segments := strings.Split(path, "/")
+		for i, s := range segments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				segments[i] = ":" + s[1:len(s)-1]
+			}
+		}
+		for method := range ops {
+			routes = append(routes, strings.ToUpper(method)+" "+strings.Join(segments, "/"))
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema es un esquema JSON Schema (draft 2020-12), el dialecto de los esquemas de OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`                 // Referencia a un esquema de components/schemas
+	Type                 interface{}        `json:"type,omitempty"`                 // Tipo, o lista de tipos si admite null
+	Format               string             `json:"format,omitempty"`               // Formato del valor, por ejemplo "date-time"
+	Description          string             `json:"description,omitempty"`          // Descripción del valor
+	Properties           map[string]*Schema `json:"properties,omitempty"`           // Propiedades de un objeto
+	Required             []string           `json:"required,omitempty"`             // Propiedades obligatorias de un objeto
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // Esquema de las claves de un mapa, o false si el objeto no admite otras propiedades
+	Items                *Schema            `json:"items,omitempty"`                // Esquema de los elementos de un arreglo
+	Enum                 []interface{}      `json:"enum,omitempty"`                 // Valores permitidos
+	Minimum              *float64           `json:"minimum,omitempty"`              // Valor mínimo de un número
+	MinLength            *int               `json:"minLength,omitempty"`            // Longitud mínima de una cadena, en caracteres
+	MaxLength            *int               `json:"maxLength,omitempty"`            // Longitud máxima de una cadena, en caracteres
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`                // El valor debe cumplir al menos uno de los esquemas
+}
+
+// timeType es el tipo de time.Time, que se representa como una cadena RFC 3339.
+var timeType = reflect.TypeOf(time.Time{})
+
+// generator genera los esquemas de los tipos de Go y guarda los de las estructuras con nombre en components/schemas.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	closed  bool // Si los objetos generados no admiten propiedades que no estén en el esquema
+}
+
+// newGenerator crea un generador de esquemas vacío.
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf devuelve el esquema del tipo del valor v; si v es nil, el esquema de null.
+func (g *generator) schemaOf(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{Type: "null"}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// requestOf devuelve el esquema del cuerpo de una solicitud del tipo del valor v. A diferencia de los de las
+// respuestas, los objetos de las solicitudes no admiten propiedades adicionales (additionalProperties: false), para
+// que un campo mal escrito se rechace en lugar de ignorarse.
+func (g *generator) requestOf(v interface{}) *Schema {
+	g.closed = true
+	defer func() { g.closed = false }()
+	return g.schemaOf(v)
+}
+
+// schema devuelve el esquema de un tipo de Go con la misma representación que le da encoding/json. Las estructuras
+// con nombre se agregan a components/schemas y se devuelven como referencia; los punteros admiten además null.
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: intFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := 0.0
+		return &Schema{Type: "integer", Format: intFormat(t), Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json codifica []byte como una cadena base64.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+
+	// interface{} y los tipos que no tienen representación propia admiten cualquier valor.
+	return &Schema{}
+}
+
+// component agrega el esquema de una estructura con nombre a components/schemas y devuelve su nombre. Si dos tipos
+// de paquetes distintos tienen el mismo nombre, el segundo se califica con el nombre de su paquete.
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+
+	// El nombre se reserva antes de generar las propiedades, para que los tipos recursivos terminen en una referencia.
+	g.names[t] = name
+	g.schemas[name] = nil
+	g.schemas[name] = g.object(t)
+	return name
+}
+
+// object devuelve el esquema de una estructura. Solo forman parte del esquema los campos exportados con etiqueta
+// json, que son los que el servicio lee y escribe; los campos sin etiqueta, como el ID de las solicitudes que se toma
+// de la URL, no se documentan. La etiqueta openapi agrega restricciones separadas por comas: required, format=<formato>,
+// enum=<valor>|<valor>, minLength=<n> y maxLength=<n>.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if g.closed {
+		s.AdditionalProperties = false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("json")
+		if !f.IsExported() || !ok || tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		for _, opt := range strings.Split(f.Tag.Get("openapi"), ",") {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "required":
+				s.Required = append(s.Required, name)
+			case "format":
+				prop.Format = value
+			case "enum":
+				for _, v := range strings.Split(value, "|") {
+					prop.Enum = append(prop.Enum, v)
+				}
+			case "minLength":
+				prop.MinLength = length(value)
+			case "maxLength":
+				prop.MaxLength = length(value)
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// nullable devuelve un esquema que admite los valores de s y null.
+func nullable(s *Schema) *Schema {
+	if t, ok := s.Type.(string); ok && s.Ref == "" {
+		s.Type = []string{t, "null"}
+		return s
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+}
+
+// intFormat devuelve el formato OpenAPI de un tipo entero: int32 hasta 32 bits e int64 en el resto.
+func intFormat(t reflect.Type) string {
+	if t.Bits() <= 32 {
+		return "int32"
+	}
+	return "int64"
+}
+
+// length convierte el valor de una restricción de longitud de la etiqueta openapi.
+func length(value string) *int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		// Las etiquetas son constantes del código; un valor inválido es un error de programación.
+		panic("openapi: invalid length " + strconv.Quote(value))
+	}
+	return &n
+}
@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// Códigos de error de los campos que no cumplen el esquema.
+const (
+	CodeInvalidJSON  = "invalid_json"  // El cuerpo no es un documento JSON válido
+	CodeRequired     = "required"      // Falta un campo obligatorio o el cuerpo
+	CodeUnknownField = "unknown_field" // El campo no está en el esquema
+	CodeInvalidType  = "invalid_type"  // El valor no es del tipo del esquema
+	CodeTooShort     = "too_short"     // La cadena tiene menos caracteres que minLength
+	CodeTooLong      = "too_long"      // La cadena tiene más caracteres que maxLength
+	CodeTooSmall     = "too_small"     // El número es menor que minimum
+	CodeInvalidValue = "invalid_value" // El valor no es uno de los de enum
+)
+
+// ErrBodyTooLarge se produce cuando el cuerpo de la solicitud supera el tamaño máximo.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+func init() {
+	apierror.Register(ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "request_body_too_large")
+}
+
+// GinValidator devuelve un middleware de Gin que valida el cuerpo de la solicitud contra el esquema que el documento
+// publica para la ruta (el método y la plantilla de la ruta de Gin) antes de que lo decodifique el manejador. Si no
+// cumple el esquema, responde 400 con un apierror.ValidationError que indica cada campo con un JSON Pointer, codificado
+// con encodeError. Las rutas sin cuerpo en el documento no se validan.
+// El cuerpo se lee completo en memoria, por lo que se limita a maxBytes bytes: si es más grande, se deja de leer y se
+// responde 413 con ErrBodyTooLarge.
+func GinValidator(doc *Document, maxBytes int64, encodeError func(c *gin.Context, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				err = ErrBodyTooLarge
+			}
+			encodeError(c, err)
+			c.Abort()
+			return
+		}
+		// El manejador vuelve a leer el cuerpo completo.
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := doc.ValidateBody(c.Request.Method, c.FullPath(), body); err != nil {
+			encodeError(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ValidateBody valida el cuerpo de una solicitud a la ruta indicada (con la sintaxis de Gin) contra el esquema de su
+// requestBody. Devuelve un apierror.ValidationError con todos los campos que no cumplen el esquema, o nil si lo cumple
+// o si la ruta no tiene cuerpo. Se validan type, properties, required, additionalProperties, items, enum, minimum,
+// minLength y maxLength; format es solo una anotación, como establece JSON Schema de forma predeterminada.
+func (d *Document) ValidateBody(method, path string, body []byte) error {
+	op, ok := d.Paths[openAPIPath(path)][strings.ToLower(method)]
+	if !ok || op.RequestBody == nil {
+		return nil
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if !op.RequestBody.Required {
+			return nil
+		}
+		return apierror.ValidationError{Fields: []apierror.FieldError{{Pointer: "", Code: CodeRequired, Detail: "request body is required"}}}
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		return apierror.ValidationError{Fields: []apierror.FieldError{{Pointer: "", Code: CodeInvalidJSON, Detail: fmt.Sprintf("invalid JSON: %v", err)}}}
+	}
+
+	v := &validator{doc: d}
+	v.validate(op.RequestBody.Content["application/json"].Schema, value, "")
+	if len(v.errs) > 0 {
+		return apierror.ValidationError{Fields: v.errs}
+	}
+	return nil
+}
+
+// decode decodifica un documento JSON conservando los números como json.Number, para distinguir los enteros.
+// Devuelve un error si después del documento hay más datos.
+func decode(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON document")
+	}
+	return value, nil
+}
+
+// validator valida un valor contra un esquema del documento y acumula los errores de sus campos.
+type validator struct {
+	doc  *Document
+	errs []apierror.FieldError
+}
+
+// fail agrega el error de un campo.
+func (v *validator) fail(pointer, code, format string, args ...interface{}) {
+	v.errs = append(v.errs, apierror.FieldError{Pointer: pointer, Code: code, Detail: fmt.Sprintf(format, args...)})
+}
+
+// validate valida el valor ubicado en pointer contra el esquema s.
+func (v *validator) validate(s *Schema, value interface{}, pointer string) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		v.anyOf(s.AnyOf, value, pointer)
+		return
+	}
+
+	if types := schemaTypes(s.Type); len(types) > 0 && !matchesAny(types, value) {
+		v.fail(pointer, CodeInvalidType, "%s must be %s", field(pointer), strings.Join(types, " or "))
+		return
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.object(s, val, pointer)
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, item, pointer+"/"+strconv.Itoa(i))
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			v.fail(pointer, CodeTooShort, "%s must be at least %d characters long", field(pointer), *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			v.fail(pointer, CodeTooLong, "%s can't be longer than %d characters", field(pointer), *s.MaxLength)
+		}
+	case json.Number:
+		if f, err := val.Float64(); err == nil && s.Minimum != nil && f < *s.Minimum {
+			v.fail(pointer, CodeTooSmall, "%s must be greater than or equal to %v", field(pointer), *s.Minimum)
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		v.fail(pointer, CodeInvalidValue, "%s must be one of %v", field(pointer), s.Enum)
+	}
+}
+
+// object valida las propiedades de un objeto: las obligatorias, las del esquema y las adicionales. Las propiedades
+// se recorren ordenadas, para que los errores se informen siempre en el mismo orden.
+func (v *validator) object(s *Schema, obj map[string]interface{}, pointer string) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			v.fail(pointer+"/"+escape(name), CodeRequired, "%s is required", name)
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := pointer + "/" + escape(name)
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(prop, obj[name], p)
+			continue
+		}
+		switch additional := s.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				v.fail(p, CodeUnknownField, "unknown field '%s'", name)
+			}
+		case *Schema:
+			v.validate(additional, obj[name], p)
+		}
+	}
+}
+
+// anyOf valida que el valor cumpla al menos uno de los esquemas. Si no cumple ninguno, informa los errores del primero.
+func (v *validator) anyOf(schemas []*Schema, value interface{}, pointer string) {
+	var first []apierror.FieldError
+	for i, s := range schemas {
+		sub := &validator{doc: v.doc}
+		sub.validate(s, value, pointer)
+		if len(sub.errs) == 0 {
+			return
+		}
+		if i == 0 {
+			first = sub.errs
+		}
+	}
+	v.errs = append(v.errs, first...)
+}
+
+// resolve devuelve el esquema de components/schemas al que hace referencia s, o s si no es una referencia.
+func (v *validator) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = v.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// schemaTypes devuelve los tipos de un esquema, que pueden indicarse como un tipo o como una lista de tipos.
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// matchesAny indica si el valor es de alguno de los tipos de JSON Schema indicados.
+func matchesAny(types []string, value interface{}) bool {
+	for _, t := range types {
+		if matches(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches indica si el valor es del tipo de JSON Schema indicado. Un número es entero si no tiene parte decimal.
+func matches(t string, value interface{}) bool {
+	switch val := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		if t != "integer" {
+			return false
+		}
+		if _, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return true
+		}
+		if _, err := strconv.ParseUint(string(val), 10, 64); err == nil {
+			return true
+		}
+		f, err := val.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+// inEnum indica si el valor es uno de los valores permitidos.
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+// escape escapa el nombre de una propiedad para usarlo en un JSON Pointer (RFC 6901).
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// field devuelve el nombre del campo ubicado en pointer para los mensajes de error.
+func field(pointer string) string {
+	if pointer == "" {
+		return "request body"
+	}
+	return strings.TrimPrefix(pointer, "/")
+}
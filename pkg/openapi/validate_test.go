@@ -0,0 +1,149 @@
+package openapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// testAddress y testReq son los cuerpos de la ruta de prueba.
+type testAddress struct {
+	City string `json:"city" openapi:"maxLength=5"`
+}
+
+type testReq struct {
+	Name    *string      `json:"name" openapi:"required,minLength=1,maxLength=5"`
+	Age     *int         `json:"age"`
+	Role    string       `json:"role" openapi:"enum=admin|user"`
+	Tags    []string     `json:"tags"`
+	Address *testAddress `json:"address"`
+}
+
+// testDoc devuelve un documento con la ruta PATCH /things/:id, cuyo cuerpo es un testReq.
+func testDoc() *Document {
+	return Build(Info{Title: "test", Version: "1"}, []Route{
+		{Method: http.MethodPatch, Path: "/things/:id", OperationID: "updateThing", Body: testReq{}},
+		{Method: http.MethodGet, Path: "/things/:id", OperationID: "getThing"},
+	})
+}
+
+// TestValidateBody verifica los errores de cada campo que no cumple el esquema y sus JSON Pointers.
+func TestValidateBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []apierror.FieldError
+	}{
+		{name: "valid", body: `{"name": "Ana", "age": 30, "role": "admin", "tags": ["a"], "address": {"city": "Lima"}}`},
+		{name: "missing body", body: ``, want: []apierror.FieldError{{Pointer: "", Code: CodeRequired}}},
+		{name: "invalid json", body: `{"name": `, want: []apierror.FieldError{{Pointer: "", Code: CodeInvalidJSON}}},
+		{name: "trailing data", body: `{"name": "Ana"} {}`, want: []apierror.FieldError{{Pointer: "", Code: CodeInvalidJSON}}},
+		{name: "required", body: `{}`, want: []apierror.FieldError{{Pointer: "/name", Code: CodeRequired}}},
+		{
+			name: "unknown fields",
+			body: `{"name": "Ana", "nmae": "x", "a/b~c": 1, "address": {"town": "x"}}`,
+			want: []apierror.FieldError{
+				{Pointer: "/a~1b~0c", Code: CodeUnknownField},
+				{Pointer: "/address/town", Code: CodeUnknownField},
+				{Pointer: "/nmae", Code: CodeUnknownField},
+			},
+		},
+		{
+			name: "wrong types",
+			body: `{"name": 1, "age": 1.5, "tags": ["a", 2], "address": "x"}`,
+			want: []apierror.FieldError{
+				{Pointer: "/address", Code: CodeInvalidType},
+				{Pointer: "/age", Code: CodeInvalidType},
+				{Pointer: "/name", Code: CodeInvalidType},
+				{Pointer: "/tags/1", Code: CodeInvalidType},
+			},
+		},
+		{name: "null pointer field", body: `{"name": "Ana", "age": null}`},
+		{name: "integer with zero decimals", body: `{"name": "Ana", "age": 30.0}`},
+		{
+			name: "max length",
+			body: `{"name": "Ñandúes", "address": {"city": "Buenos Aires"}}`,
+			want: []apierror.FieldError{
+				{Pointer: "/address/city", Code: CodeTooLong},
+				{Pointer: "/name", Code: CodeTooLong},
+			},
+		},
+		{name: "max length counts characters", body: `{"name": "Ñandú"}`},
+		{name: "min length", body: `{"name": ""}`, want: []apierror.FieldError{{Pointer: "/name", Code: CodeTooShort}}},
+		{name: "enum", body: `{"name": "Ana", "role": "root"}`, want: []apierror.FieldError{{Pointer: "/role", Code: CodeInvalidValue}}},
+	}
+
+	doc := testDoc()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateBody(http.MethodPatch, "/things/:id", []byte(tt.body))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateBody() = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr apierror.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateBody() = %v, want a ValidationError", err)
+			}
+			got := make([]apierror.FieldError, len(verr.Fields))
+			for i, f := range verr.Fields {
+				got[i] = apierror.FieldError{Pointer: f.Pointer, Code: f.Code}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateBody() fields = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if err := doc.ValidateBody(http.MethodGet, "/things/:id", []byte(`{"x": 1}`)); err != nil {
+		t.Errorf("ValidateBody() of a route without body = %v, want nil", err)
+	}
+}
+
+// TestGinValidator verifica que el middleware deje pasar los cuerpos válidos sin consumirlos, rechace con 400 los que
+// no cumplen el esquema y con 413 los que superan el tamaño máximo.
+func TestGinValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	encodeError := func(c *gin.Context, err error) {
+		m, _ := apierror.Lookup(err)
+		c.String(m.Status, m.Code)
+	}
+	r.PATCH("/things/:id", GinValidator(testDoc(), 64, encodeError), func(c *gin.Context) {
+		var req testReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, *req.Name)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "valid", body: `{"name": "Ana"}`, status: http.StatusOK, want: "Ana"},
+		{name: "invalid", body: `{"name": "Ana", "nmae": "x"}`, status: http.StatusBadRequest, want: "validation_failed"},
+		{name: "too large", body: `{"name": "Ana", "tags": ["` + strings.Repeat("a", 64) + `"]}`, status: http.StatusRequestEntityTooLarge, want: "request_body_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/things/1", strings.NewReader(tt.body)))
+			if w.Code != tt.status || w.Body.String() != tt.want {
+				t.Errorf("response = %d %q, want %d %q", w.Code, w.Body.String(), tt.status, tt.want)
+			}
+		})
+	}
+}
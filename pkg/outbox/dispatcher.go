@@ -0,0 +1,137 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	batchSize   = 100             // Cantidad máxima de eventos que se leen en cada pasada
+	maxAttempts = 20              // Cantidad de intentos antes de pasar el evento a dead letter
+	minBackoff  = time.Second     // Espera antes del primer reintento
+	maxBackoff  = 5 * time.Minute // Espera máxima entre reintentos
+)
+
+// Dispatcher entrega en segundo plano los eventos pendientes del outbox a los sinks configurados.
+type Dispatcher interface {
+	// Run entrega los eventos pendientes cada interval hasta que se cancele el contexto.
+	Run(ctx context.Context)
+	// Dispatch realiza una única pasada de entrega de los eventos pendientes.
+	Dispatch(ctx context.Context) error
+}
+
+// dispatcher es una implementación de Dispatcher.
+type dispatcher struct {
+	store    Store
+	sinks    []Sink
+	log      *slog.Logger
+	interval time.Duration
+}
+
+// NewDispatcher crea un Dispatcher que lee los eventos del store y los entrega a todos los sinks.
+// Se puede ejecutar un Dispatcher en cada instancia: cada pasada reserva la entrega con Store.Lock, de modo que las
+// pasadas de distintas instancias no se superponen y cada clave conserva el orden de entrega.
+func NewDispatcher(store Store, sinks []Sink, l *slog.Logger, interval time.Duration) Dispatcher {
+	return &dispatcher{
+		store:    store,
+		sinks:    sinks,
+		log:      l,
+		interval: interval,
+	}
+}
+
+// Run entrega los eventos pendientes cada interval hasta que se cancele el contexto.
+func (d *dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			d.log.ErrorContext(ctx, "outbox dispatch failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch realiza una única pasada de entrega de los eventos pendientes.
+// Si la entrega de un evento falla, los eventos posteriores con la misma clave no se entregan hasta que se reintente con éxito,
+// de modo que cada clave conserva el orden en que se generaron sus eventos. Después de maxAttempts intentos fallidos el
+// evento pasa a dead letter, para que un evento que no se puede entregar no detenga a su clave indefinidamente.
+// Si otro dispatcher está realizando su pasada, no entrega nada.
+func (d *dispatcher) Dispatch(ctx context.Context) error {
+	unlock, ok, err := d.store.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	defer unlock()
+
+	events, err := d.store.Pending(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	blocked := map[string]bool{}
+	for _, e := range events {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if blocked[e.Key] {
+			continue
+		}
+
+		if err := d.publish(ctx, e); err != nil {
+			blocked[e.Key] = true
+			if e.Attempts+1 >= maxAttempts {
+				d.log.ErrorContext(ctx, "outbox event dead", "event_id", e.ID, "event_type", e.Type, "attempts", e.Attempts+1, "error", err)
+				if err := d.store.MarkDead(ctx, e.ID, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			nextAt := time.Now().Add(backoff(e.Attempts))
+			d.log.WarnContext(ctx, "outbox event delivery failed", "event_id", e.ID, "event_type", e.Type, "next_attempt_at", nextAt, "error", err)
+			if err := d.store.MarkFailed(ctx, e.ID, nextAt, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.store.MarkDispatched(ctx, e.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish entrega el evento a todos los sinks y devuelve el primer error.
+// Si un sink falla, en el reintento el evento se vuelve a entregar a todos los sinks.
+func (d *dispatcher) publish(ctx context.Context, e Event) error {
+	for _, s := range d.sinks {
+		if err := s.Publish(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff devuelve la espera antes del siguiente reintento, que se duplica en cada intento fallido hasta maxBackoff.
+func backoff(attempts int) time.Duration {
+	wait := minBackoff
+	for i := 0; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
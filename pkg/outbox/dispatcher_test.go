@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryStore es un Store en memoria que registra cómo el dispatcher marca cada evento.
+type memoryStore struct {
+	locked     bool
+	events     []Event
+	now        time.Time
+	dispatched []uint64
+	failed     []uint64
+	dead       []uint64
+}
+
+func (s *memoryStore) Pending(_ context.Context, now time.Time, limit int) ([]Event, error) {
+	s.now = now
+	if len(s.events) > limit {
+		return s.events[:limit], nil
+	}
+	return s.events, nil
+}
+
+func (s *memoryStore) MarkDispatched(_ context.Context, id uint64) error {
+	s.dispatched = append(s.dispatched, id)
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(_ context.Context, id uint64, _ time.Time, _ string) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+
+func (s *memoryStore) MarkDead(_ context.Context, id uint64, _ string) error {
+	s.dead = append(s.dead, id)
+	return nil
+}
+
+func (s *memoryStore) Lock(context.Context) (func(), bool, error) {
+	if s.locked {
+		return nil, false, nil
+	}
+	s.locked = true
+	return func() { s.locked = false }, true, nil
+}
+
+// failingSink falla con los eventos indicados.
+type failingSink map[uint64]bool
+
+func (s failingSink) Publish(_ context.Context, e Event) error {
+	if s[e.ID] {
+		return errors.New("sink unavailable")
+	}
+	return nil
+}
+
+// TestDispatch verifica que un evento que falla demore a los posteriores de su clave pero no a los demás, y que pase
+// a dead letter al agotar sus intentos.
+func TestDispatch(t *testing.T) {
+	store := &memoryStore{events: []Event{
+		{ID: 1, Key: "a"},
+		{ID: 2, Key: "b", Attempts: maxAttempts - 1},
+		{ID: 3, Key: "a"},
+		{ID: 4, Key: "c"},
+		{ID: 5, Key: "b"},
+	}}
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := NewDispatcher(store, []Sink{failingSink{1: true, 2: true}}, l, time.Second)
+
+	before := time.Now()
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.now.Before(before) || store.now.After(time.Now()) {
+		t.Errorf("Pending() now = %v, want the current time", store.now)
+	}
+	if want := []uint64{4}; !reflect.DeepEqual(store.dispatched, want) {
+		t.Errorf("dispatched = %v, want %v", store.dispatched, want)
+	}
+	if want := []uint64{1}; !reflect.DeepEqual(store.failed, want) {
+		t.Errorf("failed = %v, want %v", store.failed, want)
+	}
+	if want := []uint64{2}; !reflect.DeepEqual(store.dead, want) {
+		t.Errorf("dead = %v, want %v", store.dead, want)
+	}
+}
+
+// TestDispatchLocked verifica que, mientras otro dispatcher tiene reservada la entrega, la pasada no entregue nada,
+// y que al terminar una pasada se libere la reserva.
+func TestDispatchLocked(t *testing.T) {
+	store := &memoryStore{locked: true, events: []Event{{ID: 1, Key: "a"}}}
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := NewDispatcher(store, []Sink{failingSink{}}, l, time.Second)
+
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.dispatched) != 0 {
+		t.Fatalf("dispatched = %v while locked, want none", store.dispatched)
+	}
+
+	store.locked = false
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(store.dispatched, []uint64{1}) || store.locked {
+		t.Errorf("dispatched = %v, locked = %v; want [1], false", store.dispatched, store.locked)
+	}
+}
+
+// TestDispatchSinks verifica que el dispatcher entregue los eventos al canal, al archivo y al webhook configurados.
+func TestDispatchSinks(t *testing.T) {
+	ch := make(chan Event, 2)
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{events: []Event{
+		{ID: 1, Type: "user.created", Key: "a", Payload: json.RawMessage(`{"id":1}`)},
+		{ID: 2, Type: "user.updated", Key: "a", Payload: json.RawMessage(`{"id":1}`)},
+	}}
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sinks := []Sink{NewChannelSink(ch), file, NewWebhookSink(srv.URL, time.Second)}
+	d := NewDispatcher(store, sinks, l, time.Second)
+
+	if err := d.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{1, 2}; !reflect.DeepEqual(store.dispatched, want) {
+		t.Fatalf("dispatched = %v, want %v", store.dispatched, want)
+	}
+
+	close(ch)
+	var fromChannel []uint64
+	for e := range ch {
+		fromChannel = append(fromChannel, e.ID)
+	}
+	if want := []uint64{1, 2}; !reflect.DeepEqual(fromChannel, want) {
+		t.Errorf("channel sink received %v, want %v", fromChannel, want)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("file sink wrote %d lines, want 2", len(lines))
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 2 || e.Type != "user.updated" {
+		t.Errorf("file sink line 2 = %+v, want event 2 of type user.updated", e)
+	}
+
+	if len(received) != 2 || received[0].ID != 1 || received[1].ID != 2 {
+		t.Errorf("webhook sink received %+v, want events 1 and 2", received)
+	}
+}
+
+// TestChannelSinkCanceled verifica que el sink del canal deje de esperar cuando se cancela el contexto.
+func TestChannelSinkCanceled(t *testing.T) {
+	sink := NewChannelSink(make(chan Event))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := sink.Publish(ctx, Event{ID: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Publish() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestWebhookSinkStatus verifica que una respuesta distinta de 2xx sea un error para que la entrega se reintente.
+func TestWebhookSinkStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookSink(srv.URL, time.Second).Publish(context.Background(), Event{ID: 1}); err == nil {
+		t.Error("Publish() error = nil, want an error for a 503 response")
+	}
+}
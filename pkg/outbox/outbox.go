@@ -0,0 +1,47 @@
+package outbox
+
+/*
+Package outbox implementa el patrón transactional outbox: los eventos se guardan en la base de datos en la misma
+transacción que el cambio que los origina y un proceso en segundo plano los entrega a los destinos (sinks) configurados.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event representa un evento guardado en el outbox.
+type Event struct {
+	ID        uint64          `json:"id"`         // Identificador único y creciente del evento
+	Type      string          `json:"type"`       // Tipo del evento, por ejemplo user.created
+	Key       string          `json:"key"`        // Clave de ordenamiento: los eventos con la misma clave se entregan en orden
+	Payload   json.RawMessage `json:"payload"`    // Contenido del evento en JSON
+	CreatedAt time.Time       `json:"created_at"` // Momento en el que se generó el evento
+	Attempts  int             `json:"-"`          // Cantidad de intentos de entrega fallidos
+	NextAt    time.Time       `json:"-"`          // Momento a partir del cual se puede reintentar la entrega
+}
+
+// Sink define un destino al que se entregan los eventos.
+// La entrega es al menos una vez, por lo que un Sink puede recibir el mismo evento más de una vez.
+type Sink interface {
+	// Publish entrega el evento; si devuelve un error la entrega se reintenta más tarde.
+	Publish(ctx context.Context, e Event) error
+}
+
+// Store define las operaciones que el dispatcher necesita sobre los eventos guardados.
+type Store interface {
+	// Pending devuelve hasta limit eventos pendientes de entrega, ordenados por ID, que se pueden entregar en now: su
+	// próximo reintento ya venció y ningún evento anterior con la misma clave está esperando el suyo.
+	Pending(ctx context.Context, now time.Time, limit int) ([]Event, error)
+	// MarkDispatched marca el evento como entregado.
+	MarkDispatched(ctx context.Context, id uint64) error
+	// MarkFailed registra un intento de entrega fallido y el momento a partir del cual se puede reintentar.
+	MarkFailed(ctx context.Context, id uint64, nextAt time.Time, reason string) error
+	// MarkDead registra el último intento de entrega fallido y pasa el evento a dead letter: no se vuelve a intentar
+	// entregar y deja de demorar a los eventos posteriores con la misma clave.
+	MarkDead(ctx context.Context, id uint64, reason string) error
+	// Lock reserva la entrega de los eventos para el llamador, sin esperar: devuelve false si otro dispatcher (por
+	// ejemplo, el de otra instancia) la tiene reservada. Si la reserva, devuelve la función que la libera.
+	Lock(ctx context.Context) (func(), bool, error)
+}
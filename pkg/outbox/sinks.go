@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// channelSink entrega los eventos a un canal para que los consuma otro componente del mismo proceso.
+type channelSink struct {
+	ch chan<- Event
+}
+
+// NewChannelSink crea un Sink que envía los eventos al canal proporcionado.
+// Si el canal está lleno, Publish espera hasta que haya lugar o se cancele el contexto.
+func NewChannelSink(ch chan<- Event) Sink {
+	return &channelSink{
+		ch: ch,
+	}
+}
+
+// Publish envía el evento al canal.
+func (s *channelSink) Publish(ctx context.Context, e Event) error {
+	select {
+	case s.ch <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fileSink escribe los eventos en un archivo, uno por línea en formato JSON.
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink crea un Sink que agrega los eventos al final del archivo indicado, creándolo si no existe.
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{
+		file: f,
+	}, nil
+}
+
+// Publish escribe el evento en el archivo y espera a que se persista en disco.
+func (s *fileSink) Publish(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// webhookSink entrega los eventos mediante una solicitud HTTP POST.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink crea un Sink que envía cada evento en formato JSON a la URL indicada.
+// Cualquier respuesta distinta de 2xx se considera un error y la entrega se reintenta.
+func NewWebhookSink(url string, timeout time.Duration) Sink {
+	return &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Publish envía el evento a la URL del webhook.
+func (s *webhookSink) Publish(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
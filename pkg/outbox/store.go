@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// lockName es el nombre del bloqueo de MySQL (GET_LOCK) que reserva la entrega de los eventos para un único
+// dispatcher entre todas las instancias que comparten la base de datos.
+const lockName = "outbox_events"
+
+// sqlStore es una implementación de Store sobre la tabla outbox_events.
+type sqlStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore crea un Store que lee los eventos de la tabla outbox_events.
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db: db,
+	}
+}
+
+// Pending devuelve hasta limit eventos pendientes de entrega, ordenados por ID, que se pueden entregar en now.
+// Los eventos que esperan su próximo reintento se excluyen, junto con los posteriores de su misma clave para
+// conservar el orden, de modo que no ocupan el lote y los demás eventos se siguen entregando. Los eventos en dead
+// letter no se devuelven.
+func (s *sqlStore) Pending(ctx context.Context, now time.Time, limit int) ([]Event, error) {
+	sqlQ := `SELECT e.id, e.event_type, e.event_key, e.payload, e.created_at, e.attempts, e.next_attempt_at FROM outbox_events e
+		WHERE e.dispatched_at IS NULL AND e.dead_at IS NULL AND (e.next_attempt_at IS NULL OR e.next_attempt_at <= ?)
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox_events w
+			WHERE w.event_key = e.event_key AND w.id < e.id AND w.dispatched_at IS NULL AND w.dead_at IS NULL AND w.next_attempt_at > ?
+		)
+		ORDER BY e.id LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, sqlQ, now.UTC(), now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var (
+			e      Event
+			nextAt sql.NullTime
+		)
+		if err := rows.Scan(&e.ID, &e.Type, &e.Key, &e.Payload, &e.CreatedAt, &e.Attempts, &nextAt); err != nil {
+			return nil, err
+		}
+		e.NextAt = nextAt.Time
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkDispatched marca el evento como entregado.
+func (s *sqlStore) MarkDispatched(ctx context.Context, id uint64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox_events SET dispatched_at=? WHERE id=?", time.Now().UTC(), id)
+	return err
+}
+
+// MarkFailed registra un intento de entrega fallido y el momento a partir del cual se puede reintentar.
+func (s *sqlStore) MarkFailed(ctx context.Context, id uint64, nextAt time.Time, reason string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox_events SET attempts=attempts+1, next_attempt_at=?, last_error=? WHERE id=?", nextAt.UTC(), reason, id)
+	return err
+}
+
+// MarkDead registra el último intento de entrega fallido y pasa el evento a dead letter.
+func (s *sqlStore) MarkDead(ctx context.Context, id uint64, reason string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE outbox_events SET attempts=attempts+1, next_attempt_at=NULL, last_error=?, dead_at=? WHERE id=?", reason, time.Now().UTC(), id)
+	return err
+}
+
+// Lock reserva la entrega de los eventos con GET_LOCK sobre una conexión propia, que se mantiene hasta liberarla. Si
+// la conexión se pierde, MySQL libera el bloqueo y otra instancia puede tomarlo.
+func (s *sqlStore) Lock(ctx context.Context) (func(), bool, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if locked.Int64 != 1 {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+		_ = conn.Close()
+	}, true, nil
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCKeyFunc devuelve la clave que identifica al cliente de una llamada de gRPC.
+type GRPCKeyFunc func(ctx context.Context) string
+
+// GRPCUnaryInterceptor devuelve un interceptor de gRPC que limita las llamadas de cada cliente a cada método con el
+// límite que indica limits para su nombre completo (por ejemplo, "/user.v1.UserService/CreateUser"); los métodos que
+// no están en limits no se limitan. Del mismo modo que GinMiddleware, agrega a los encabezados de la respuesta los
+// metadatos ratelimit-limit, ratelimit-remaining, ratelimit-reset y ratelimit-policy, y si el cliente superó el límite
+// devuelve ErrLimitExceeded codificado con encodeError, con el metadato retry-after. Si el Store falla, la llamada se
+// permite y el error se registra en el log.
+func GRPCUnaryInterceptor(store Store, limits map[string]Limit, key GRPCKeyFunc, encodeError func(err error) error, l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limit, ok := limits[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		if err := take(ctx, store, info.FullMethod, info.FullMethod+":"+key(ctx), limit, encodeError, l); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCRequestKeyFunc devuelve la clave del balde de una llamada de gRPC a partir del método y de la solicitud, o una
+// cadena vacía si la llamada no se limita.
+type GRPCRequestKeyFunc func(ctx context.Context, method string, req interface{}) string
+
+// GRPCRequestInterceptor es como GRPCUnaryInterceptor, pero todas las llamadas que limita comparten el balde name con
+// la clave que key devuelve a partir de la solicitud, por ejemplo el usuario al que se refiere. Con el mismo name y
+// la misma clave que un GinMiddleware, las llamadas de gRPC y las solicitudes HTTP comparten el balde.
+func GRPCRequestInterceptor(store Store, name string, limit Limit, key GRPCRequestKeyFunc, encodeError func(err error) error, l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		k := key(ctx, info.FullMethod, req)
+		if k == "" {
+			return handler(ctx, req)
+		}
+		if err := take(ctx, store, info.FullMethod, name+":"+k, limit, encodeError, l); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// take consume un token del balde bucket y agrega los metadatos del límite a los encabezados de la respuesta. Devuelve
+// ErrLimitExceeded codificado con encodeError si no quedan tokens. Si el Store falla, la llamada se permite y el error
+// se registra en el log.
+func take(ctx context.Context, store Store, method, bucket string, limit Limit, encodeError func(err error) error, l *slog.Logger) error {
+	res, err := store.Take(ctx, bucket, limit)
+	if err != nil {
+		l.ErrorContext(ctx, "rate limit store failed", "method", method, "error", err)
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", strconv.Itoa(seconds(res.Reset)),
+		"ratelimit-policy", strconv.Itoa(limit.capacity())+";w="+strconv.Itoa(int(limit.Period.Seconds())),
+	)
+	if !res.Allowed {
+		md.Set("retry-after", strconv.Itoa(seconds(res.RetryAfter)))
+		_ = grpc.SetHeader(ctx, md)
+		return encodeError(ErrLimitExceeded)
+	}
+	_ = grpc.SetHeader(ctx, md)
+	return nil
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval es cada cuánto el almacenamiento en memoria elimina los baldes llenos, que equivalen a no tener balde.
+const sweepInterval = time.Minute
+
+// bucket es el estado del balde de un cliente.
+type bucket struct {
+	tokens  float64   // Tokens disponibles en updated
+	updated time.Time // Última vez que se recargó el balde
+	full    time.Time // Momento en el que el balde vuelve a estar lleno
+}
+
+// memoryStore es una implementación de Store en memoria, válida para una sola instancia del servicio.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore crea un Store que guarda los baldes en memoria.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take consume un token del balde identificado por key con el límite indicado.
+func (s *memoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity, rate := float64(limit.capacity()), limit.rate()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	// Recarga los tokens correspondientes al tiempo transcurrido desde la última solicitud.
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	res := Result{Limit: limit.capacity()}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = duration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = duration((capacity - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// sweep elimina los baldes que ya se recargaron por completo, como mucho una vez cada sweepInterval.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// duration convierte una cantidad de segundos en una duración.
+func duration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+/*
+Package ratelimit limita la cantidad de solicitudes que cada cliente puede realizar a cada ruta mediante un token bucket:
+cada cliente dispone de un balde de Burst tokens que se recarga a razón de Requests tokens por Period, y cada
+solicitud consume un token. El estado de los baldes se guarda en un Store, en memoria por defecto o compartido entre
+instancias (por ejemplo, en Redis) implementando la interfaz Store.
+*/
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrLimitExceeded se produce cuando el cliente superó el límite de solicitudes de la ruta.
+var ErrLimitExceeded = errors.New("rate limit exceeded")
+
+func init() {
+	apierror.Register(ErrLimitExceeded, http.StatusTooManyRequests, "rate_limit_exceeded")
+}
+
+// Limit define el límite de solicitudes de una ruta.
+type Limit struct {
+	Requests int           // Tokens que se recargan en cada período
+	Period   time.Duration // Período de recarga
+	Burst    int           // Capacidad del balde; si es 0 se utiliza Requests
+}
+
+// capacity devuelve la capacidad del balde.
+func (l Limit) capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// rate devuelve la cantidad de tokens que se recargan por segundo.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result es el resultado de consumir un token del balde de un cliente.
+type Result struct {
+	Allowed    bool          // Si la solicitud está permitida
+	Limit      int           // Capacidad del balde
+	Remaining  int           // Tokens que quedan en el balde
+	Reset      time.Duration // Tiempo hasta que el balde vuelve a estar lleno
+	RetryAfter time.Duration // Tiempo hasta que haya un token disponible, si la solicitud no está permitida
+}
+
+// Store guarda el estado de los baldes de los clientes.
+// Para compartir los límites entre varias instancias del servicio, Store debe implementarse sobre un almacenamiento
+// compartido y Take debe ser atómica (por ejemplo, un script Lua en Redis).
+type Store interface {
+	// Take consume un token del balde identificado por key con el límite indicado.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// KeyFunc devuelve la clave que identifica al cliente de una solicitud.
+type KeyFunc func(c *gin.Context) string
+
+// CostFunc devuelve la cantidad de tokens que consume una solicitud; con cero, la solicitud no consume tokens.
+type CostFunc func(c *gin.Context) int
+
+// GinMiddleware devuelve un middleware de Gin que limita las solicitudes de cada cliente a la ruta indicada por name.
+// Agrega a la respuesta los encabezados RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset y RateLimit-Policy,
+// y si el cliente superó el límite responde 429 con Retry-After, codificando ErrLimitExceeded con encodeError. Si el
+// Store falla, la solicitud se permite y el error se registra en el log, para que una falla del almacenamiento no
+// deje al servicio sin responder.
+func GinMiddleware(store Store, name string, limit Limit, key KeyFunc, encodeError func(c *gin.Context, err error), l *slog.Logger) gin.HandlerFunc {
+	return GinCostMiddleware(store, name, limit, key, func(*gin.Context) int { return 1 }, encodeError, l)
+}
+
+// GinCostMiddleware es como GinMiddleware, pero cada solicitud consume la cantidad de tokens que indica cost, por
+// ejemplo uno por cada mutación de una operación de GraphQL. Los tokens se consumen de a uno, por lo que los que se
+// consumieron antes de superar el límite no se devuelven.
+func GinCostMiddleware(store Store, name string, limit Limit, key KeyFunc, cost CostFunc, encodeError func(c *gin.Context, err error), l *slog.Logger) gin.HandlerFunc {
+	policy := strconv.Itoa(limit.capacity()) + ";w=" + strconv.Itoa(int(limit.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		n := cost(c)
+		if n <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		bucket := name + ":" + key(c)
+		var res Result
+		for i := 0; i < n; i++ {
+			var err error
+			res, err = store.Take(ctx, bucket, limit)
+			if err != nil {
+				l.ErrorContext(ctx, "rate limit store failed", "route", name, "error", err)
+				c.Next()
+				return
+			}
+			if !res.Allowed {
+				break
+			}
+		}
+
+		respond(c, res, policy, encodeError)
+	}
+}
+
+// KeysFunc devuelve las claves de los baldes de los que consume un token una solicitud; sin claves, la solicitud no
+// consume tokens.
+type KeysFunc func(c *gin.Context) []string
+
+// GinKeysMiddleware es como GinMiddleware, pero cada solicitud consume un token de cada balde cuya clave devuelve
+// keys, por ejemplo uno por cada usuario al que se refieren las mutaciones de una operación de GraphQL. Los
+// encabezados de la respuesta corresponden al último balde consultado, y los tokens que se consumieron antes de
+// superar el límite de uno de los baldes no se devuelven.
+func GinKeysMiddleware(store Store, name string, limit Limit, keys KeysFunc, encodeError func(c *gin.Context, err error), l *slog.Logger) gin.HandlerFunc {
+	policy := strconv.Itoa(limit.capacity()) + ";w=" + strconv.Itoa(int(limit.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		ks := keys(c)
+		if len(ks) == 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		var res Result
+		for _, k := range ks {
+			var err error
+			res, err = store.Take(ctx, name+":"+k, limit)
+			if err != nil {
+				l.ErrorContext(ctx, "rate limit store failed", "route", name, "error", err)
+				c.Next()
+				return
+			}
+			if !res.Allowed {
+				break
+			}
+		}
+
+		respond(c, res, policy, encodeError)
+	}
+}
+
+// respond agrega a la respuesta los encabezados del límite y, si no quedan tokens, responde 429 con Retry-After y
+// detiene la cadena de manejadores; si quedan, continúa con el siguiente.
+func respond(c *gin.Context, res Result, policy string, encodeError func(c *gin.Context, err error)) {
+	h := c.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	h.Set("RateLimit-Policy", policy)
+
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+		encodeError(c, ErrLimitExceeded)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// seconds redondea hacia arriba una duración a segundos enteros, para no indicar al cliente que reintente antes de tiempo.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+// TestGinCostMiddleware verifica que cada solicitud consuma la cantidad de tokens que indica su costo, que las de
+// costo cero no consuman tokens y que se responda 429 al superar el límite.
+func TestGinCostMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	limit := Limit{Requests: 5, Period: time.Minute}
+	cost := func(c *gin.Context) int {
+		n, _ := strconv.Atoi(c.Query("cost"))
+		return n
+	}
+	encodeError := func(c *gin.Context, err error) { c.String(http.StatusTooManyRequests, err.Error()) }
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r.POST("/", GinCostMiddleware(NewMemoryStore(), "POST /", limit, func(*gin.Context) string { return "client" }, cost, encodeError, logger), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		cost, status int
+		remaining    string
+	}{
+		{cost: 3, status: http.StatusNoContent, remaining: "2"},
+		{cost: 0, status: http.StatusNoContent, remaining: ""},
+		{cost: 2, status: http.StatusNoContent, remaining: "0"},
+		{cost: 1, status: http.StatusTooManyRequests, remaining: "0"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?cost="+strconv.Itoa(tt.cost), nil))
+		if w.Code != tt.status || w.Header().Get("RateLimit-Remaining") != tt.remaining {
+			t.Errorf("request %d = %d, remaining %q; want %d, %q", i, w.Code, w.Header().Get("RateLimit-Remaining"), tt.status, tt.remaining)
+		}
+	}
+}
+
+// TestGinKeysMiddleware verifica que cada solicitud consuma un token de cada balde que indica keys, que se responda
+// 429 al superar el límite de cualquiera de ellos y que las solicitudes sin claves no consuman tokens.
+func TestGinKeysMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	limit := Limit{Requests: 2, Period: time.Minute}
+	keys := func(c *gin.Context) []string { return c.QueryArray("key") }
+	encodeError := func(c *gin.Context, err error) { c.String(http.StatusTooManyRequests, err.Error()) }
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r.POST("/", GinKeysMiddleware(NewMemoryStore(), "users", limit, keys, encodeError, logger), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		query     string
+		status    int
+		remaining string
+	}{
+		{query: "key=a&key=b", status: http.StatusNoContent, remaining: "1"},
+		{query: "", status: http.StatusNoContent, remaining: ""},
+		{query: "key=b", status: http.StatusNoContent, remaining: "0"},
+		{query: "key=a&key=b", status: http.StatusTooManyRequests, remaining: "0"},
+		{query: "key=c", status: http.StatusNoContent, remaining: "1"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?"+tt.query, nil))
+		if w.Code != tt.status || w.Header().Get("RateLimit-Remaining") != tt.remaining {
+			t.Errorf("request %d = %d, remaining %q; want %d, %q", i, w.Code, w.Header().Get("RateLimit-Remaining"), tt.status, tt.remaining)
+		}
+	}
+}
+
+// TestGRPCUnaryInterceptor verifica que se limiten las llamadas a los métodos con límite, cada uno con su propio
+// balde, que se devuelva ErrLimitExceeded al superarlo y que los métodos sin límite no consuman tokens.
+func TestGRPCUnaryInterceptor(t *testing.T) {
+	limits := map[string]Limit{
+		"/svc/A": {Requests: 2, Period: time.Minute},
+		"/svc/B": {Requests: 1, Period: time.Minute},
+	}
+	key := func(context.Context) string { return "client" }
+	encodeError := func(err error) error { return err }
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	interceptor := GRPCUnaryInterceptor(NewMemoryStore(), limits, key, encodeError, logger)
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		method string
+		err    error
+	}{
+		{method: "/svc/A"},
+		{method: "/svc/B"},
+		{method: "/svc/A"},
+		{method: "/svc/A", err: ErrLimitExceeded},
+		{method: "/svc/B", err: ErrLimitExceeded},
+		{method: "/svc/C"},
+		{method: "/svc/C"},
+	}
+	for i, tt := range tests {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("call %d to %s = %v; want %v", i, tt.method, err, tt.err)
+		}
+	}
+}
+
+// TestGRPCRequestInterceptor verifica que las llamadas con la misma clave compartan el balde aunque sean a métodos
+// distintos, y que las llamadas sin clave no se limiten.
+func TestGRPCRequestInterceptor(t *testing.T) {
+	key := func(_ context.Context, _ string, req interface{}) string {
+		s, _ := req.(string)
+		return s
+	}
+	encodeError := func(err error) error { return err }
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	interceptor := GRPCRequestInterceptor(NewMemoryStore(), "bucket", Limit{Requests: 2, Period: time.Minute}, key, encodeError, logger)
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		method, key string
+		err         error
+	}{
+		{method: "/svc/A", key: "user:1"},
+		{method: "/svc/B", key: "user:1"},
+		{method: "/svc/A", key: "user:1", err: ErrLimitExceeded},
+		{method: "/svc/A", key: "user:2"},
+		{method: "/svc/A"},
+		{method: "/svc/A"},
+	}
+	for i, tt := range tests {
+		_, err := interceptor(context.Background(), tt.key, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("call %d to %s with key %q = %v; want %v", i, tt.method, tt.key, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package requestctx
+
+/*
+Package requestctx permite transportar en el contexto los datos propios de cada solicitud, como su ID, el actor que la realiza, la identidad del certificado de cliente, la clave de idempotencia y el código del segundo factor.
+*/
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxRequestIDLength es la longitud máxima del ID de una solicitud, la de la columna audit_log.request_id.
+	MaxRequestIDLength = 64
+
+	// MaxActorLength es la longitud máxima, en caracteres, del actor de una solicitud, la de la columna audit_log.actor.
+	MaxActorLength = 100
+)
+
+// key es el tipo de las claves que el paquete guarda en el contexto, para evitar colisiones con otros paquetes.
+type key int
+
+const (
+	requestIDKey      key = iota // Clave del ID de la solicitud
+	actorKey                     // Clave del actor que realiza la solicitud
+	clientIdentityKey            // Clave de la identidad del certificado de cliente (mTLS)
+	idempotencyKeyKey            // Clave de la clave de idempotencia de la solicitud
+	declaredActorKey             // Clave del actor declarado por el cliente
+	apiClientKey                 // Clave del cliente autenticado con una clave de API
+	twoFactorCodeKey             // Clave del código del segundo factor enviado con la solicitud
+)
+
+// WithRequestID devuelve una copia del contexto con el ID de la solicitud.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID devuelve el ID de la solicitud guardado en el contexto, o una cadena vacía si no existe.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithActor devuelve una copia del contexto con el actor que realiza la solicitud.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor devuelve el actor guardado en el contexto, o una cadena vacía si no existe.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithDeclaredActor devuelve una copia del contexto con el actor que el cliente declara en la solicitud (encabezado
+// X-Actor). A diferencia del actor, no está autenticado: el cliente puede indicar cualquier valor.
+func WithDeclaredActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, declaredActorKey, actor)
+}
+
+// DeclaredActor devuelve el actor declarado por el cliente guardado en el contexto, o una cadena vacía si no existe.
+func DeclaredActor(ctx context.Context) string {
+	actor, _ := ctx.Value(declaredActorKey).(string)
+	return actor
+}
+
+// WithClientIdentity devuelve una copia del contexto con la identidad del certificado de cliente verificado por mTLS.
+func WithClientIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, clientIdentityKey, identity)
+}
+
+// ClientIdentity devuelve la identidad del certificado de cliente guardada en el contexto,
+// o una cadena vacía si la solicitud no presentó un certificado verificado.
+func ClientIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(clientIdentityKey).(string)
+	return identity
+}
+
+// WithAPIClient devuelve una copia del contexto con el nombre del cliente que se autenticó con su clave de API.
+func WithAPIClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, apiClientKey, client)
+}
+
+// APIClient devuelve el nombre del cliente autenticado con una clave de API guardado en el contexto, o una cadena
+// vacía si la solicitud se autenticó con el token compartido.
+func APIClient(ctx context.Context) string {
+	client, _ := ctx.Value(apiClientKey).(string)
+	return client
+}
+
+// WithTwoFactorCode devuelve una copia del contexto con el código del segundo factor (TOTP o de recuperación) que el
+// cliente envía con la solicitud.
+func WithTwoFactorCode(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, twoFactorCodeKey, code)
+}
+
+// TwoFactorCode devuelve el código del segundo factor guardado en el contexto, o una cadena vacía si la solicitud no
+// lo incluye.
+func TwoFactorCode(ctx context.Context) string {
+	code, _ := ctx.Value(twoFactorCodeKey).(string)
+	return code
+}
+
+// WithIdempotencyKey devuelve una copia del contexto con la clave de idempotencia de la solicitud.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey, key)
+}
+
+// IdempotencyKey devuelve la clave de idempotencia guardada en el contexto, o una cadena vacía si la solicitud no la incluye.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey).(string)
+	return key
+}
+
+// ValidRequestID indica si el ID de solicitud enviado por un cliente se puede usar: no vacío, de hasta
+// MaxRequestIDLength caracteres y solo con caracteres ASCII imprimibles, sin espacios, para que no altere los logs
+// ni los encabezados de la respuesta.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// CleanActor devuelve el actor sin caracteres de control ni espacios al comienzo o al final, recortado a
+// MaxActorLength caracteres.
+func CleanActor(actor string) string {
+	actor = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, actor))
+	if runes := []rune(actor); len(runes) > MaxActorLength {
+		actor = strings.TrimSpace(string(runes[:MaxActorLength]))
+	}
+	return actor
+}
@@ -0,0 +1,47 @@
+package requestctx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"0123456789abcdef", true},
+		{"req-1:a/b_c.d", true},
+		{strings.Repeat("a", MaxRequestIDLength), true},
+		{strings.Repeat("a", MaxRequestIDLength+1), false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{"tab\t", false},
+		{"ñandú", false},
+		{"del\x7f", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestCleanActor(t *testing.T) {
+	tests := []struct {
+		actor string
+		want  string
+	}{
+		{"", ""},
+		{"  alice  ", "alice"},
+		{"José Núñez", "José Núñez"},
+		{"alice\r\nforged: entry", "aliceforged: entry"},
+		{strings.Repeat("ñ", MaxActorLength+10), strings.Repeat("ñ", MaxActorLength)},
+	}
+	for _, tt := range tests {
+		if got := CleanActor(tt.actor); got != tt.want {
+			t.Errorf("CleanActor(%q) = %q, want %q", tt.actor, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package tlsconfig
+
+/*
+Package tlsconfig construye la configuración TLS del servidor a partir de archivos de certificado, clave y CA de clientes,
+y la recarga cuando esos archivos cambian en disco, sin reiniciar el servidor ni cortar las conexiones existentes.
+*/
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader mantiene la configuración TLS vigente y la recarga cuando cambian los archivos.
+type Reloader interface {
+	// Config devuelve la configuración TLS que debe utilizar el servidor; cada conexión nueva usa los archivos vigentes.
+	Config() *tls.Config
+	// Reload vuelve a leer los archivos si cambiaron desde la última carga. Si fallan, se mantiene la configuración anterior.
+	Reload() error
+	// Run verifica los archivos cada interval hasta que se cancele el contexto.
+	Run(ctx context.Context)
+}
+
+// Options agrupa los archivos y opciones de la configuración TLS.
+type Options struct {
+	CertFile          string        // Certificado del servidor en PEM
+	KeyFile           string        // Clave privada del servidor en PEM
+	ClientCAFile      string        // CA con la que se verifican los certificados de los clientes; vacío desactiva mTLS
+	RequireClientCert bool          // Si es true (y hay CA), los clientes deben presentar un certificado válido
+	ReloadInterval    time.Duration // Frecuencia con la que se verifican los cambios de los archivos
+}
+
+// reloader es una implementación de Reloader.
+type reloader struct {
+	opts Options
+	log  *slog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// New carga los archivos y devuelve el Reloader; falla si la carga inicial no es válida.
+func New(opts Options, l *slog.Logger) (Reloader, error) {
+	r := &reloader{
+		opts:     opts,
+		log:      l,
+		modTimes: map[string]time.Time{},
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config devuelve la configuración TLS del servidor. El certificado y la CA de clientes se resuelven en cada
+// conexión, de modo que una recarga se aplica a las conexiones nuevas.
+func (r *reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetCertificate:     r.getCertificate,
+		GetConfigForClient: r.configForClient,
+	}
+}
+
+// getCertificate devuelve el certificado vigente.
+func (r *reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// configForClient devuelve la configuración de una conexión con el certificado y la CA de clientes vigentes.
+func (r *reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.clientCAs != nil {
+		cfg.ClientCAs = r.clientCAs
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.opts.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
+
+// Reload vuelve a leer los archivos si cambiaron desde la última carga.
+func (r *reloader) Reload() error {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+
+	modTimes := map[string]time.Time{}
+	changed := false
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client CA: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("load client CA: no certificates found")
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Run verifica los archivos cada ReloadInterval hasta que se cancele el contexto.
+func (r *reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.RLock()
+		before := r.cert
+		r.mu.RUnlock()
+
+		if err := r.Reload(); err != nil {
+			r.log.ErrorContext(ctx, "tls reload failed, keeping the current certificate", "error", err)
+			continue
+		}
+
+		r.mu.RLock()
+		reloaded := r.cert != before
+		r.mu.RUnlock()
+		if reloaded {
+			r.log.InfoContext(ctx, "tls certificate reloaded", "cert_file", r.opts.CertFile)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReload verifica que al reemplazar el certificado y la clave en disco se pase a usar el certificado nuevo, y que
+// si el par nuevo no es válido se mantenga el certificado anterior.
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		CertFile:       filepath.Join(dir, "cert.pem"),
+		KeyFile:        filepath.Join(dir, "key.pem"),
+		ReloadInterval: time.Second,
+	}
+	modTime := time.Now().Add(-time.Hour)
+
+	first, firstKey := newCertificate(t, 1)
+	writePair(t, opts, first, firstKey, modTime)
+	r, err := New(opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New = %v", err)
+	}
+	assertSerial(t, r, 1)
+
+	// Sin cambios en los archivos no se vuelve a cargar el par.
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload without changes = %v", err)
+	}
+	assertSerial(t, r, 1)
+
+	second, secondKey := newCertificate(t, 2)
+	modTime = modTime.Add(time.Minute)
+	writePair(t, opts, second, secondKey, modTime)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload = %v", err)
+	}
+	assertSerial(t, r, 2)
+
+	// Un certificado con la clave de otro par no es válido: se mantiene el certificado anterior.
+	third, _ := newCertificate(t, 3)
+	modTime = modTime.Add(time.Minute)
+	writePair(t, opts, third, firstKey, modTime)
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload with a mismatched key = nil; want an error")
+	}
+	assertSerial(t, r, 2)
+}
+
+// newCertificate genera un certificado autofirmado con el número de serie indicado y su clave privada, en DER.
+func newCertificate(t *testing.T, serial int64) (cert, key []byte) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// writePair escribe el certificado y la clave en PEM en los archivos de opts, con la fecha de modificación indicada
+// para no depender de la resolución del reloj del sistema de archivos.
+func writePair(t *testing.T, opts Options, cert, key []byte, modTime time.Time) {
+	t.Helper()
+	files := map[string]*pem.Block{
+		opts.CertFile: {Type: "CERTIFICATE", Bytes: cert},
+		opts.KeyFile:  {Type: "EC PRIVATE KEY", Bytes: key},
+	}
+	for name, block := range files {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// assertSerial verifica el número de serie del certificado que el Reloader entrega a las conexiones nuevas.
+func assertSerial(t *testing.T, r Reloader, want int64) {
+	t.Helper()
+	cfg, err := r.Config().GetConfigForClient(nil)
+	if err != nil {
+		t.Fatalf("GetConfigForClient = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.SerialNumber.Int64() != want {
+		t.Fatalf("serial = %d; want %d", leaf.SerialNumber.Int64(), want)
+	}
+}
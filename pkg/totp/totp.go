@@ -0,0 +1,110 @@
+package totp
+
+/*
+Package totp implementa contraseñas de un solo uso basadas en tiempo (TOTP, RFC 6238) compatibles con aplicaciones como Google Authenticator.
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	digits = 6                // Cantidad de dígitos de cada código
+	period = 30 * time.Second // Tiempo de validez de cada código
+	skew   = 1                // Cantidad de períodos anteriores y posteriores que se aceptan para tolerar desfasajes de reloj
+)
+
+// encoding es la codificación base32 sin relleno utilizada por las aplicaciones autenticadoras.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret genera un nuevo secreto aleatorio de 160 bits codificado en base32.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Code devuelve el código correspondiente al secreto en el instante proporcionado.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return code(key, uint64(t.Unix())/uint64(period.Seconds())), nil
+}
+
+// Validate indica si el código es válido para el secreto en el instante proporcionado.
+func Validate(passcode, secret string, t time.Time) bool {
+	_, ok := Step(passcode, secret, t)
+	return ok
+}
+
+// Step indica si el código es válido para el secreto en el instante proporcionado y devuelve el período (contador de
+// la RFC 6238) al que corresponde. Para que un código no pueda reutilizarse mientras es válido, quien lo verifica debe
+// guardar el último período aceptado y rechazar los códigos de ese período o de uno anterior.
+func Step(passcode, secret string, t time.Time) (uint64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(passcode) != digits {
+		return 0, false
+	}
+
+	counter := int64(uint64(t.Unix()) / uint64(period.Seconds()))
+	for i := -skew; i <= skew; i++ {
+		step := uint64(counter + int64(i))
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(passcode)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI devuelve la URI otpauth:// que las aplicaciones autenticadoras utilizan para registrar el secreto.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(digits))
+	v.Set("period", fmt.Sprint(int(period.Seconds())))
+
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, account))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+// QRCode devuelve una imagen PNG con el código QR de la URI otpauth:// proporcionada.
+func QRCode(uri string) ([]byte, error) {
+	return qrcode.Encode(uri, qrcode.Medium, 256)
+}
+
+// code calcula el código HOTP (RFC 4226) para la clave y el contador proporcionados.
+func code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Truncamiento dinámico definido en la RFC 4226.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
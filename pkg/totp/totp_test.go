@@ -0,0 +1,66 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret es el secreto de los vectores de prueba de la RFC 6238 para SHA-1 ("12345678901234567890"), en base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCode verifica los códigos con los vectores de prueba del apéndice B de la RFC 6238 para SHA-1, tomando los
+// últimos seis de sus ocho dígitos.
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+// TestStep verifica que se acepten los códigos del período actual y de los adyacentes, con su período, y que se
+// rechacen los demás.
+func TestStep(t *testing.T) {
+	now := time.Unix(1111111111, 0) // Período 37037037
+	tests := []struct {
+		name     string
+		passcode string
+		want     uint64
+		ok       bool
+	}{
+		{name: "current", passcode: "050471", want: 37037037, ok: true},
+		{name: "previous", passcode: "081804", want: 37037036, ok: true},
+		{name: "two periods ago", passcode: "287082"},
+		{name: "wrong length", passcode: "50471"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Step(tt.passcode, rfcSecret, now)
+			if step != tt.want || ok != tt.ok {
+				t.Errorf("Step() = %d, %v; want %d, %v", step, ok, tt.want, tt.ok)
+			}
+			if Validate(tt.passcode, rfcSecret, now) != tt.ok {
+				t.Errorf("Validate() = %v, want %v", !tt.ok, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := Step("050471", "not base32!", now); ok {
+		t.Error("Step() accepted an invalid secret")
+	}
+}
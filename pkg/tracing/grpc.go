@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCUnaryInterceptor devuelve un interceptor de gRPC que continúa la traza recibida en el metadato traceparent
+// (o inicia una nueva) y crea el span de servidor de cada llamada, nombrado según el método, como "user.v1.UserService/GetUser".
+func GRPCUnaryInterceptor(component string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+		method := strings.TrimPrefix(info.FullMethod, "/")
+		service, name, _ := strings.Cut(method, "/")
+		ctx, span := otel.Tracer(component).Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.RPCSystemGRPC,
+				semconv.RPCService(service),
+				semconv.RPCMethod(name),
+			),
+		)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if err != nil {
+			span.SetStatus(codes.Error, code.String())
+		}
+		return resp, err
+	}
+}
+
+// metadataCarrier adapta los metadatos de gRPC a la interfaz propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+// Get devuelve el primer valor de la clave.
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set reemplaza los valores de la clave.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys devuelve las claves de los metadatos.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,138 @@
+package tracing
+
+/*
+Package tracing configura OpenTelemetry para generar trazas de las solicitudes, propagar el contexto de traza con el
+estándar W3C (encabezado traceparent) y exportar los spans al destino configurado.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exportadores disponibles.
+const (
+	ExporterNone   = "none"   // No se exportan los spans; la propagación del contexto sigue funcionando
+	ExporterStdout = "stdout" // Los spans se escriben en la salida estándar en JSON
+	ExporterFile   = "file"   // Los spans se escriben en un archivo en JSON, útil para pruebas sin conexión
+	ExporterOTLP   = "otlp"   // Los spans se envían a un colector OTLP/HTTP (configurable con las variables OTEL_EXPORTER_OTLP_*)
+)
+
+// Config agrupa la configuración de las trazas.
+type Config struct {
+	ServiceName string // Nombre del servicio que se informa en los spans
+	Exporter    string // Exportador a utilizar: none, stdout, file u otlp
+	File        string // Archivo en el que se escriben los spans con el exportador file
+}
+
+// Setup configura el proveedor global de trazas y el propagador W3C.
+// Devuelve la función que exporta los spans pendientes y libera los recursos, que debe llamarse al detener el servicio.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	// El propagador W3C se configura siempre, para que el contexto de traza recibido se siga propagando.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var f *os.File
+		if f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+			return nil, err
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter '%s'", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start inicia un span con el nombre indicado utilizando el tracer del componente.
+func Start(ctx context.Context, component, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(component).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End finaliza el span registrando el error, si lo hay.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// GinMiddleware devuelve un middleware de Gin que continúa la traza recibida en el encabezado traceparent
+// (o inicia una nueva) y crea el span de servidor de la solicitud, nombrado según la plantilla de la ruta.
+func GinMiddleware(component string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := otel.Tracer(component).Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
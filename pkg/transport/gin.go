@@ -1,12 +1,17 @@
 package transport
 
 import (
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
 	"github.com/gin-gonic/gin"
 )
 
+// tracerName es el nombre del tracer con el que se crean los spans de las etapas del transporte.
+const tracerName = "github.com/EmiiFernandez/go-fundamentals-web-users/pkg/transport"
+
 // GinServer crea un manejador HTTP utilizando Gin Gonic.
 // Toma un endpoint, funciones para decodificar, codificar y manejar errores,
 // y devuelve un manejador HTTP compatible con Gin.
+// Cada etapa (decodificación, endpoint y codificación) se registra como un span hijo del span de la solicitud.
 func GinServer(
 	endpoint Endpoint,
 	decode func(c *gin.Context) (interface{}, error),
@@ -17,7 +22,9 @@ func GinServer(
 	// La función anónima devuelta actúa como el manejador HTTP para Gin.
 	return func(c *gin.Context) {
 		// Decodifica la solicitud utilizando la función de decodificación proporcionada.
+		_, span := tracing.Start(c.Request.Context(), tracerName, "decode")
 		data, err := decode(c)
+		tracing.End(span, err)
 		if err != nil {
 			// Si hay un error durante la decodificación, se codifica el error y se envía como respuesta.
 			encodeError(c, err)
@@ -25,7 +32,9 @@ func GinServer(
 		}
 
 		// Llama al endpoint proporcionado con el contexto de la solicitud y los datos decodificados.
-		res, err := endpoint(c.Request.Context(), data)
+		ctx, span := tracing.Start(c.Request.Context(), tracerName, "endpoint")
+		res, err := endpoint(ctx, data)
+		tracing.End(span, err)
 		if err != nil {
 			// Si hay un error al llamar al endpoint, se codifica el error y se envía como respuesta.
 			encodeError(c, err)
@@ -33,7 +42,9 @@ func GinServer(
 		}
 
 		// Codifica la respuesta y la envía como respuesta HTTP.
+		_, span = tracing.Start(c.Request.Context(), tracerName, "encode")
 		encode(c, res)
+		span.End()
 	}
 }
 
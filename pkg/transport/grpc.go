@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/apierror"
+	"github.com/EmiiFernandez/go-fundamentals-web-users/pkg/tracing"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain es el dominio de los errores que se informa en el detalle ErrorInfo de los errores de gRPC.
+const errorDomain = "go-fundamentals-web-users"
+
+// GRPCServer crea el manejador de un método unario de gRPC a partir de un endpoint, del mismo modo que GinServer lo
+// adapta a Gin: decode convierte el mensaje de la solicitud en la solicitud del endpoint y encode convierte la
+// respuesta del endpoint en el mensaje de la respuesta. Los errores se convierten en errores de gRPC con GRPCError.
+// Cada etapa (decodificación, endpoint y codificación) se registra como un span hijo del span de la llamada.
+func GRPCServer[Req, Resp any](
+	endpoint Endpoint,
+	decode func(ctx context.Context, req Req) (interface{}, error),
+	encode func(ctx context.Context, resp interface{}) (Resp, error),
+) func(ctx context.Context, req Req) (Resp, error) {
+	return func(ctx context.Context, req Req) (Resp, error) {
+		var zero Resp
+
+		// Decodifica el mensaje de la solicitud.
+		_, span := tracing.Start(ctx, tracerName, "decode")
+		data, err := decode(ctx, req)
+		tracing.End(span, err)
+		if err != nil {
+			return zero, GRPCError(err)
+		}
+
+		// Llama al endpoint con el contexto de la llamada y los datos decodificados.
+		endpointCtx, span := tracing.Start(ctx, tracerName, "endpoint")
+		res, err := endpoint(endpointCtx, data)
+		tracing.End(span, err)
+		if err != nil {
+			return zero, GRPCError(err)
+		}
+
+		// Codifica la respuesta del endpoint en el mensaje de la respuesta.
+		_, span = tracing.Start(ctx, tracerName, "encode")
+		out, err := encode(ctx, res)
+		tracing.End(span, err)
+		if err != nil {
+			return zero, GRPCError(err)
+		}
+		return out, nil
+	}
+}
+
+// GRPCError convierte un error en un error de gRPC. Los errores de dominio se traducen según el registro de apierror:
+// el código de estado HTTP registrado se convierte en el código de gRPC equivalente (por ejemplo, ErrNotFound en
+// NotFound), el código de error se informa en un detalle ErrorInfo y los errores de los campos de las solicitudes, en
+// un detalle BadRequest. Los errores que ya son errores de gRPC se devuelven sin cambios.
+func GRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	p := apierror.NewProblem(err, "", "")
+	st := status.New(GRPCCode(p.Status), p.Detail)
+
+	var details []protoadapt.MessageV1
+	if p.Code != "" {
+		details = append(details, &errdetails.ErrorInfo{Reason: p.Code, Domain: errorDomain})
+	}
+	if len(p.Errors) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(p.Errors))
+		for _, f := range p.Errors {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Pointer, Description: f.Detail})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// GRPCCode devuelve el código de gRPC equivalente a un código de estado HTTP.
+func GRPCCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case apierror.StatusClientClosedRequest:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	if httpStatus >= http.StatusBadRequest {
+		return codes.InvalidArgument
+	}
+	return codes.Unknown
+}
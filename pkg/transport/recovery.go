@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/EmiiFernandez/go-fundamentals-response/response"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GinRecovery devuelve un middleware de Gin que recupera los pánicos de los manejadores posteriores, como una
+// aserción de tipo fallida en un decodificador o un controlador. Registra el pánico con su stack en el log (con el ID
+// de la solicitud del contexto) y, si todavía no se envió la respuesta, responde 500 con encodeError.
+func GinRecovery(l *slog.Logger, encodeError func(c *gin.Context, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// http.ErrAbortHandler indica que el manejador abortó la respuesta a propósito; se deja que net/http lo maneje.
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+
+			l.ErrorContext(c.Request.Context(), "panic recovered",
+				"method", c.Request.Method,
+				"route", c.FullPath(),
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+
+			if !c.Writer.Written() {
+				encodeError(c, response.InternalServerError("internal server error"))
+			}
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// GRPCRecovery devuelve un interceptor de gRPC que recupera los pánicos de los manejadores, los registra con su stack
+// en el log (con el ID de la solicitud del contexto) y responde la llamada con el código Internal.
+func GRPCRecovery(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			l.ErrorContext(ctx, "panic recovered",
+				"method", info.FullMethod,
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+			resp, err = nil, status.Error(codes.Internal, "internal server error")
+		}()
+		return handler(ctx, req)
+	}
+}